@@ -1,38 +1,85 @@
 package main
 
 import (
-	"github.com/bgrewell/iso-kit"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/logging"
 	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/bgrewell/iso-kit/pkg/spec"
+	"github.com/bgrewell/iso-kit/pkg/version"
+	"github.com/bgrewell/usage"
 	"os"
 )
 
 func main() {
+	// Initialize usage handler
+	u := usage.NewUsage(
+		usage.WithApplicationVersion(version.Version()),
+		usage.WithApplicationBranch(version.Branch()),
+		usage.WithApplicationBuildDate(version.Date()),
+		usage.WithApplicationCommitHash(version.Revision()),
+		usage.WithApplicationName("isocreate"),
+		usage.WithApplicationDescription("isocreate is a command-line tool for building ISO9660 images from a declarative YAML or JSON build spec, including support for Rock Ridge, Joliet, El Torito and hybrid MBR images."),
+	)
 
-	name := "ubuntu-test-iso"
-	source := "/tmp/ubuntu-iso"
-	dest := "/tmp/created-ubuntu.iso"
-	_ = source //TODO: this will be passed in via an 'AddDir' command
-	_ = dest
+	// Define CLI options
+	help := u.AddBooleanOption("h", "help", false, "Show this help message", "optional", nil)
+	verbose := u.AddBooleanOption("v", "verbose", false, "Enable verbose (debug) logging", "", nil)
+	specPath := u.AddStringOption("s", "spec", "", "Path to the YAML or JSON build spec", "", nil)
+	outputPath := u.AddStringOption("o", "output", "", "Path of the ISO image to write", "", nil)
 
-	// Use values from the real ISO to simplify testing
-	name = "Ubuntu-Server 24.04.1 LTS amd64"
-	preparer := "XORRISO-1.5.4 2021.01.30.150001, LIBISOBURN-1.5.4, LIBISOFS-1.5.4, LIBBURN-1.5.4"
+	// Parse arguments
+	parsed := u.Parse()
+	if !parsed {
+		u.PrintError(fmt.Errorf("failed to parse arguments"))
+		os.Exit(1)
+	}
 
-	i, err := iso.Create(name,
-		option.WithPreparerID(preparer),
-	)
-	if err != nil {
-		panic(err)
+	// Handle help flag
+	if *help {
+		u.PrintUsage()
+		os.Exit(0)
+	}
+
+	// Ensure the spec and output were provided
+	if specPath == nil || *specPath == "" {
+		u.PrintError(fmt.Errorf("path to the build spec must be provided"))
+		os.Exit(1)
+	}
+	if outputPath == nil || *outputPath == "" {
+		u.PrintError(fmt.Errorf("path to the output ISO must be provided"))
+		os.Exit(1)
 	}
 
-	f, err := os.Create(dest)
+	// Setup logging level
+	level := logging.LEVEL_INFO
+	if *verbose {
+		level = logging.LEVEL_DEBUG
+	}
+	logger := logging.NewLogger(logging.NewSimpleLogger(os.Stderr, level, true))
+
+	s, err := spec.Load(*specPath)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "Failed to load spec: %v\n", err)
+		os.Exit(1)
 	}
 
-	err = i.Save(f)
+	f, err := os.Create(*outputPath)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "Failed to create output file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = s.Build(f, option.WithEnableLogging(logger)); err != nil {
+		f.Close()
+		os.Remove(*outputPath)
+		fmt.Fprintf(os.Stderr, "Failed to build image: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write image: %v\n", err)
+		os.Exit(1)
 	}
 
+	fmt.Printf("Image written to %s\n", *outputPath)
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/logging"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/bgrewell/iso-kit/pkg/version"
+	"github.com/bgrewell/usage"
+	"os"
+	"time"
+)
+
+// optionalString returns nil for options that were not given so the field is left unchanged.
+func optionalString(value *string) *string {
+	if value == nil || *value == "" {
+		return nil
+	}
+	return value
+}
+
+// optionalTime parses an RFC 3339 date, returning nil for options that were not given.
+func optionalTime(name string, value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s date %q: %w", name, *value, err)
+	}
+	return &t, nil
+}
+
+func main() {
+	// Initialize usage handler
+	u := usage.NewUsage(
+		usage.WithApplicationVersion(version.Version()),
+		usage.WithApplicationBranch(version.Branch()),
+		usage.WithApplicationBuildDate(version.Date()),
+		usage.WithApplicationCommitHash(version.Revision()),
+		usage.WithApplicationName("isoedit"),
+		usage.WithApplicationDescription("isoedit is a command-line tool for editing existing ISO9660 images in place. The label command rewrites the volume identifiers and dates of the primary and supplementary volume descriptors without rebuilding the image."),
+	)
+
+	// Define CLI options
+	help := u.AddBooleanOption("h", "help", false, "Show this help message", "optional", nil)
+	verbose := u.AddBooleanOption("v", "verbose", false, "Enable verbose (debug) logging", "", nil)
+
+	// Label options, empty values are left unchanged
+	volumeID := u.AddStringOption("V", "volume-id", "", "New volume identifier", "", nil)
+	systemID := u.AddStringOption("sys", "system-id", "", "New system identifier", "", nil)
+	volumeSetID := u.AddStringOption("vs", "volume-set-id", "", "New volume set identifier", "", nil)
+	publisher := u.AddStringOption("p", "publisher", "", "New publisher identifier", "", nil)
+	preparer := u.AddStringOption("pr", "preparer", "", "New data preparer identifier", "", nil)
+	application := u.AddStringOption("a", "application", "", "New application identifier", "", nil)
+	creation := u.AddStringOption("c", "creation", "", "New volume creation date (RFC 3339)", "", nil)
+	modification := u.AddStringOption("m", "modification", "", "New volume modification date (RFC 3339)", "", nil)
+	expiration := u.AddStringOption("e", "expiration", "", "New volume expiration date (RFC 3339)", "", nil)
+	effective := u.AddStringOption("ef", "effective", "", "New volume effective date (RFC 3339)", "", nil)
+
+	// Command and ISO file path arguments
+	command := u.AddArgument(1, "command", "Edit to perform (label)", "")
+	isoPath := u.AddArgument(2, "iso-path", "Path to the ISO file", "")
+
+	// Parse arguments
+	parsed := u.Parse()
+	if !parsed {
+		u.PrintError(fmt.Errorf("failed to parse arguments"))
+		os.Exit(1)
+	}
+
+	// Handle help flag
+	if *help {
+		u.PrintUsage()
+		os.Exit(0)
+	}
+
+	if command == nil || *command != "label" {
+		u.PrintError(fmt.Errorf("a supported command must be provided (label)"))
+		os.Exit(1)
+	}
+
+	// Ensure an ISO path was provided
+	if isoPath == nil || *isoPath == "" {
+		u.PrintError(fmt.Errorf("path to the ISO file must be provided"))
+		os.Exit(1)
+	}
+
+	label := iso9660.VolumeLabel{
+		VolumeID:      optionalString(volumeID),
+		SystemID:      optionalString(systemID),
+		VolumeSetID:   optionalString(volumeSetID),
+		PublisherID:   optionalString(publisher),
+		PreparerID:    optionalString(preparer),
+		ApplicationID: optionalString(application),
+	}
+	var err error
+	for _, d := range []struct {
+		name   string
+		value  *string
+		target **time.Time
+	}{
+		{"creation", creation, &label.CreationTime},
+		{"modification", modification, &label.ModificationTime},
+		{"expiration", expiration, &label.ExpirationTime},
+		{"effective", effective, &label.EffectiveTime},
+	} {
+		if *d.target, err = optionalTime(d.name, d.value); err != nil {
+			u.PrintError(err)
+			os.Exit(1)
+		}
+	}
+
+	// Only log while reading the image when verbose output was requested
+	var openOpts []option.OpenOption
+	if *verbose {
+		logger := logging.NewLogger(logging.NewSimpleLogger(os.Stderr, logging.LEVEL_DEBUG, true))
+		openOpts = append(openOpts, option.WithLogger(logger))
+	}
+
+	f, err := os.OpenFile(*isoPath, os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open ISO: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	img, err := iso9660.Open(f, openOpts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open ISO: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err = img.Relabel(f, label); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to relabel image: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Image %s relabeled\n", *isoPath)
+}
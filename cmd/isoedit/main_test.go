@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// TestMain runs the command instead of the tests when the test binary is started by runIsoedit.
+func TestMain(m *testing.M) {
+	if os.Getenv("ISOEDIT_RUN_MAIN") == "1" {
+		os.Args = append([]string{"isoedit"}, os.Args[1:]...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runIsoedit runs isoedit with the given arguments and returns its combined output.
+func runIsoedit(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "ISOEDIT_RUN_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestLabel(t *testing.T) {
+	img, err := iso9660.Create("OLD_LABEL", option.WithJolietEnabled(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/readme.txt", []byte("hello")))
+	path := isotest.File(t, img).Name()
+
+	out, err := runIsoedit(t, "--volume-id", "new label", "--publisher", "ACME", "--creation", "2020-02-03T04:05:06Z", "label", path)
+	require.NoError(t, err, out)
+	require.Contains(t, out, "relabeled")
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	opened, err := iso9660.Open(f)
+	require.NoError(t, err)
+	require.Equal(t, "NEW_LABEL", opened.GetVolumeID())
+	require.Equal(t, "ACME", opened.GetPublisherID())
+	require.True(t, time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC).Equal(opened.GetCreationDateTime()))
+
+	// Invalid dates and unknown commands are reported without touching the image
+	out, err = runIsoedit(t, "--creation", "yesterday", "label", path)
+	require.Error(t, err)
+	require.Contains(t, out, "invalid creation date")
+	_, err = runIsoedit(t, "--volume-id", "OTHER", "rename", path)
+	require.Error(t, err)
+	reopened, err := iso9660.Open(f)
+	require.NoError(t, err)
+	require.Equal(t, "NEW_LABEL", reopened.GetVolumeID())
+}
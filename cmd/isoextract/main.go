@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/bgrewell/iso-kit"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
 	"github.com/bgrewell/iso-kit/pkg/option"
 	"github.com/bgrewell/iso-kit/pkg/version"
 	"github.com/bgrewell/usage"
@@ -108,7 +109,11 @@ func main() {
 	bootImages := u.AddBooleanOption("b", "boot", false, "Extract boot images (El Torito)", "", nil)
 	rockRidge := u.AddBooleanOption("rr", "rockridge", true, "Enable Rock Ridge support", "", nil)
 	enhancedVol := u.AddBooleanOption("eh", "enhanced", true, "Use Enhanced Volume Descriptors", "", nil)
+	joliet := u.AddBooleanOption("j", "joliet", true, "Use Joliet Volume Descriptors", "", nil)
+	merged := u.AddBooleanOption("m", "merged", false, "Combine Joliet names with Rock Ridge attributes", "", nil)
 	stripVer := u.AddBooleanOption("s", "strip", true, "Strip version info from filenames", "", nil)
+	session := u.AddIntegerOption("S", "session", 0, "Session of a multisession image to extract, -1 for the last", "", nil)
+	tolerant := u.AddBooleanOption("t", "tolerant", false, "Skip damaged structures of the image and salvage every readable file", "", nil)
 
 	// Output directories
 	outputDir := u.AddStringOption("o", "output", "./extracted", "Output directory for extracted files", "", nil)
@@ -158,10 +163,13 @@ func main() {
 		*isoPath,
 		option.WithElToritoEnabled(*bootImages),
 		option.WithRockRidgeEnabled(*rockRidge),
-		option.WithParseOnOpen(*enhancedVol),
 		option.WithBootFileExtractLocation(*bootDir),
-		option.WithPreferJoliet(*enhancedVol),
+		option.WithPreferEnhanced(*enhancedVol),
+		option.WithPreferJoliet(*joliet),
+		option.WithMergedView(*merged),
 		option.WithStripVersionInfo(*stripVer),
+		option.WithSession(*session),
+		option.WithTolerantParsing(*tolerant),
 		option.WithExtractionProgress(progressCallback),
 	)
 	if err != nil {
@@ -189,5 +197,15 @@ func main() {
 		time.Sleep(10 * time.Millisecond)
 	}
 
+	// Report the damaged structures that were skipped
+	if img, ok := img.(*iso9660.ISO9660); ok {
+		if problems := img.Problems(); len(problems) > 0 {
+			fmt.Fprintf(os.Stderr, "Skipped %d damaged structures:\n", len(problems))
+			for _, problem := range problems {
+				fmt.Fprintf(os.Stderr, "  %v\n", problem)
+			}
+		}
+	}
+
 	//fmt.Printf("Extraction completed successfully to '%s'.\n", *outputDir)
 }
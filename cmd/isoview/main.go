@@ -3,9 +3,11 @@ package main
 import (
 	"fmt"
 	"github.com/bgrewell/iso-kit"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
 	"github.com/bgrewell/iso-kit/pkg/version"
 	"github.com/bgrewell/usage"
 	"os"
+	"time"
 )
 
 // DisplayISOInfo prints general information about the ISO file.
@@ -101,6 +103,20 @@ func DisplayISOInfo(i iso.ISO, verbose bool) {
 		fmt.Printf("Symbolic Links: %d\n", symlinks)
 		fmt.Printf("Root Directory Location: %d (LBA)\n", i.RootDirectoryLocation())
 
+		// Sessions of multisession images
+		if img, ok := i.(*iso9660.ISO9660); ok {
+			sessions, err := img.Sessions()
+			if err != nil {
+				fmt.Println("Failed to list sessions:", err)
+			}
+			if len(sessions) > 1 {
+				fmt.Println("\n--- Sessions ---")
+				for _, s := range sessions {
+					fmt.Printf("  Session %d: %s, sector %d, created %s\n", s.Number, s.VolumeID, s.StartSector, s.CreationTime.Format(time.RFC3339))
+				}
+			}
+		}
+
 		// Rock Ridge Support
 		if i.HasRockRidge() {
 			fmt.Println("\n--- Rock Ridge Extensions ---")
@@ -2,15 +2,22 @@ package iso
 
 import (
 	"errors"
+	"github.com/bgrewell/iso-kit/pkg/cdrom"
+	"github.com/bgrewell/iso-kit/pkg/ciso"
 	"github.com/bgrewell/iso-kit/pkg/consts"
 	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"github.com/bgrewell/iso-kit/pkg/httprange"
 	"github.com/bgrewell/iso-kit/pkg/iso9660"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/info"
 	"github.com/bgrewell/iso-kit/pkg/logging"
 	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/bgrewell/iso-kit/pkg/probe"
 	"github.com/bgrewell/iso-kit/pkg/udf"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -37,12 +44,17 @@ type ISO interface {
 	ListFiles() ([]*filesystem.FileSystemEntry, error)
 	ListDirectories() ([]*filesystem.FileSystemEntry, error)
 	ReadFile(path string) ([]byte, error)
+	Open(name string) (fs.File, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Stat(name string) (fs.FileInfo, error)
 	AddFile(path string, data []byte) error
 	RemoveFile(path string) error
+	PatchFile(w io.WriterAt, path string, data []byte) error
 	CreateDirectories(path string) error
 	Extract(path string) error
 
 	HasJoliet() bool
+	HasEnhanced() bool
 	HasRockRidge() bool
 	HasElTorito() bool
 
@@ -55,8 +67,29 @@ type ISO interface {
 	Close() error
 }
 
+// Open opens the image at filename. Besides images of 2048-byte sectors it reads CSO/ZSO compressed images, raw CD
+// images of 2352-byte or 2336-byte sectors, Nero NRG images, BIN/CUE images given the path of their cue sheet and
+// Alcohol 120% images given the path of their .mds descriptor or .mdf image file, through a translation to 2048-byte
+// sectors. The filesystem presented is chosen with option.WithFilesystem.
 func Open(filename string, opts ...option.OpenOption) (ISO, error) {
 
+	// Cue sheets and MDS descriptors describe the tracks of the image files next to them, read the filesystem of the
+	// first data track
+	track, container, err := openTrack(filename)
+	if err != nil {
+		return nil, err
+	}
+	if track != nil {
+		res := probe.Probe(track, track.Size())
+		res.Container = container
+		img, err := openFilesystem(track, res, opts...)
+		if err != nil {
+			track.Close()
+			return nil, err
+		}
+		return img, nil
+	}
+
 	// Get file info
 	fileInfo, err := os.Stat(filename)
 	if err != nil {
@@ -69,38 +102,182 @@ func Open(filename string, opts ...option.OpenOption) (ISO, error) {
 		return nil, err
 	}
 
-	// Check if file is large enough to be a valid ISO
-	if fileInfo.Size() < 16*consts.ISO9660_SECTOR_SIZE {
+	img, err := OpenReader(f, fileInfo.Size(), opts...)
+	if err != nil {
 		f.Close()
+		return nil, err
+	}
+	return img, nil
+}
+
+// OpenURL opens the image served at url by an HTTP server supporting Range requests. The image is fetched in
+// sector-aligned blocks as its volume descriptors, directories and files are read, so listing files or extracting one
+// of them downloads only the sectors they are recorded in. Images in any format Open detects from their content can
+// be read, cue sheets and MDS descriptors excepted.
+func OpenURL(url string, opts ...option.OpenOption) (ISO, error) {
+	var options option.OpenOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	r, err := httprange.NewReader(url, options.HTTPClient, options.HTTPBlockSize, options.HTTPCacheBlocks)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := OpenReader(r, r.Size(), opts...)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return img, nil
+}
+
+// OpenReader detects the format of the image of the given size read from r and opens its filesystem, probing for the
+// same formats as Open from the content of the image. CSO/ZSO compressed images, Nero images and raw CD images are read
+// through a reader presenting them as 2048-byte sectors. Images can be opened from memory, from an httprange.Reader or
+// from the reader returned by the Open method of a FileSystemEntry of another image. If r is an io.Closer it is closed
+// when the image is closed.
+func OpenReader(r io.ReaderAt, size int64, opts ...option.OpenOption) (ISO, error) {
+	sectors, res, err := detect(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if file is large enough to be a valid ISO
+	if res.Size < 16*consts.ISO9660_SECTOR_SIZE {
 		return nil, errors.New("file is too small to be a valid ISO9660 ISO")
 	}
+	return openFilesystem(sectors, res, opts...)
+}
 
-	// Read PVD header at sector 16 (offset 32768)
-	var header [6]byte
-	if _, err = f.ReadAt(header[:], 16*consts.ISO9660_SECTOR_SIZE); err != nil {
-		f.Close()
+// Probe reports the filesystems, boot structures and partition tables recorded in the image at filename, detecting
+// its format like Open.
+func Probe(filename string) (*probe.Result, error) {
+	track, container, err := openTrack(filename)
+	if err != nil {
 		return nil, err
 	}
+	if track != nil {
+		defer track.Close()
+		res := probe.Probe(track, track.Size())
+		res.Container = container
+		return res, nil
+	}
 
-	// Detect ISO9660
-	if string(header[1:6]) == consts.ISO9660_STD_IDENTIFIER {
-		return iso9660.Open(f, opts...)
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
+	defer f.Close()
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return ProbeReader(f, fileInfo.Size())
+}
 
-	// Check if file is large enough to be a valid UDF ISO
-	if fileInfo.Size() < 256*consts.UDF_SECTOR_SIZE {
-		f.Close()
-		return nil, errors.New("file is too small to be a valid ISO9660 or UDF ISO")
+// ProbeReader reports the filesystems, boot structures and partition tables recorded in the image of the given size
+// read from r, detecting its format like OpenReader.
+func ProbeReader(r io.ReaderAt, size int64) (*probe.Result, error) {
+	_, res, err := detect(r, size)
+	return res, err
+}
+
+// openTrack opens the first data track of the image described by the cue sheet or MDS descriptor at filename, given
+// directly or as the path of the .mdf file next to its descriptor. It returns a nil Reader for other files.
+func openTrack(filename string) (*cdrom.Reader, probe.Container, error) {
+	switch ext := filepath.Ext(filename); {
+	case strings.EqualFold(ext, ".cue"):
+		r, err := cdrom.OpenCueSheet(filename)
+		return r, probe.CONTAINER_BIN_CUE, err
+	case strings.EqualFold(ext, ".mds"):
+		r, err := cdrom.OpenMDS(filename)
+		return r, probe.CONTAINER_MDF_MDS, err
+	case strings.EqualFold(ext, ".mdf"):
+		for _, descExt := range []string{".mds", ".MDS"} {
+			if desc := strings.TrimSuffix(filename, ext) + descExt; fileExists(desc) {
+				r, err := cdrom.OpenMDS(desc)
+				return r, probe.CONTAINER_MDF_MDS, err
+			}
+		}
 	}
+	return nil, probe.CONTAINER_NONE, nil
+}
 
-	// Read UDF anchor volume descriptor at sector 256 (offset 524288)
-	if _, err = f.ReadAt(header[:], 256*consts.UDF_SECTOR_SIZE); err == nil {
-		if string(header[1:5]) == consts.UDF_STD_IDENTIFIER {
-			return udf.Open(f, opts...)
+// detect finds how the image of the given size is recorded and probes it. CSO/ZSO compressed images, Nero images and
+// raw CD images are read through a reader presenting them as 2048-byte sectors, which is returned with the result.
+// Images without a recognized filesystem are probed as they are.
+func detect(r io.ReaderAt, size int64) (io.ReaderAt, *probe.Result, error) {
+
+	// Compressed images are detected again once decompressed
+	if ciso.IsCompressed(r) {
+		c, err := ciso.NewReader(r, ciso.DEFAULT_CACHE_BLOCKS)
+		if err != nil {
+			return nil, nil, err
+		}
+		sectors, res, err := detect(c, c.Size())
+		if err == nil && res.Container == probe.CONTAINER_NONE {
+			res.Container = probe.CONTAINER_CSO
+			if c.Header().Magic == ciso.ZSO_MAGIC {
+				res.Container = probe.CONTAINER_ZSO
+			}
 		}
+		return sectors, res, err
+	}
+
+	// Detect ISO9660 and UDF from their volume recognition sequence at sector 16 (offset 32768)
+	res := probe.Probe(r, size)
+	if res.ISO9660 || res.HasUDF() {
+		return r, res, nil
 	}
 
-	return nil, errors.New("unsupported ISO format")
+	// Detect Nero images from the footer pointing at their track information
+	if nrg, err := cdrom.NewNRGReader(r, size); err == nil {
+		if nrgRes := probe.Probe(nrg, nrg.Size()); nrgRes.ISO9660 || nrgRes.HasUDF() {
+			nrgRes.Container = probe.CONTAINER_NRG
+			return nrg, nrgRes, nil
+		}
+	}
+
+	// Detect raw CD images from the sync pattern of their sectors
+	if raw, err := cdrom.NewReader(r, size); err == nil {
+		if rawRes := probe.Probe(raw, raw.Size()); rawRes.ISO9660 || rawRes.HasUDF() {
+			rawRes.Container = probe.CONTAINER_RAW
+			return raw, rawRes, nil
+		}
+	}
+
+	return r, res, nil
+}
+
+// openFilesystem opens the filesystem of the probed image selected with option.WithFilesystem, ISO9660 unless the
+// image only records a UDF filesystem by default.
+func openFilesystem(r io.ReaderAt, res *probe.Result, opts ...option.OpenOption) (ISO, error) {
+	var options option.OpenOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	switch {
+	case options.Filesystem == option.FILESYSTEM_UDF && !res.HasUDF():
+		return nil, errors.New("image does not hold a UDF filesystem")
+	case options.Filesystem == option.FILESYSTEM_UDF,
+		options.Filesystem == option.FILESYSTEM_AUTO && !res.ISO9660 && res.HasUDF():
+		return udf.Open(r, opts...)
+	case res.ISO9660:
+		return iso9660.Open(r, opts...)
+	case options.Filesystem == option.FILESYSTEM_ISO9660:
+		return nil, errors.New("image does not hold an ISO9660 filesystem")
+	default:
+		return nil, errors.New("unsupported ISO format")
+	}
+}
+
+// fileExists returns true if there is a file at path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
 }
 
 func Create(name string, opts ...option.CreateOption) (ISO, error) {
@@ -0,0 +1,363 @@
+package iso
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"github.com/bgrewell/iso-kit/pkg/cdrom"
+	"github.com/bgrewell/iso-kit/pkg/ciso"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/bgrewell/iso-kit/pkg/probe"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rawImage converts an image of 2048-byte sectors to raw 2352-byte sectors of the given mode.
+func rawImage(cooked []byte, mode cdrom.SectorMode) []byte {
+	bcd := func(v int) byte { return byte(v/10<<4 | v%10) }
+	var raw bytes.Buffer
+	for lba := 0; lba*consts.ISO9660_SECTOR_SIZE < len(cooked); lba++ {
+		sector := make([]byte, consts.CD_RAW_SECTOR_SIZE)
+		copy(sector, []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00})
+		frames := lba + 150
+		sector[12], sector[13], sector[14] = bcd(frames/75/60), bcd(frames/75%60), bcd(frames%75)
+		data := cooked[lba*consts.ISO9660_SECTOR_SIZE : (lba+1)*consts.ISO9660_SECTOR_SIZE]
+		if mode == cdrom.SECTOR_MODE_1 {
+			sector[15] = 1
+			copy(sector[16:], data)
+		} else {
+			sector[15] = 2
+			copy(sector[24:], data)
+		}
+		raw.Write(sector)
+	}
+	return raw.Bytes()
+}
+
+// cookedImage returns an image of 2048-byte sectors holding docs/readme.txt.
+func cookedImage(t *testing.T) []byte {
+	img, err := iso9660.Create("RAW_TEST", option.WithJolietEnabled(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/docs/readme.txt", []byte("hello raw sectors")))
+	return isotest.Bytes(t, img)
+}
+
+// requireReadme opens the image at path and checks the content of docs/readme.txt.
+func requireReadme(t *testing.T, path string) {
+	opened, err := Open(path, option.WithPreferJoliet(true))
+	require.NoError(t, err, path)
+	defer opened.Close()
+	require.Equal(t, "RAW_TEST", opened.GetVolumeID())
+	data, err := fs.ReadFile(opened.(fs.FS), "docs/readme.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello raw sectors", string(data))
+}
+
+func TestOpenRawImage(t *testing.T) {
+	cooked := cookedImage(t)
+	dir := t.TempDir()
+	audio := make([]byte, 10*consts.CD_RAW_SECTOR_SIZE)
+	for _, mode := range []cdrom.SectorMode{cdrom.SECTOR_MODE_1, cdrom.SECTOR_MODE_2_FORM_1} {
+		raw := rawImage(cooked, mode)
+		bin := filepath.Join(dir, "game.bin")
+		require.NoError(t, os.WriteFile(bin, append(raw, audio...), 0o644))
+
+		// The data track is followed by an audio track in the same file
+		cue := filepath.Join(dir, "game.cue")
+		sheet := `FILE "GAME.BIN" BINARY
+  TRACK 01 ` + map[cdrom.SectorMode]string{cdrom.SECTOR_MODE_1: "MODE1/2352", cdrom.SECTOR_MODE_2_FORM_1: "MODE2/2352"}[mode] + `
+    INDEX 01 00:00:00
+  TRACK 02 AUDIO
+    PREGAP 00:02:00
+    INDEX 01 ` + msf(len(raw)/consts.CD_RAW_SECTOR_SIZE) + `
+`
+		require.NoError(t, os.WriteFile(cue, []byte(sheet), 0o644))
+
+		requireReadme(t, cue)
+		requireReadme(t, bin)
+
+		// Offsets of the translated sectors are not offsets in the file, so the image cannot be patched
+		opened, err := Open(bin)
+		require.NoError(t, err)
+		w, err := os.OpenFile(bin, os.O_RDWR, 0)
+		require.NoError(t, err)
+		require.ErrorContains(t, opened.PatchFile(w, "/DOCS/README.TXT;1", []byte("patched")), "sector translation")
+		require.NoError(t, w.Close())
+		require.NoError(t, opened.Close())
+		requireReadme(t, bin)
+
+		f, err := os.Open(bin)
+		require.NoError(t, err)
+		r, err := cdrom.NewReader(f, int64(len(raw)+len(audio)))
+		require.NoError(t, err)
+		require.Equal(t, mode, r.Mode())
+		require.NoError(t, r.Close())
+	}
+
+	t.Run("cue sheet", func(t *testing.T) {
+		sheet, err := cdrom.ParseCueSheet(strings.NewReader("REM comment\nFILE \"a b.bin\" BINARY\n TRACK 01 AUDIO\n  INDEX 01 00:00:00\n TRACK 02 MODE1/2048\n  INDEX 00 01:00:00\n  INDEX 01 01:02:00\n"))
+		require.NoError(t, err)
+		file, track, err := sheet.DataTrack()
+		require.NoError(t, err)
+		require.Equal(t, "a b.bin", file.Name)
+		require.Equal(t, 2, track.Number)
+		require.Equal(t, 2048, track.SectorSize)
+		require.Equal(t, int64(62*75), track.Indexes[1])
+
+		_, err = cdrom.ParseCueSheet(strings.NewReader("TRACK 01 MODE1/2352\n"))
+		require.Error(t, err)
+	})
+}
+
+func TestOpenContainerImages(t *testing.T) {
+	raw := rawImage(cookedImage(t), cdrom.SECTOR_MODE_1)
+	sectors := len(raw) / consts.CD_RAW_SECTOR_SIZE
+	dir := t.TempDir()
+
+	t.Run("nrg", func(t *testing.T) {
+		// A disc at once image with the 150 sector pregap of the data track stored in front of it
+		pregap := int64(150 * consts.CD_RAW_SECTOR_SIZE)
+		image := append(make([]byte, pregap), raw...)
+		chunks := int64(len(image))
+		cues := []byte{0x41, 0x01, 0x00, 0, 0xFF, 0xFF, 0xFF, 0x6A, 0x41, 0x01, 0x01, 0, 0, 0, 0, 0}
+		image = append(image, append([]byte("CUEX\x00\x00\x00\x10"), cues...)...)
+		dao := make([]byte, 22+42)
+		dao[20], dao[21] = 1, 1
+		binary.BigEndian.PutUint16(dao[22+12:], consts.CD_RAW_SECTOR_SIZE)
+		dao[22+14] = 0x05
+		binary.BigEndian.PutUint64(dao[22+26:], uint64(pregap))
+		binary.BigEndian.PutUint64(dao[22+34:], uint64(pregap)+uint64(len(raw)))
+		image = binary.BigEndian.AppendUint32(append(image, "DAOX"...), uint32(len(dao)))
+		image = append(append(image, dao...), "END!\x00\x00\x00\x00"...)
+		image = binary.BigEndian.AppendUint64(append(image, cdrom.NRG_V2_SIGNATURE...), uint64(chunks))
+
+		path := filepath.Join(dir, "image.nrg")
+		require.NoError(t, os.WriteFile(path, image, 0o644))
+		requireReadme(t, path)
+
+		nrg, err := cdrom.ReadNRG(bytes.NewReader(image), int64(len(image)))
+		require.NoError(t, err)
+		require.Equal(t, 2, nrg.Version)
+		require.Len(t, nrg.Tracks, 1)
+		require.Equal(t, pregap, nrg.Tracks[0].Offset)
+		require.Equal(t, int64(0), nrg.Tracks[0].Start)
+	})
+
+	t.Run("cso", func(t *testing.T) {
+		cooked := cookedImage(t)
+		path := filepath.Join(dir, "image.cso")
+		out, err := os.Create(path)
+		require.NoError(t, err)
+		require.NoError(t, ciso.Compress(out, bytes.NewReader(cooked), int64(len(cooked)), flate.BestCompression))
+		require.NoError(t, out.Close())
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Less(t, info.Size(), int64(len(cooked)))
+		requireReadme(t, path)
+	})
+
+	t.Run("mds", func(t *testing.T) {
+		// Raw sectors followed by subchannel data, described by a lead-in block and a track block
+		var mdf []byte
+		for i := 0; i < sectors; i++ {
+			mdf = append(mdf, raw[i*consts.CD_RAW_SECTOR_SIZE:(i+1)*consts.CD_RAW_SECTOR_SIZE]...)
+			mdf = append(mdf, make([]byte, consts.CD_SUBCHANNEL_SIZE)...)
+		}
+		mds := make([]byte, 0x120)
+		copy(mds, cdrom.MDS_SIGNATURE)
+		mds[0x10], mds[0x11] = 1, 3
+		binary.LittleEndian.PutUint16(mds[0x14:], 1)
+		binary.LittleEndian.PutUint32(mds[0x50:], 0x58)
+		session := mds[0x58:]
+		binary.LittleEndian.PutUint16(session[0x08:], 1)
+		session[0x0A] = 2
+		binary.LittleEndian.PutUint32(session[0x14:], 0x70)
+		leadIn, track := mds[0x70:], mds[0xC0:]
+		leadIn[0x00], leadIn[0x04] = 0xAA, 0xA0
+		track[0x00], track[0x04] = 0xAA, 1
+		binary.LittleEndian.PutUint16(track[0x10:], consts.CD_RAW_SECTOR_SIZE+consts.CD_SUBCHANNEL_SIZE)
+		binary.LittleEndian.PutUint32(track[0x30:], 1)
+		binary.LittleEndian.PutUint32(track[0x34:], 0x110)
+		binary.LittleEndian.PutUint32(mds[0x110:], 0x120)
+		mds = append(mds, "*.mdf\x00"...)
+
+		path := filepath.Join(dir, "image.mds")
+		require.NoError(t, os.WriteFile(path, mds, 0o644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "image.mdf"), mdf, 0o644))
+		requireReadme(t, path)
+		requireReadme(t, filepath.Join(dir, "image.mdf"))
+
+		desc, err := cdrom.ParseMDS(mds)
+		require.NoError(t, err)
+		require.Len(t, desc.Sessions, 1)
+		require.Len(t, desc.Sessions[0].Tracks, 1)
+		require.Equal(t, "*.mdf", desc.Sessions[0].Tracks[0].File)
+	})
+}
+
+func TestOpenReader(t *testing.T) {
+	cooked := cookedImage(t)
+
+	t.Run("memory", func(t *testing.T) {
+		opened, err := OpenReader(bytes.NewReader(cooked), int64(len(cooked)), option.WithPreferJoliet(true))
+		require.NoError(t, err)
+		defer opened.Close()
+		data, err := fs.ReadFile(opened.(fs.FS), "docs/readme.txt")
+		require.NoError(t, err)
+		require.Equal(t, "hello raw sectors", string(data))
+	})
+
+	t.Run("filesystem", func(t *testing.T) {
+		raw := rawImage(cooked, cdrom.SECTOR_MODE_2_FORM_1)
+		res, err := ProbeReader(bytes.NewReader(raw), int64(len(raw)))
+		require.NoError(t, err)
+		require.Equal(t, probe.CONTAINER_RAW, res.Container)
+		require.True(t, res.ISO9660)
+		require.True(t, res.Joliet)
+		require.False(t, res.HasUDF())
+
+		_, err = OpenReader(bytes.NewReader(raw), int64(len(raw)), option.WithFilesystem(option.FILESYSTEM_UDF))
+		require.ErrorContains(t, err, "does not hold a UDF filesystem")
+		opened, err := OpenReader(bytes.NewReader(raw), int64(len(raw)), option.WithFilesystem(option.FILESYSTEM_ISO9660))
+		require.NoError(t, err)
+		require.NoError(t, opened.Close())
+	})
+
+	t.Run("nested", func(t *testing.T) {
+		// A raw image stored as a file of another image
+		outer, err := iso9660.Create("OUTER")
+		require.NoError(t, err)
+		require.NoError(t, outer.AddFile("/images/inner.bin", rawImage(cooked, cdrom.SECTOR_MODE_1)))
+		out := isotest.File(t, outer)
+
+		_, err = OpenReader(out, 0)
+		require.ErrorContains(t, err, "too small")
+		info, err := out.Stat()
+		require.NoError(t, err)
+		opened, err := OpenReader(out, info.Size())
+		require.NoError(t, err)
+		defer opened.Close()
+		files, err := opened.ListFiles()
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+
+		f, err := files[0].Open()
+		require.NoError(t, err)
+		inner, err := OpenReader(f, f.Size(), option.WithPreferJoliet(true))
+		require.NoError(t, err)
+		defer inner.Close()
+		require.Equal(t, "RAW_TEST", inner.GetVolumeID())
+		data, err := fs.ReadFile(inner.(fs.FS), "docs/readme.txt")
+		require.NoError(t, err)
+		require.Equal(t, "hello raw sectors", string(data))
+	})
+}
+
+func TestOpenURL(t *testing.T) {
+	img, err := iso9660.Create("HTTP_TEST", option.WithJolietEnabled(true),
+		option.WithBootEntry(option.BootEntry{ImagePath: "/boot/boot.img"}))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/boot/boot.img", bytes.Repeat([]byte{0xEB}, 2048)))
+	require.NoError(t, img.AddFile("/docs/readme.txt", []byte("hello over http")))
+	require.NoError(t, img.AddFile("/data/large.bin", bytes.Repeat([]byte("large file content "), 1<<18)))
+	image := isotest.Bytes(t, img)
+
+	var requests int
+	var fetched int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests++
+		start, end, _ := strings.Cut(strings.TrimPrefix(req.Header.Get("Range"), "bytes="), "-")
+		first, _ := strconv.ParseInt(start, 10, 64)
+		last, _ := strconv.ParseInt(end, 10, 64)
+		fetched += last - first + 1
+		http.ServeContent(w, req, "image.iso", time.Time{}, bytes.NewReader(image))
+	}))
+	defer server.Close()
+
+	opened, err := OpenURL(server.URL+"/image.iso", option.WithHTTPClient(server.Client()),
+		option.WithPreferJoliet(true))
+	require.NoError(t, err)
+	defer opened.Close()
+	require.Equal(t, "HTTP_TEST", opened.GetVolumeID())
+
+	files, err := opened.ListFiles()
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	require.True(t, opened.HasElTorito())
+	boot, err := opened.ListBootEntries()
+	require.NoError(t, err)
+	require.Len(t, boot, 1)
+	data, err := opened.ReadFile("docs/readme.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello over http", string(data))
+
+	// The sectors of the large file are never fetched
+	require.Greater(t, len(image), 4<<20)
+	require.Less(t, fetched, int64(1<<20))
+	require.Less(t, requests, 10)
+}
+
+func TestOpenForm2Files(t *testing.T) {
+	img, err := iso9660.Create("VCD_TEST")
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/VIDEO.DAT", make([]byte, 2*consts.ISO9660_SECTOR_SIZE)))
+	cooked := isotest.Bytes(t, img)
+
+	// Record the XA information of a Form 2 file after the directory record, the last of the root directory
+	opened, err := iso9660.Open(bytes.NewReader(cooked))
+	require.NoError(t, err)
+	files, err := opened.ListFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	record := files[0].DirectoryRecord()
+	xa := []byte{0, 0, 0, 0, 0x15, 0x55, 'X', 'A', 1, 0, 0, 0, 0, 0}
+	end := int(record.ObjectLocation) + int(cooked[record.ObjectLocation])
+	copy(cooked[end:], xa)
+	cooked[record.ObjectLocation] += byte(len(xa))
+
+	// Store the file in Form 2 sectors holding 2324 bytes of user data each
+	raw := rawImage(cooked, cdrom.SECTOR_MODE_2_FORM_1)
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 2*consts.CD_FORM2_DATA_SIZE/16+1)[:2*consts.CD_FORM2_DATA_SIZE]
+	for i := 0; i < 2; i++ {
+		sector := raw[(int(record.LocationOfExtent)+i)*consts.CD_RAW_SECTOR_SIZE:]
+		sector[18], sector[22] = 0x20, 0x20
+		copy(sector[24:24+consts.CD_FORM2_DATA_SIZE], payload[i*consts.CD_FORM2_DATA_SIZE:])
+	}
+	bin := filepath.Join(t.TempDir(), "vcd.bin")
+	require.NoError(t, os.WriteFile(bin, raw, 0o644))
+
+	image, err := Open(bin)
+	require.NoError(t, err)
+	defer image.Close()
+	files, err = image.ListFiles()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.NotNil(t, files[0].XA)
+	require.True(t, files[0].XA.IsForm2())
+	require.Equal(t, uint8(1), files[0].XA.FileNumber)
+	require.Equal(t, os.FileMode(0o555), files[0].XA.Permissions())
+	require.Nil(t, files[0].DirectoryRecord().RockRidge)
+	data, err := files[0].GetBytes()
+	require.NoError(t, err)
+	require.Equal(t, payload, data)
+}
+
+// msf formats a number of sectors as an mm:ss:ff position.
+func msf(sectors int) string {
+	b := []byte("00:00:00")
+	for i, v := range []int{sectors / 75 / 60, sectors / 75 % 60, sectors % 75} {
+		b[i*3], b[i*3+1] = byte('0'+v/10), byte('0'+v%10)
+	}
+	return string(b)
+}
@@ -0,0 +1,277 @@
+package cdrom
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CueSheet describes the tracks of a disc image stored in one or more files, as written by CD burning and dumping
+// tools next to BIN images.
+type CueSheet struct {
+	Files []*CueFile
+}
+
+// CueFile is a file of a disc image and the tracks stored in it.
+type CueFile struct {
+	// Name of the file, relative to the directory of the cue sheet
+	Name string
+	// Format of the file: BINARY, MOTOROLA, AIFF, WAVE or MP3
+	Type   string
+	Tracks []*CueTrack
+}
+
+// CueTrack is a track of a disc image.
+type CueTrack struct {
+	Number int
+	// Datatype of the track such as MODE1/2352, MODE2/2352, MODE2/2336, MODE1/2048 or AUDIO
+	Type string
+	// Size of the sectors of the track in the file
+	SectorSize int
+	// Position of each index of the track in the file, in sectors. Index 1 starts the track and index 0 its pregap.
+	Indexes map[int]int64
+}
+
+// IsData returns true if the track holds data rather than audio.
+func (t *CueTrack) IsData() bool {
+	return t.Type != "AUDIO" && t.Type != "CDG"
+}
+
+// firstIndex returns the position of the lowest index of the track, where its data starts in the file.
+func (t *CueTrack) firstIndex() int64 {
+	numbers := make([]int, 0, len(t.Indexes))
+	for number := range t.Indexes {
+		numbers = append(numbers, number)
+	}
+	return t.Indexes[slices.Min(numbers)]
+}
+
+// ParseCueSheet reads a cue sheet. Commands other than FILE, TRACK and INDEX are ignored.
+func ParseCueSheet(r io.Reader) (*CueSheet, error) {
+	sheet := &CueSheet{}
+	var file *CueFile
+	var track *CueTrack
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := cueFields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "FILE":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: FILE needs a name and a type", line)
+			}
+			file = &CueFile{Name: fields[1], Type: strings.ToUpper(fields[2])}
+			sheet.Files = append(sheet.Files, file)
+			track = nil
+		case "TRACK":
+			if file == nil {
+				return nil, fmt.Errorf("line %d: TRACK before FILE", line)
+			}
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: TRACK needs a number and a datatype", line)
+			}
+			number, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid track number: %w", line, err)
+			}
+			datatype := strings.ToUpper(fields[2])
+			size, err := sectorSizeOf(datatype)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			track = &CueTrack{Number: number, Type: datatype, SectorSize: size, Indexes: make(map[int]int64)}
+			file.Tracks = append(file.Tracks, track)
+		case "INDEX":
+			if track == nil {
+				return nil, fmt.Errorf("line %d: INDEX before TRACK", line)
+			}
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("line %d: INDEX needs a number and a position", line)
+			}
+			number, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid index number: %w", line, err)
+			}
+			position, err := parseMSF(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			track.Indexes[number] = position
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, f := range sheet.Files {
+		for _, t := range f.Tracks {
+			if _, ok := t.Indexes[1]; !ok {
+				return nil, fmt.Errorf("track %d has no INDEX 01", t.Number)
+			}
+		}
+	}
+	return sheet, nil
+}
+
+// DataTrack returns the first data track of the cue sheet and the file holding it.
+func (c *CueSheet) DataTrack() (*CueFile, *CueTrack, error) {
+	for _, file := range c.Files {
+		for _, track := range file.Tracks {
+			if track.IsData() {
+				return file, track, nil
+			}
+		}
+	}
+	return nil, nil, errors.New("cue sheet has no data track")
+}
+
+// trackBounds returns the byte offset of index 1 of a track in a file of the given size and the byte offset where the
+// track ends, which is the start of the next track or the end of the file.
+func (f *CueFile) trackBounds(track *CueTrack, size int64) (int64, int64) {
+	var offset, start int64
+	position, sectorSize := int64(0), f.Tracks[0].SectorSize
+	for i, t := range f.Tracks {
+		first := t.firstIndex()
+		offset += (first - position) * int64(sectorSize)
+		position, sectorSize = first, t.SectorSize
+		if t == track {
+			start = offset + (t.Indexes[1]-first)*int64(sectorSize)
+			if i+1 < len(f.Tracks) {
+				return start, offset + (f.Tracks[i+1].firstIndex()-first)*int64(sectorSize)
+			}
+			break
+		}
+	}
+	return start, size
+}
+
+// OpenCueSheet parses the cue sheet at path and returns a Reader for its first data track. Closing the Reader closes
+// the file holding the track.
+func OpenCueSheet(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sheet, err := ParseCueSheet(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cue sheet: %w", err)
+	}
+
+	file, track, err := sheet.DataTrack()
+	if err != nil {
+		return nil, err
+	}
+	if file.Type != "BINARY" {
+		return nil, fmt.Errorf("data track %d is stored in a %s file", track.Number, file.Type)
+	}
+
+	bin, err := os.Open(resolveImageFile(filepath.Dir(path), file.Name))
+	if err != nil {
+		return nil, err
+	}
+	info, err := bin.Stat()
+	if err != nil {
+		bin.Close()
+		return nil, err
+	}
+	start, end := file.trackBounds(track, info.Size())
+	reader, err := NewTrackReader(bin, start, end-start, track.SectorSize)
+	if err != nil {
+		bin.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// resolveImageFile returns the path of an image file named by a cue sheet or descriptor in dir. They are often written
+// on systems with other path separators or case rules, so when the file does not exist a file with the same base name
+// in dir, ignoring case, is used instead.
+func resolveImageFile(dir, name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	candidate := filepath.Join(dir, filepath.FromSlash(name))
+	if _, err := os.Stat(candidate); err == nil {
+		return candidate
+	}
+	base := filepath.Base(filepath.FromSlash(name))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return candidate
+	}
+	for _, entry := range entries {
+		if strings.EqualFold(entry.Name(), base) {
+			return filepath.Join(dir, entry.Name())
+		}
+	}
+	return candidate
+}
+
+// sectorSizeOf returns the size of the sectors of a track datatype.
+func sectorSizeOf(datatype string) (int, error) {
+	switch datatype {
+	case "AUDIO":
+		return consts.CD_RAW_SECTOR_SIZE, nil
+	case "CDG":
+		return consts.CD_RAW_SECTOR_SIZE + consts.CD_SUBCHANNEL_SIZE, nil
+	}
+	if _, size, ok := strings.Cut(datatype, "/"); ok {
+		if n, err := strconv.Atoi(size); err == nil && n > 0 {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported track datatype %q", datatype)
+}
+
+// parseMSF converts an mm:ss:ff position to a number of sectors.
+func parseMSF(msf string) (int64, error) {
+	parts := strings.Split(msf, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid position %q", msf)
+	}
+	var values [3]int64
+	for i, part := range parts {
+		v, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid position %q", msf)
+		}
+		values[i] = v
+	}
+	if values[1] >= 60 || values[2] >= consts.CD_FRAMES_PER_SECOND {
+		return 0, fmt.Errorf("invalid position %q", msf)
+	}
+	return (values[0]*60+values[1])*consts.CD_FRAMES_PER_SECOND + values[2], nil
+}
+
+// cueFields splits a cue sheet line into fields, keeping double-quoted strings together.
+func cueFields(line string) []string {
+	var fields []string
+	line = strings.TrimSpace(line)
+	for line != "" {
+		var field string
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				field, line = line[1:], ""
+			} else {
+				field, line = line[1:end+1], line[end+2:]
+			}
+		} else if end := strings.IndexAny(line, " \t"); end >= 0 {
+			field, line = line[:end], line[end:]
+		} else {
+			field, line = line, ""
+		}
+		fields = append(fields, field)
+		line = strings.TrimLeft(line, " \t")
+	}
+	return fields
+}
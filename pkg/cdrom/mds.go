@@ -0,0 +1,200 @@
+package cdrom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// MDS_SIGNATURE starts the descriptor of Alcohol 120% images
+	MDS_SIGNATURE = "MEDIA DESCRIPTOR"
+
+	// Sizes of the header, session blocks, track blocks and filename blocks of a descriptor
+	mdsHeaderSize        = 0x58
+	mdsSessionBlockSize  = 24
+	mdsTrackBlockSize    = 80
+	mdsFilenameBlockSize = 16
+	// Track block points from A0 on describe the lead-in rather than a track
+	mdsFirstLeadInPoint = 0xA0
+	// Track modes, in the low bits of the mode byte of a track block
+	mdsModeNone  = 0
+	mdsModeAudio = 1
+)
+
+// MDSDescriptor describes the sessions and tracks of an Alcohol 120% image, read from its .mds file.
+type MDSDescriptor struct {
+	// Version of the descriptor format as major and minor numbers
+	Version  [2]byte
+	Sessions []MDSSession
+}
+
+// MDSSession is a session of an Alcohol 120% image.
+type MDSSession struct {
+	Number int
+	// Sector addresses of the start and end of the session
+	Start int64
+	End   int64
+	// Tracks of the session in the image files
+	Tracks []MDSTrack
+}
+
+// MDSTrack is a track of an Alcohol 120% image.
+type MDSTrack struct {
+	Track
+	// Name of the image file holding the track. A name starting with '*', usually "*.mdf", stands for the name of the
+	// descriptor followed by the rest of the name.
+	File string
+}
+
+// ParseMDS decodes the content of an .mds descriptor. Tracks without a recorded length have a Length of zero, meaning
+// they extend to the end of their image file.
+func ParseMDS(data []byte) (*MDSDescriptor, error) {
+	if len(data) < mdsHeaderSize || string(data[:len(MDS_SIGNATURE)]) != MDS_SIGNATURE {
+		return nil, errors.New("not an MDS descriptor")
+	}
+	desc := &MDSDescriptor{Version: [2]byte{data[0x10], data[0x11]}}
+	sessionCount := int(binary.LittleEndian.Uint16(data[0x14:0x16]))
+	sessionsOffset := int(binary.LittleEndian.Uint32(data[0x50:0x54]))
+
+	for i := 0; i < sessionCount; i++ {
+		block, err := mdsBlock(data, sessionsOffset+i*mdsSessionBlockSize, mdsSessionBlockSize)
+		if err != nil {
+			return nil, fmt.Errorf("session %d: %w", i+1, err)
+		}
+		session := MDSSession{
+			Number: int(binary.LittleEndian.Uint16(block[0x08:0x0A])),
+			Start:  int64(int32(binary.LittleEndian.Uint32(block[0x00:0x04]))),
+			End:    int64(int32(binary.LittleEndian.Uint32(block[0x04:0x08]))),
+		}
+		blockCount := int(block[0x0A])
+		tracksOffset := int(binary.LittleEndian.Uint32(block[0x14:0x18]))
+
+		for j := 0; j < blockCount; j++ {
+			tb, err := mdsBlock(data, tracksOffset+j*mdsTrackBlockSize, mdsTrackBlockSize)
+			if err != nil {
+				return nil, fmt.Errorf("session %d track block %d: %w", session.Number, j, err)
+			}
+			mode, point := tb[0x00]&0x07, tb[0x04]
+			if point >= mdsFirstLeadInPoint || mode == mdsModeNone {
+				continue
+			}
+			track := MDSTrack{Track: Track{
+				Number:     int(point),
+				Audio:      mode == mdsModeAudio,
+				SectorSize: int(binary.LittleEndian.Uint16(tb[0x10:0x12])),
+				Start:      int64(binary.LittleEndian.Uint32(tb[0x24:0x28])),
+				Offset:     int64(binary.LittleEndian.Uint64(tb[0x28:0x30])),
+			}}
+
+			// The extra block gives the pregap and length of the track in sectors
+			if extraOffset := int(binary.LittleEndian.Uint32(tb[0x0C:0x10])); extraOffset != 0 {
+				if extra, err := mdsBlock(data, extraOffset, 8); err == nil {
+					track.Length = int64(binary.LittleEndian.Uint32(extra[4:8])) * int64(track.SectorSize)
+				}
+			}
+
+			if binary.LittleEndian.Uint32(tb[0x30:0x34]) > 0 {
+				name, err := mdsFilename(data, int(binary.LittleEndian.Uint32(tb[0x34:0x38])))
+				if err != nil {
+					return nil, fmt.Errorf("track %d: %w", track.Number, err)
+				}
+				track.File = name
+			}
+			session.Tracks = append(session.Tracks, track)
+		}
+		desc.Sessions = append(desc.Sessions, session)
+	}
+	return desc, nil
+}
+
+// OpenMDS parses the .mds descriptor at path and returns a Reader for the first data track, read from the image file
+// the descriptor names. Closing the Reader closes the image file.
+func OpenMDS(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := ParseMDS(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []Track
+	files := make(map[int]string)
+	for _, session := range desc.Sessions {
+		for _, track := range session.Tracks {
+			tracks = append(tracks, track.Track)
+			files[track.Number] = track.File
+		}
+	}
+	track, err := dataTrack(tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	name := files[track.Number]
+	if name == "" || strings.HasPrefix(name, "*") {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)) + strings.TrimPrefix(name, "*")
+	}
+	mdf, err := os.Open(resolveImageFile(filepath.Dir(path), name))
+	if err != nil {
+		return nil, err
+	}
+	if track.Length == 0 {
+		info, err := mdf.Stat()
+		if err != nil {
+			mdf.Close()
+			return nil, err
+		}
+		track.Length = info.Size() - track.Offset
+	}
+	reader, err := NewTrackReader(mdf, track.Offset, track.Length, track.SectorSize)
+	if err != nil {
+		mdf.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// mdsBlock returns size bytes of the descriptor starting at offset.
+func mdsBlock(data []byte, offset, size int) ([]byte, error) {
+	if offset <= 0 || offset+size > len(data) {
+		return nil, fmt.Errorf("block at offset %d is outside the descriptor", offset)
+	}
+	return data[offset : offset+size], nil
+}
+
+// mdsFilename decodes the name referenced by a filename block, recorded as a NUL terminated string of bytes or, when
+// the format byte is set, of UTF-16 little-endian code units.
+func mdsFilename(data []byte, offset int) (string, error) {
+	block, err := mdsBlock(data, offset, mdsFilenameBlockSize)
+	if err != nil {
+		return "", err
+	}
+	nameOffset := int(binary.LittleEndian.Uint32(block[0:4]))
+	if nameOffset <= 0 || nameOffset >= len(data) {
+		return "", fmt.Errorf("filename at offset %d is outside the descriptor", nameOffset)
+	}
+	name := data[nameOffset:]
+	if block[4] == 0 {
+		if end := strings.IndexByte(string(name), 0); end >= 0 {
+			name = name[:end]
+		}
+		return string(name), nil
+	}
+
+	var units []uint16
+	for i := 0; i+1 < len(name); i += 2 {
+		unit := binary.LittleEndian.Uint16(name[i : i+2])
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units)), nil
+}
@@ -0,0 +1,221 @@
+package cdrom
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"io"
+)
+
+const (
+	// Signatures of the footer of Nero images: NERO is followed by a 32-bit chunk offset in version 1 images and NER5
+	// by a 64-bit chunk offset in version 2 images
+	NRG_V1_SIGNATURE = "NERO"
+	NRG_V2_SIGNATURE = "NER5"
+
+	// Largest chunk area read from the end of a Nero image
+	nrgMaxChunkArea = 16 * 1024 * 1024
+	// Size of the header of a DAO chunk and of its track blocks in version 1 and version 2 images
+	nrgDAOHeaderSize  = 22
+	nrgDAOV1BlockSize = 30
+	nrgDAOV2BlockSize = 42
+	// Size of the track entries of a TAO chunk in version 1 and version 2 images
+	nrgTAOV1EntrySize = 20
+	nrgTAOV2EntrySize = 32
+	// Size of the entries of a cue chunk
+	nrgCueEntrySize = 8
+)
+
+// Nero track modes, giving the sector size of the track in the image
+var nrgModes = map[uint32]struct {
+	sectorSize int
+	audio      bool
+}{
+	0x00: {consts.ISO9660_SECTOR_SIZE, false},                            // Mode 1
+	0x02: {consts.ISO9660_SECTOR_SIZE, false},                            // Mode 2 Form 1
+	0x03: {consts.CD_MODE2_SECTOR_SIZE, false},                           // Mode 2
+	0x05: {consts.CD_RAW_SECTOR_SIZE, false},                             // Raw Mode 1
+	0x06: {consts.CD_RAW_SECTOR_SIZE, false},                             // Raw Mode 2
+	0x07: {consts.CD_RAW_SECTOR_SIZE, true},                              // Audio
+	0x0F: {consts.CD_RAW_SECTOR_SIZE + consts.CD_SUBCHANNEL_SIZE, false}, // Raw Mode 1 with subchannel
+	0x10: {consts.CD_RAW_SECTOR_SIZE + consts.CD_SUBCHANNEL_SIZE, true},  // Audio with subchannel
+	0x11: {consts.CD_RAW_SECTOR_SIZE + consts.CD_SUBCHANNEL_SIZE, false}, // Raw Mode 2 with subchannel
+}
+
+// NRGImage describes the tracks of a Nero image, read from the chunks its footer points at.
+type NRGImage struct {
+	// Version of the image format, 1 for NERO footers and 2 for NER5 footers
+	Version int
+	Tracks  []Track
+}
+
+// ReadNRG reads the footer and chunks of a Nero image of the given size. Tracks are located by DAOI/DAOX (disc at
+// once) and ETNF/ETN2 (track at once) chunks, and their start addresses taken from CUES/CUEX chunks.
+func ReadNRG(r io.ReaderAt, size int64) (*NRGImage, error) {
+	if size < 12 {
+		return nil, errors.New("not an NRG image")
+	}
+	footer := make([]byte, 12)
+	if _, err := r.ReadAt(footer, size-12); err != nil {
+		return nil, err
+	}
+
+	img := &NRGImage{}
+	var first int64
+	switch {
+	case string(footer[0:4]) == NRG_V2_SIGNATURE:
+		img.Version, first = 2, int64(binary.BigEndian.Uint64(footer[4:12]))
+	case string(footer[4:8]) == NRG_V1_SIGNATURE:
+		img.Version, first = 1, int64(binary.BigEndian.Uint32(footer[8:12]))
+	default:
+		return nil, errors.New("not an NRG image")
+	}
+	if first < 0 || first >= size || size-first > nrgMaxChunkArea {
+		return nil, fmt.Errorf("invalid NRG chunk offset %d", first)
+	}
+
+	chunks := make([]byte, size-first)
+	if _, err := r.ReadAt(chunks, first); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read NRG chunks: %w", err)
+	}
+
+	starts := make(map[int]int64)
+	for offset := 0; offset+8 <= len(chunks); {
+		id := string(chunks[offset : offset+4])
+		length := int(binary.BigEndian.Uint32(chunks[offset+4 : offset+8]))
+		if id == "END!" {
+			break
+		}
+		if length < 0 || offset+8+length > len(chunks) {
+			return nil, fmt.Errorf("NRG chunk %s is truncated", id)
+		}
+		body := chunks[offset+8 : offset+8+length]
+		switch id {
+		case "CUEX", "CUES":
+			parseNRGCues(body, id == "CUEX", starts)
+		case "DAOX", "DAOI":
+			tracks, err := parseNRGDAO(body, id == "DAOX")
+			if err != nil {
+				return nil, err
+			}
+			img.Tracks = append(img.Tracks, tracks...)
+		case "ETN2", "ETNF":
+			tracks, err := parseNRGTAO(body, id == "ETN2", len(img.Tracks)+1)
+			if err != nil {
+				return nil, err
+			}
+			img.Tracks = append(img.Tracks, tracks...)
+		}
+		offset += 8 + length
+	}
+
+	if len(img.Tracks) == 0 {
+		return nil, errors.New("NRG image has no track information")
+	}
+	for i := range img.Tracks {
+		if start, ok := starts[img.Tracks[i].Number]; ok {
+			img.Tracks[i].Start = start
+		}
+	}
+	return img, nil
+}
+
+// NewNRGReader returns a Reader for the first data track of a Nero image of the given size. If r is an io.Closer it
+// is closed by Close.
+func NewNRGReader(r io.ReaderAt, size int64) (*Reader, error) {
+	img, err := ReadNRG(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return openDataTrack(r, img.Tracks)
+}
+
+// parseNRGDAO decodes the track blocks of a disc at once chunk, numbered from the first track given by its header.
+func parseNRGDAO(body []byte, v2 bool) ([]Track, error) {
+	if len(body) < nrgDAOHeaderSize {
+		return nil, errors.New("NRG DAO chunk is truncated")
+	}
+	blockSize := nrgDAOV1BlockSize
+	if v2 {
+		blockSize = nrgDAOV2BlockSize
+	}
+
+	var tracks []Track
+	number := int(body[20])
+	for offset := nrgDAOHeaderSize; offset+blockSize <= len(body); offset += blockSize {
+		block := body[offset : offset+blockSize]
+		var start, end int64
+		if v2 {
+			start, end = int64(binary.BigEndian.Uint64(block[26:34])), int64(binary.BigEndian.Uint64(block[34:42]))
+		} else {
+			start, end = int64(binary.BigEndian.Uint32(block[22:26])), int64(binary.BigEndian.Uint32(block[26:30]))
+		}
+		if end < start {
+			return nil, fmt.Errorf("NRG track %d ends before it starts", number)
+		}
+		mode := nrgModes[uint32(block[14])]
+		tracks = append(tracks, Track{
+			Number:     number,
+			Audio:      mode.audio,
+			SectorSize: int(binary.BigEndian.Uint16(block[12:14])),
+			Offset:     start,
+			Length:     end - start,
+		})
+		number++
+	}
+	return tracks, nil
+}
+
+// parseNRGTAO decodes the track entries of a track at once chunk, numbering the tracks from number.
+func parseNRGTAO(body []byte, v2 bool, number int) ([]Track, error) {
+	entrySize := nrgTAOV1EntrySize
+	if v2 {
+		entrySize = nrgTAOV2EntrySize
+	}
+
+	var tracks []Track
+	for offset := 0; offset+entrySize <= len(body); offset += entrySize {
+		entry := body[offset : offset+entrySize]
+		track := Track{Number: number}
+		var code uint32
+		if v2 {
+			track.Offset, track.Length = int64(binary.BigEndian.Uint64(entry[0:8])), int64(binary.BigEndian.Uint64(entry[8:16]))
+			code, track.Start = binary.BigEndian.Uint32(entry[16:20]), int64(binary.BigEndian.Uint32(entry[20:24]))
+		} else {
+			track.Offset, track.Length = int64(binary.BigEndian.Uint32(entry[0:4])), int64(binary.BigEndian.Uint32(entry[4:8]))
+			code, track.Start = binary.BigEndian.Uint32(entry[8:12]), int64(binary.BigEndian.Uint32(entry[12:16]))
+		}
+		mode, ok := nrgModes[code]
+		if !ok {
+			return nil, fmt.Errorf("NRG track %d has unknown mode %#x", number, code)
+		}
+		track.SectorSize, track.Audio = mode.sectorSize, mode.audio
+		tracks = append(tracks, track)
+		number++
+	}
+	return tracks, nil
+}
+
+// parseNRGCues records the sector address of index 1 of every track listed in a cue chunk. Version 2 images record
+// addresses as logical sectors, version 1 images as BCD coded MSF positions.
+func parseNRGCues(body []byte, v2 bool, starts map[int]int64) {
+	for offset := 0; offset+nrgCueEntrySize <= len(body); offset += nrgCueEntrySize {
+		entry := body[offset : offset+nrgCueEntrySize]
+		// Track 0 is the lead-in and track AA the lead-out
+		if entry[1] == 0 || entry[1] == 0xAA || bcd(entry[2]) != 1 {
+			continue
+		}
+		if v2 {
+			starts[bcd(entry[1])] = int64(int32(binary.BigEndian.Uint32(entry[4:8])))
+		} else {
+			frames := (bcd(entry[5])*60+bcd(entry[6]))*consts.CD_FRAMES_PER_SECOND + bcd(entry[7])
+			starts[bcd(entry[1])] = int64(frames - 2*consts.CD_FRAMES_PER_SECOND)
+		}
+	}
+}
+
+// bcd decodes a binary coded decimal byte.
+func bcd(b byte) int {
+	return int(b>>4)*10 + int(b&0x0F)
+}
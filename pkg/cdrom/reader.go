@@ -0,0 +1,260 @@
+package cdrom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"io"
+)
+
+// SectorMode identifies how the user data of a CD sector is laid out.
+type SectorMode int
+
+const (
+	// SECTOR_MODE_COOKED is a 2048-byte sector holding only user data, as stored in an ISO image
+	SECTOR_MODE_COOKED SectorMode = iota
+	// SECTOR_MODE_1 holds 2048 bytes of user data protected by EDC/ECC
+	SECTOR_MODE_1
+	// SECTOR_MODE_2 holds 2336 bytes of user data without a subheader (formless Mode 2)
+	SECTOR_MODE_2
+	// SECTOR_MODE_2_FORM_1 is a CD-ROM XA sector holding 2048 bytes of user data protected by EDC/ECC
+	SECTOR_MODE_2_FORM_1
+	// SECTOR_MODE_2_FORM_2 is a CD-ROM XA sector holding 2324 bytes of user data, used for audio and video
+	SECTOR_MODE_2_FORM_2
+)
+
+// String returns the name of the sector mode.
+func (m SectorMode) String() string {
+	switch m {
+	case SECTOR_MODE_COOKED:
+		return "Cooked"
+	case SECTOR_MODE_1:
+		return "Mode 1"
+	case SECTOR_MODE_2:
+		return "Mode 2"
+	case SECTOR_MODE_2_FORM_1:
+		return "Mode 2 Form 1"
+	case SECTOR_MODE_2_FORM_2:
+		return "Mode 2 Form 2"
+	default:
+		return fmt.Sprintf("SectorMode(%d)", int(m))
+	}
+}
+
+const (
+	// Size of the sync pattern and header of a raw sector
+	rawHeaderSize = 16
+	// Size of the CD-ROM XA subheader, recorded twice, in front of the user data of a Mode 2 sector
+	subheaderSize = 8
+	// Submode bit of the subheader marking a Form 2 sector
+	submodeForm2 = 0x20
+	// Number of raw sectors read from the underlying reader at once
+	readAheadSectors = 32
+)
+
+// syncPattern starts every raw data sector.
+var syncPattern = []byte{0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00}
+
+// DetectSectorMode returns the mode of a raw 2352-byte sector from its sync pattern and header. It returns false if
+// the sector does not start with a sync pattern.
+func DetectSectorMode(sector []byte) (SectorMode, bool) {
+	if len(sector) < rawHeaderSize+subheaderSize || !bytes.Equal(sector[:len(syncPattern)], syncPattern) {
+		return SECTOR_MODE_1, false
+	}
+	switch sector[15] {
+	case 1:
+		return SECTOR_MODE_1, true
+	case 2:
+		return mode2Form(sector[rawHeaderSize:]), true
+	default:
+		return SECTOR_MODE_1, false
+	}
+}
+
+// mode2Form returns the form of a Mode 2 sector from the subheader at the start of its data. Sectors without two
+// identical copies of the subheader are formless.
+func mode2Form(data []byte) SectorMode {
+	if !bytes.Equal(data[:subheaderSize/2], data[subheaderSize/2:subheaderSize]) {
+		return SECTOR_MODE_2
+	}
+	if data[2]&submodeForm2 != 0 {
+		return SECTOR_MODE_2_FORM_2
+	}
+	return SECTOR_MODE_2_FORM_1
+}
+
+// userData returns the user data of a sector stored in sectorSize bytes together with its mode. Sectors of a raw
+// image without a sync pattern, such as unrecorded ones, are read as Mode 1.
+func userData(sector []byte, sectorSize int) ([]byte, SectorMode) {
+	switch sectorSize {
+	case consts.CD_RAW_SECTOR_SIZE, consts.CD_RAW_SECTOR_SIZE + consts.CD_SUBCHANNEL_SIZE:
+		mode, _ := DetectSectorMode(sector)
+		return payload(sector[rawHeaderSize:], mode), mode
+	case consts.CD_MODE2_SECTOR_SIZE:
+		mode := mode2Form(sector)
+		return payload(sector, mode), mode
+	default:
+		return sector, SECTOR_MODE_COOKED
+	}
+}
+
+// payload returns the user data of a sector from the data following its header.
+func payload(data []byte, mode SectorMode) []byte {
+	switch mode {
+	case SECTOR_MODE_2:
+		return data[:consts.CD_MODE2_SECTOR_SIZE]
+	case SECTOR_MODE_2_FORM_1:
+		return data[subheaderSize : subheaderSize+consts.ISO9660_SECTOR_SIZE]
+	case SECTOR_MODE_2_FORM_2:
+		return data[subheaderSize : subheaderSize+consts.CD_FORM2_DATA_SIZE]
+	default:
+		return data[:consts.ISO9660_SECTOR_SIZE]
+	}
+}
+
+// Reader is an io.ReaderAt presenting a data track of a CD image as 2048-byte sectors, so the ISO9660 parser can read
+// images made of raw 2352-byte or Mode 2 2336-byte sectors. Sector n of the view is sector n of the track; for Form 2
+// and formless Mode 2 sectors it holds the first 2048 bytes of their user data, which ReadSector returns in full.
+type Reader struct {
+	r          io.ReaderAt
+	closer     io.Closer
+	start      int64
+	sectorSize int
+	sectors    int64
+	mode       SectorMode
+}
+
+// NewReader detects the sector size and mode of a raw CD image of the given size and returns a Reader for it. Raw
+// sectors are recognized by their sync pattern and Mode 2 sectors stored without one by the volume descriptor at
+// sector 16. If r is an io.Closer it is closed by Close.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	first := make([]byte, consts.CD_RAW_SECTOR_SIZE)
+	if size >= 17*consts.CD_RAW_SECTOR_SIZE {
+		if _, err := r.ReadAt(first, 16*consts.CD_RAW_SECTOR_SIZE); err != nil {
+			return nil, err
+		}
+		if _, ok := DetectSectorMode(first); ok {
+			return NewTrackReader(r, 0, size, consts.CD_RAW_SECTOR_SIZE)
+		}
+	}
+
+	if size >= 17*consts.CD_MODE2_SECTOR_SIZE {
+		sector := first[:consts.CD_MODE2_SECTOR_SIZE]
+		if _, err := r.ReadAt(sector, 16*consts.CD_MODE2_SECTOR_SIZE); err != nil {
+			return nil, err
+		}
+		data, _ := userData(sector, consts.CD_MODE2_SECTOR_SIZE)
+		if id := string(data[1:6]); id == consts.ISO9660_STD_IDENTIFIER || id == consts.UDF_STD_IDENTIFIER {
+			return NewTrackReader(r, 0, size, consts.CD_MODE2_SECTOR_SIZE)
+		}
+	}
+
+	return nil, errors.New("not a raw CD image")
+}
+
+// NewTrackReader returns a Reader for a track of length bytes starting at byte start of r whose sectors are
+// sectorSize bytes long: 2352 for raw sectors, 2448 for raw sectors followed by subchannel data, 2336 for Mode 2
+// sectors without sync pattern and header or 2048 for cooked sectors. If r is an io.Closer it is closed by Close.
+func NewTrackReader(r io.ReaderAt, start, length int64, sectorSize int) (*Reader, error) {
+	switch sectorSize {
+	case consts.CD_RAW_SECTOR_SIZE + consts.CD_SUBCHANNEL_SIZE, consts.CD_RAW_SECTOR_SIZE, consts.CD_MODE2_SECTOR_SIZE,
+		consts.ISO9660_SECTOR_SIZE:
+	default:
+		return nil, fmt.Errorf("unsupported sector size %d", sectorSize)
+	}
+
+	reader := &Reader{
+		r:          r,
+		start:      start,
+		sectorSize: sectorSize,
+		sectors:    length / int64(sectorSize),
+	}
+	reader.closer, _ = r.(io.Closer)
+
+	// The mode of the track is the mode of its first volume descriptor, or of its first sector when it is shorter
+	lba := int64(16)
+	if reader.sectors <= lba {
+		lba = 0
+	}
+	if reader.sectors > 0 {
+		_, mode, err := reader.ReadSector(lba)
+		if err != nil {
+			return nil, err
+		}
+		reader.mode = mode
+	}
+	return reader, nil
+}
+
+// Mode returns the sector mode of the track, detected from its first volume descriptor.
+func (r *Reader) Mode() SectorMode {
+	return r.mode
+}
+
+// SectorSize returns the size of the sectors of the track as stored in the image.
+func (r *Reader) SectorSize() int {
+	return r.sectorSize
+}
+
+// Size returns the size of the 2048-byte sector view of the track.
+func (r *Reader) Size() int64 {
+	return r.sectors * consts.ISO9660_SECTOR_SIZE
+}
+
+// ReadSector returns the user data of a sector of the track and its mode. The data is 2048 bytes long for cooked,
+// Mode 1 and Form 1 sectors, 2324 bytes for Form 2 sectors and 2336 bytes for formless Mode 2 sectors.
+func (r *Reader) ReadSector(lba int64) ([]byte, SectorMode, error) {
+	if lba < 0 || lba >= r.sectors {
+		return nil, r.mode, fmt.Errorf("sector %d is outside the track: %w", lba, io.EOF)
+	}
+	sector := make([]byte, r.sectorSize)
+	if _, err := r.r.ReadAt(sector, r.start+lba*int64(r.sectorSize)); err != nil && !errors.Is(err, io.EOF) {
+		return nil, r.mode, err
+	}
+	data, mode := userData(sector, r.sectorSize)
+	return data, mode, nil
+}
+
+// ReadAt implements io.ReaderAt, translating the 2048-byte sectors of the view to the sectors of the track.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("cdrom: negative offset")
+	}
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		lba := pos / consts.ISO9660_SECTOR_SIZE
+		if lba >= r.sectors {
+			return n, io.EOF
+		}
+		within := int(pos % consts.ISO9660_SECTOR_SIZE)
+
+		count := (int64(within+len(p)-n) + consts.ISO9660_SECTOR_SIZE - 1) / consts.ISO9660_SECTOR_SIZE
+		count = min(count, r.sectors-lba, readAheadSectors)
+		raw := make([]byte, count*int64(r.sectorSize))
+		read, err := r.r.ReadAt(raw, r.start+lba*int64(r.sectorSize))
+		if read < len(raw) {
+			if err == nil || errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, err
+		}
+
+		for i := 0; i < int(count) && n < len(p); i++ {
+			data, _ := userData(raw[i*r.sectorSize:(i+1)*r.sectorSize], r.sectorSize)
+			n += copy(p[n:], data[within:consts.ISO9660_SECTOR_SIZE])
+			within = 0
+		}
+	}
+	return n, nil
+}
+
+// Close closes the underlying image if it is an io.Closer.
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
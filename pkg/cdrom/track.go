@@ -0,0 +1,39 @@
+package cdrom
+
+import (
+	"errors"
+	"io"
+)
+
+// Track is a track of a disc image as located by the descriptor of an image container such as NRG or MDS.
+type Track struct {
+	Number int
+	// Audio is set for CD-DA tracks
+	Audio bool
+	// Sector address of the start of the track on the disc, when the container records it
+	Start int64
+	// Size of the sectors of the track in the image file, including any subchannel data
+	SectorSize int
+	// Position and length of the track in the image file, in bytes
+	Offset int64
+	Length int64
+}
+
+// dataTrack returns the first data track of a list of tracks.
+func dataTrack(tracks []Track) (Track, error) {
+	for _, track := range tracks {
+		if !track.Audio {
+			return track, nil
+		}
+	}
+	return Track{}, errors.New("image has no data track")
+}
+
+// openDataTrack returns a Reader for the first data track stored in r.
+func openDataTrack(r io.ReaderAt, tracks []Track) (*Reader, error) {
+	track, err := dataTrack(tracks)
+	if err != nil {
+		return nil, err
+	}
+	return NewTrackReader(r, track.Offset, track.Length, track.SectorSize)
+}
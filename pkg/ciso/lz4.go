@@ -0,0 +1,72 @@
+package ciso
+
+import "errors"
+
+var errCorruptLZ4 = errors.New("corrupt LZ4 block")
+
+// decompressLZ4 decodes an LZ4 block into dst, stopping once dst is full so any padding after the block is ignored.
+// It returns the number of bytes written to dst.
+func decompressLZ4(src, dst []byte) (int, error) {
+	si, di := 0, 0
+	for si < len(src) && di < len(dst) {
+		token := src[si]
+		si++
+
+		// Literals, with a length extended by bytes of 255 when the token holds 15
+		literals, err := lz4Length(src, &si, int(token>>4))
+		if err != nil {
+			return di, err
+		}
+		if si+literals > len(src) || di+literals > len(dst) {
+			return di, errCorruptLZ4
+		}
+		di += copy(dst[di:], src[si:si+literals])
+		si += literals
+
+		// The last sequence of a block only holds literals
+		if si >= len(src) || di >= len(dst) {
+			break
+		}
+
+		// Match copied from earlier output, possibly overlapping the bytes being written
+		if si+2 > len(src) {
+			return di, errCorruptLZ4
+		}
+		offset := int(src[si]) | int(src[si+1])<<8
+		si += 2
+		if offset == 0 || offset > di {
+			return di, errCorruptLZ4
+		}
+		match, err := lz4Length(src, &si, int(token&0x0F))
+		if err != nil {
+			return di, err
+		}
+		match += 4
+		if di+match > len(dst) {
+			return di, errCorruptLZ4
+		}
+		for i := 0; i < match; i++ {
+			dst[di] = dst[di-offset]
+			di++
+		}
+	}
+	return di, nil
+}
+
+// lz4Length completes a literal or match length taken from a token nibble with the extension bytes at src[*si].
+func lz4Length(src []byte, si *int, length int) (int, error) {
+	if length != 15 {
+		return length, nil
+	}
+	for {
+		if *si >= len(src) {
+			return 0, errCorruptLZ4
+		}
+		b := src[*si]
+		*si++
+		length += int(b)
+		if b != 255 {
+			return length, nil
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package ciso
+
+import (
+	"bytes"
+	"compress/flate"
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// Magic numbers of CSO images, compressed with deflate, and ZSO images, compressed with LZ4
+	CSO_MAGIC = "CISO"
+	ZSO_MAGIC = "ZISO"
+
+	// Size of the header of CSO and ZSO images, followed by the block index
+	HEADER_SIZE = 24
+
+	// Default number of decompressed blocks kept in memory by a Reader
+	DEFAULT_CACHE_BLOCKS = 256
+
+	// Index entry bit marking a block stored without compression, or compressed with LZ4 in version 2 CSO images
+	indexFlag = 0x80000000
+)
+
+// Header is the header of a CSO or ZSO image.
+type Header struct {
+	// Magic is CSO_MAGIC or ZSO_MAGIC
+	Magic string
+	// Size of the uncompressed image
+	TotalBytes uint64
+	// Size of the uncompressed blocks, usually 2048
+	BlockSize uint32
+	// Format version, 1 or 2
+	Version uint8
+	// Index entries hold block offsets shifted right by Align bits
+	Align uint8
+}
+
+// IsCompressed returns true if the image starts with the magic number of a CSO or ZSO image.
+func IsCompressed(r io.ReaderAt) bool {
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], 0); err != nil {
+		return false
+	}
+	return string(magic[:]) == CSO_MAGIC || string(magic[:]) == ZSO_MAGIC
+}
+
+// Reader is an io.ReaderAt presenting the uncompressed content of a CSO or ZSO image. Blocks are located through the
+// block index and decompressed on demand, keeping the most recently used ones in memory.
+type Reader struct {
+	r      io.ReaderAt
+	closer io.Closer
+	header Header
+	index  []uint32
+
+	mu         sync.Mutex
+	cacheSize  int
+	lru        *list.List
+	blocks     map[int64]*list.Element
+	compressed []byte
+}
+
+// cachedBlock is a decompressed block held by a Reader.
+type cachedBlock struct {
+	number int64
+	data   []byte
+}
+
+// NewReader reads the header and block index of a CSO or ZSO image and returns a Reader keeping at most cacheBlocks
+// decompressed blocks in memory, DEFAULT_CACHE_BLOCKS when it is not positive. If r is an io.Closer it is closed by
+// Close.
+func NewReader(r io.ReaderAt, cacheBlocks int) (*Reader, error) {
+	buf := make([]byte, HEADER_SIZE)
+	if _, err := r.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("failed to read compressed image header: %w", err)
+	}
+	header := Header{
+		Magic:      string(buf[0:4]),
+		TotalBytes: binary.LittleEndian.Uint64(buf[8:16]),
+		BlockSize:  binary.LittleEndian.Uint32(buf[16:20]),
+		Version:    buf[20],
+		Align:      buf[21],
+	}
+	if header.Magic != CSO_MAGIC && header.Magic != ZSO_MAGIC {
+		return nil, errors.New("not a CSO or ZSO image")
+	}
+	if header.BlockSize == 0 || header.BlockSize&(header.BlockSize-1) != 0 || header.Align > 31 {
+		return nil, fmt.Errorf("invalid compressed image header: block size %d, align %d", header.BlockSize, header.Align)
+	}
+
+	blocks := (header.TotalBytes + uint64(header.BlockSize) - 1) / uint64(header.BlockSize)
+	if blocks >= 1<<32 {
+		return nil, fmt.Errorf("compressed image is too large: %d blocks", blocks)
+	}
+	raw := make([]byte, 4*(blocks+1))
+	if _, err := r.ReadAt(raw, HEADER_SIZE); err != nil {
+		return nil, fmt.Errorf("failed to read block index: %w", err)
+	}
+	index := make([]uint32, blocks+1)
+	for i := range index {
+		index[i] = binary.LittleEndian.Uint32(raw[4*i:])
+	}
+
+	if cacheBlocks <= 0 {
+		cacheBlocks = DEFAULT_CACHE_BLOCKS
+	}
+	reader := &Reader{
+		r:         r,
+		header:    header,
+		index:     index,
+		cacheSize: cacheBlocks,
+		lru:       list.New(),
+		blocks:    make(map[int64]*list.Element),
+	}
+	reader.closer, _ = r.(io.Closer)
+	return reader, nil
+}
+
+// Header returns the header of the image.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Size returns the size of the uncompressed image.
+func (r *Reader) Size() int64 {
+	return int64(r.header.TotalBytes)
+}
+
+// ReadAt implements io.ReaderAt, decompressing the blocks covering the requested range.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("ciso: negative offset")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	blockSize := int64(r.header.BlockSize)
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.Size() {
+			return n, io.EOF
+		}
+		data, err := r.block(pos / blockSize)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[pos%blockSize:])
+	}
+	return n, nil
+}
+
+// Close closes the underlying image if it is an io.Closer.
+func (r *Reader) Close() error {
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
+
+// block returns a decompressed block, decompressing it and evicting the least recently used one if it is not cached.
+func (r *Reader) block(number int64) ([]byte, error) {
+	if elem, ok := r.blocks[number]; ok {
+		r.lru.MoveToFront(elem)
+		return elem.Value.(*cachedBlock).data, nil
+	}
+
+	data, err := r.decompress(number)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %w", number, err)
+	}
+	r.blocks[number] = r.lru.PushFront(&cachedBlock{number: number, data: data})
+	for r.lru.Len() > r.cacheSize {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.blocks, oldest.Value.(*cachedBlock).number)
+	}
+	return data, nil
+}
+
+// decompress reads and decompresses a block. The last block is shorter when the image size is not a multiple of the
+// block size.
+func (r *Reader) decompress(number int64) ([]byte, error) {
+	blockSize := int64(r.header.BlockSize)
+	size := min(blockSize, r.Size()-number*blockSize)
+	entry, next := r.index[number], r.index[number+1]
+	start := int64(entry&^indexFlag) << r.header.Align
+	end := int64(next&^indexFlag) << r.header.Align
+	if end < start {
+		return nil, errors.New("invalid block index")
+	}
+
+	// The stored block may include alignment padding, which the decoders ignore
+	if int64(cap(r.compressed)) < end-start {
+		r.compressed = make([]byte, end-start)
+	}
+	stored := r.compressed[:end-start]
+	if read, err := r.r.ReadAt(stored, start); err != nil && !(errors.Is(err, io.EOF) && int64(read) >= min(size, end-start)) {
+		return nil, err
+	}
+
+	data := make([]byte, size)
+	flagged := entry&indexFlag != 0
+	switch {
+	case r.header.Magic == CSO_MAGIC && r.header.Version >= 2 && int64(len(stored)) >= blockSize,
+		r.header.Magic == CSO_MAGIC && r.header.Version < 2 && flagged,
+		r.header.Magic == ZSO_MAGIC && flagged:
+		// Stored without compression
+		if int64(len(stored)) < size {
+			return nil, io.ErrUnexpectedEOF
+		}
+		copy(data, stored)
+	case r.header.Magic == ZSO_MAGIC, flagged:
+		// LZ4 compressed, the flag selecting LZ4 over deflate in version 2 CSO images
+		n, err := decompressLZ4(stored, data)
+		if err != nil {
+			return nil, err
+		}
+		if int64(n) != size {
+			return nil, io.ErrUnexpectedEOF
+		}
+	default:
+		// Raw deflate compressed
+		if _, err := io.ReadFull(flate.NewReader(bytes.NewReader(stored)), data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,68 @@
+package ciso
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	image := bytes.Repeat([]byte("compressible block content "), 500)
+	image = append(image, make([]byte, 3000)...)
+	for i := 4096; i < 6144; i++ {
+		image[i] = byte(i * 7919 >> 3)
+	}
+
+	out, err := os.Create(filepath.Join(t.TempDir(), "image.cso"))
+	require.NoError(t, err)
+	defer out.Close()
+	require.NoError(t, Compress(out, bytes.NewReader(image), int64(len(image)), flate.DefaultCompression))
+	require.True(t, IsCompressed(out))
+
+	r, err := NewReader(out, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(image)), r.Size())
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	require.NoError(t, err)
+	require.Equal(t, image, data)
+
+	// Reads spanning blocks and past the end of the image
+	buf := make([]byte, 3000)
+	n, err := r.ReadAt(buf, 1000)
+	require.NoError(t, err)
+	require.Equal(t, image[1000:4000], buf[:n])
+	n, err = r.ReadAt(buf, int64(len(image))-10)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, 10, n)
+}
+
+func TestZSO(t *testing.T) {
+	// Block 0 is an LZ4 block of three literals and an overlapping match, block 1 is stored as is. Offsets are
+	// aligned to 4 bytes.
+	block0 := []byte{0x3F, 'a', 'b', 'c', 3, 0, 255, 255, 255, 255, 255, 255, 255, 241}
+	block1 := bytes.Repeat([]byte{'z'}, 100)
+	header := make([]byte, HEADER_SIZE)
+	copy(header, ZSO_MAGIC)
+	binary.LittleEndian.PutUint64(header[8:], 2048+100)
+	binary.LittleEndian.PutUint32(header[16:], 2048)
+	header[20], header[21] = 1, 2
+
+	image := append(header, make([]byte, 12)...)
+	binary.LittleEndian.PutUint32(image[24:], uint32(len(image)>>2))
+	image = append(image, block0...)
+	image = append(image, 0, 0)
+	binary.LittleEndian.PutUint32(image[28:], uint32(len(image)>>2)|indexFlag)
+	image = append(image, block1...)
+	binary.LittleEndian.PutUint32(image[32:], uint32(len(image)>>2))
+
+	r, err := NewReader(bytes.NewReader(image), 0)
+	require.NoError(t, err)
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	require.NoError(t, err)
+	require.Equal(t, append(bytes.Repeat([]byte("abc"), 683)[:2048], block1...), data)
+}
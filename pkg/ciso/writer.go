@@ -0,0 +1,94 @@
+package ciso
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"io"
+)
+
+// Compress writes the image of the given size read from r to w as a version 1 CSO image. Each 2048-byte block is
+// compressed with deflate at the given level, one of the compress/flate levels, and stored as is when that does not
+// make it smaller. Block offsets are aligned when the image is too large for them to fit in the index otherwise.
+func Compress(w io.WriterAt, r io.ReaderAt, size int64, level int) error {
+	if size < 0 {
+		return errors.New("ciso: negative image size")
+	}
+	const blockSize = consts.ISO9660_SECTOR_SIZE
+	blocks := (size + blockSize - 1) / blockSize
+	indexSize := 4 * (blocks + 1)
+
+	// Blocks are never stored larger than they are, so the image cannot grow past the header, index and data size
+	var align uint8
+	for (HEADER_SIZE+indexSize+size+blocks<<align)>>align > indexFlag-1 {
+		align++
+	}
+
+	header := make([]byte, HEADER_SIZE)
+	copy(header, CSO_MAGIC)
+	binary.LittleEndian.PutUint32(header[4:8], HEADER_SIZE)
+	binary.LittleEndian.PutUint64(header[8:16], uint64(size))
+	binary.LittleEndian.PutUint32(header[16:20], blockSize)
+	header[20] = 1
+	header[21] = align
+	if _, err := w.WriteAt(header, 0); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	compressor, err := flate.NewWriter(nil, level)
+	if err != nil {
+		return err
+	}
+	index := make([]byte, indexSize)
+	block := make([]byte, blockSize)
+	var compressed bytes.Buffer
+	pos := aligned(HEADER_SIZE+indexSize, align)
+
+	for i := int64(0); i < blocks; i++ {
+		data := block[:min(blockSize, size-i*blockSize)]
+		if _, err := r.ReadAt(data, i*blockSize); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to read block %d: %w", i, err)
+		}
+
+		compressed.Reset()
+		compressor.Reset(&compressed)
+		if _, err := compressor.Write(data); err != nil {
+			return err
+		}
+		if err := compressor.Close(); err != nil {
+			return err
+		}
+
+		entry := uint32(pos >> align)
+		stored := compressed.Bytes()
+		if len(stored) >= len(data) {
+			stored = data
+			entry |= indexFlag
+		}
+		binary.LittleEndian.PutUint32(index[4*i:], entry)
+
+		// Pad the block so the next one starts at an aligned offset
+		next := aligned(pos+int64(len(stored)), align)
+		stored = append(stored, make([]byte, next-pos-int64(len(stored)))...)
+		if _, err := w.WriteAt(stored, pos); err != nil {
+			return fmt.Errorf("failed to write block %d: %w", i, err)
+		}
+		pos = next
+	}
+
+	// The last entry marks the end of the last block
+	binary.LittleEndian.PutUint32(index[4*blocks:], uint32(pos>>align))
+	if _, err := w.WriteAt(index, HEADER_SIZE); err != nil {
+		return fmt.Errorf("failed to write block index: %w", err)
+	}
+	return nil
+}
+
+// aligned rounds offset up to a multiple of 1<<align.
+func aligned(offset int64, align uint8) int64 {
+	mask := int64(1)<<align - 1
+	return (offset + mask) &^ mask
+}
@@ -10,6 +10,12 @@ const (
 	// ISO9660 volume descriptor version (always 1).
 	ISO9660_VOLUME_DESC_VERSION = 1
 
+	// ISO 9660:1999 enhanced volume descriptor version and file structure version.
+	ISO9660_ENHANCED_VOLUME_DESC_VERSION = 2
+
+	// ISO 9660:1999 maximum length of a file identifier in an enhanced volume hierarchy.
+	ISO9660_ENHANCED_MAX_IDENTIFIER_LENGTH = 207
+
 	// ISO9660 default sector size.
 	ISO9660_SECTOR_SIZE = 2048
 
@@ -64,6 +70,30 @@ const (
 	// Standard UDF Identifier
 	UDF_STD_IDENTIFIER = "BEA01"
 
+	// UDF volume recognition sequence identifiers of the NSR descriptors of ECMA-167 2nd and 3rd edition volumes and
+	// of the descriptor terminating the extended area.
+	UDF_NSR02_IDENTIFIER = "NSR02"
+	UDF_NSR03_IDENTIFIER = "NSR03"
+	UDF_TEA_IDENTIFIER   = "TEA01"
+
+	// UDF sector of the first anchor volume descriptor pointer.
+	UDF_ANCHOR_SECTOR = 256
+
 	// UDF default sector size.
 	UDF_SECTOR_SIZE = 2048
+
+	// Size of a raw CD sector including sync pattern, header and error correction.
+	CD_RAW_SECTOR_SIZE = 2352
+
+	// Size of a Mode 2 sector without its sync pattern and header.
+	CD_MODE2_SECTOR_SIZE = 2336
+
+	// Size of the subchannel data stored after each raw sector by some imaging tools.
+	CD_SUBCHANNEL_SIZE = 96
+
+	// Size of the user data of a Mode 2 Form 2 sector.
+	CD_FORM2_DATA_SIZE = 2324
+
+	// Number of CD sectors (frames) per second of MSF addresses.
+	CD_FRAMES_PER_SECOND = 75
 )
@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"github.com/bgrewell/iso-kit/pkg/consts"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/directory"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extensions"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extent"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
@@ -48,12 +51,33 @@ type FileSystemEntry struct {
 	GID *uint32 `json:"gid"`
 	// Mode, permissions of the file/directory
 	Mode os.FileMode
+	// SymlinkTarget, target of a symbolic link recorded in Rock Ridge SL entries. Empty for other entries.
+	SymlinkTarget string `json:"symlink_target,omitempty"`
+	// DeviceMajor and DeviceMinor, device numbers of block and character devices recorded in Rock Ridge PN entries
+	DeviceMajor uint32 `json:"device_major,omitempty"`
+	DeviceMinor uint32 `json:"device_minor,omitempty"`
+	// LinkCount, number of hard links (st_nlink) from the Rock Ridge PX entry when present
+	LinkCount uint32 `json:"link_count,omitempty"`
+	// SerialNumber, file serial number (st_ino) from the Rock Ridge PX entry when present. Hard links share it.
+	SerialNumber uint32 `json:"serial_number,omitempty"`
 	// CreateTime
 	CreateTime time.Time
 	// ModTime
 	ModTime time.Time
+	// AccessTime, from the Rock Ridge TF entry when present
+	AccessTime time.Time
+	// AttributeChangeTime (ctime), from the Rock Ridge TF entry when present
+	AttributeChangeTime time.Time
+	// BackupTime, from the Rock Ridge TF entry when present
+	BackupTime time.Time
+	// ExpirationTime, from the Rock Ridge TF entry when present
+	ExpirationTime time.Time
+	// EffectiveTime, from the Rock Ridge TF entry when present
+	EffectiveTime time.Time
 	// RockRidge extended attributes
 	HasRockRidge bool `json:"has_rock_ridge"`
+	// XA, CD-ROM XA attributes of entries on Mode 2 discs such as VideoCD and PlayStation discs
+	XA *extensions.XAAttributes `json:"xa,omitempty"`
 	// Original DirectoryRecord
 	record *directory.DirectoryRecord
 	// A reference to the io.ReaderAt so that we can extract the file contents easily
@@ -79,6 +103,16 @@ func (fse *FileSystemEntry) ExtractToDisk(outputDir string) error {
 		return os.MkdirAll(outputPath, os.FileMode(fse.Mode))
 	}
 
+	// Symbolic links are only created when their target stays within the output directory
+	if fse.IsSymlink() {
+		return fse.ExtractSymlink(outputDir, SYMLINK_POLICY_SKIP)
+	}
+
+	// Device nodes, FIFOs and sockets are not turned into regular files, see ExtractSpecialFile
+	if fse.IsSpecialFile() {
+		return fmt.Errorf("%s: %w", fse.FullPath, ErrSpecialFile)
+	}
+
 	// Ensure parent directory exists
 	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 		return fmt.Errorf("failed to create parent directories for %s: %w", outputPath, err)
@@ -91,13 +125,14 @@ func (fse *FileSystemEntry) ExtractToDisk(outputDir string) error {
 	}
 	defer outFile.Close()
 
-	// Read file bytes and write to disk
-	data, err := fse.GetBytes()
+	// Stream the file content to disk
+	reader, err := fse.Open()
 	if err != nil {
-		return fmt.Errorf("failed to read file data for %s: %w", fse.FullPath, err)
+		return err
 	}
+	defer reader.Close()
 
-	if _, err := outFile.Write(data); err != nil {
+	if _, err := io.Copy(outFile, reader); err != nil {
 		return fmt.Errorf("failed to write file %s: %w", outputPath, err)
 	}
 
@@ -107,24 +142,67 @@ func (fse *FileSystemEntry) ExtractToDisk(outputDir string) error {
 	}
 
 	// Set timestamps
-	if err := os.Chtimes(outputPath, fse.ModTime, fse.ModTime); err != nil {
+	if err := os.Chtimes(outputPath, fse.accessTime(), fse.ModTime); err != nil {
 		return fmt.Errorf("failed to set timestamps on %s: %w", outputPath, err)
 	}
 
 	return nil
 }
 
+// accessTime returns the access time to apply when extracting, falling back to the modification time
+func (fse *FileSystemEntry) accessTime() time.Time {
+	if fse.AccessTime.IsZero() {
+		return fse.ModTime
+	}
+	return fse.AccessTime
+}
+
+// FileReader reads the content of a file entry. It implements io.ReadSeekCloser and io.ReaderAt.
+type FileReader struct {
+	*io.SectionReader
+}
+
+// Close releases the reader. The underlying image stays open.
+func (r *FileReader) Close() error {
+	return nil
+}
+
+// Open returns a reader scoped to the content of the file so it can be streamed without loading it into memory. Files
+// recorded in interleaved mode are read unit by unit, skipping the interleave gaps, and CD-ROM XA Form 2 files of raw
+// images sector by sector with their full 2324 bytes of user data.
+func (fse *FileSystemEntry) Open() (*FileReader, error) {
+	if fse.IsDir {
+		return nil, fmt.Errorf("cannot open a directory: %s", fse.FullPath)
+	}
+
+	// Form 2 files are read with the 2324 bytes of user data of each sector when the image has raw sectors
+	if sr, ok := fse.reader.(extent.SectorReader); ok && fse.XA != nil && fse.XA.IsForm2() {
+		return &FileReader{io.NewSectionReader(extent.NewForm2Reader(sr, fse.Location), 0, int64(fse.Size))}, nil
+	}
+
+	if fse.record != nil && fse.record.FileUnitSize > 0 {
+		reader := extent.NewInterleavedReader(fse.reader, fse.Location, fse.record.FileUnitSize, fse.record.InterleaveGapSize)
+		return &FileReader{io.NewSectionReader(reader, 0, int64(fse.Size))}, nil
+	}
+
+	startOffset := int64(fse.Location) * int64(consts.ISO9660_SECTOR_SIZE)
+	return &FileReader{io.NewSectionReader(fse.reader, startOffset, int64(fse.Size))}, nil
+}
+
 // Get the raw bytes of the file
 func (fse *FileSystemEntry) GetBytes() ([]byte, error) {
 	if fse.IsDir {
 		return nil, fmt.Errorf("cannot get bytes for a directory: %s", fse.FullPath)
 	}
 
-	startOffset := int64(fse.Location) * int64(consts.ISO9660_SECTOR_SIZE)
-	data := make([]byte, fse.Size)
-
-	_, err := fse.reader.ReadAt(data, startOffset)
+	reader, err := fse.Open()
 	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data := make([]byte, fse.Size)
+	if _, err = io.ReadFull(reader, data); err != nil {
 		return nil, fmt.Errorf("failed to read file data for %s: %w", fse.FullPath, err)
 	}
 
@@ -136,14 +214,7 @@ func (fse *FileSystemEntry) GetMD5() (string, error) {
 	if fse.IsDir {
 		return "", fmt.Errorf("cannot compute MD5 for a directory: %s", fse.FullPath)
 	}
-
-	data, err := fse.GetBytes()
-	if err != nil {
-		return "", err
-	}
-
-	md5Sum := md5.Sum(data)
-	return hex.EncodeToString(md5Sum[:]), nil
+	return fse.hash(md5.New())
 }
 
 // Compute SHA-256 hash of the file
@@ -151,12 +222,19 @@ func (fse *FileSystemEntry) GetSHA256() (string, error) {
 	if fse.IsDir {
 		return "", fmt.Errorf("cannot compute SHA-256 for a directory: %s", fse.FullPath)
 	}
+	return fse.hash(sha256.New())
+}
 
-	data, err := fse.GetBytes()
+// hash streams the content of the file through h and returns the hex encoded sum
+func (fse *FileSystemEntry) hash(h hash.Hash) (string, error) {
+	reader, err := fse.Open()
 	if err != nil {
 		return "", err
 	}
+	defer reader.Close()
 
-	sha256Sum := sha256.Sum256(data)
-	return hex.EncodeToString(sha256Sum[:]), nil
+	if _, err = io.Copy(h, reader); err != nil {
+		return "", fmt.Errorf("failed to read file data for %s: %w", fse.FullPath, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
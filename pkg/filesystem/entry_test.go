@@ -0,0 +1,107 @@
+package filesystem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileReader(t *testing.T) {
+	// A file spanning three sectors recorded at sector 2, followed by "hello" at sector 5
+	content := make([]byte, 2*consts.ISO9660_SECTOR_SIZE+100)
+	for i := range content {
+		content[i] = byte(i * 13 >> 3)
+	}
+	image := make([]byte, 6*consts.ISO9660_SECTOR_SIZE)
+	copy(image[2*consts.ISO9660_SECTOR_SIZE:], content)
+	copy(image[5*consts.ISO9660_SECTOR_SIZE:], "hello")
+
+	modTime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	entry := NewFileSystemEntry("data.bin", "/dir/data.bin", false, uint32(len(content)), 2, nil, nil, 0o640, modTime, modTime, nil, bytes.NewReader(image))
+	hello := NewFileSystemEntry("hello.txt", "/hello.txt", false, 5, 5, nil, nil, 0o644, modTime, modTime, nil, bytes.NewReader(image))
+
+	t.Run("stream", func(t *testing.T) {
+		r, err := entry.Open()
+		require.NoError(t, err)
+		defer r.Close()
+		var streamed []byte
+		buf := make([]byte, 1000)
+		for {
+			n, err := r.Read(buf)
+			streamed = append(streamed, buf[:n]...)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+		}
+		require.Equal(t, content, streamed)
+	})
+
+	t.Run("seek", func(t *testing.T) {
+		r, err := entry.Open()
+		require.NoError(t, err)
+		defer r.Close()
+		pos, err := r.Seek(3000, io.SeekStart)
+		require.NoError(t, err)
+		require.Equal(t, int64(3000), pos)
+		buf := make([]byte, 10)
+		_, err = io.ReadFull(r, buf)
+		require.NoError(t, err)
+		require.Equal(t, content[3000:3010], buf)
+
+		// Reads stop at the end of the file rather than the end of its last sector
+		_, err = r.Seek(-4, io.SeekEnd)
+		require.NoError(t, err)
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, content[len(content)-4:], rest)
+		n, err := r.ReadAt(buf, int64(len(content))-2)
+		require.ErrorIs(t, err, io.EOF)
+		require.Equal(t, 2, n)
+	})
+
+	t.Run("hashes", func(t *testing.T) {
+		md5sum, err := hello.GetMD5()
+		require.NoError(t, err)
+		require.Equal(t, "5d41402abc4b2a76b9719d911017c592", md5sum)
+		shasum, err := hello.GetSHA256()
+		require.NoError(t, err)
+		require.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", shasum)
+
+		expected := sha256.Sum256(content)
+		shasum, err = entry.GetSHA256()
+		require.NoError(t, err)
+		require.Equal(t, hex.EncodeToString(expected[:]), shasum)
+		data, err := entry.GetBytes()
+		require.NoError(t, err)
+		require.Equal(t, content, data)
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, entry.ExtractToDisk(dir))
+		outputPath := filepath.Join(dir, "dir", "data.bin")
+		data, err := os.ReadFile(outputPath)
+		require.NoError(t, err)
+		require.Equal(t, content, data)
+		info, err := os.Stat(outputPath)
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+		require.True(t, modTime.Equal(info.ModTime()))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		dir := NewFileSystemEntry("dir", "/dir", true, 0, 3, nil, nil, os.ModeDir|0o755, modTime, modTime, nil, bytes.NewReader(image))
+		_, err := dir.Open()
+		require.ErrorContains(t, err, "directory")
+		_, err = dir.GetMD5()
+		require.Error(t, err)
+	})
+}
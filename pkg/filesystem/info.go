@@ -0,0 +1,72 @@
+package filesystem
+
+import (
+	"io/fs"
+	"path"
+	"time"
+)
+
+// FileMode returns the mode of the entry including the type bits. Directory entries always carry fs.ModeDir even
+// when the permissions come from the defaults rather than a Rock Ridge PX entry.
+func (fse *FileSystemEntry) FileMode() fs.FileMode {
+	if fse.IsDir {
+		return fse.Mode | fs.ModeDir
+	}
+	return fse.Mode
+}
+
+// FileInfo returns a view of the entry satisfying fs.FileInfo. The fields of FileSystemEntry share their names with
+// the fs.FileInfo methods, so the entry cannot implement the interface itself.
+func (fse *FileSystemEntry) FileInfo() fs.FileInfo {
+	return entryInfo{fse}
+}
+
+// DirEntry returns a view of the entry satisfying fs.DirEntry.
+func (fse *FileSystemEntry) DirEntry() fs.DirEntry {
+	return entryInfo{fse}
+}
+
+// entryInfo implements fs.FileInfo and fs.DirEntry for a FileSystemEntry.
+type entryInfo struct {
+	fse *FileSystemEntry
+}
+
+func (i entryInfo) Name() string {
+	if i.fse.Name == "" {
+		return path.Base(i.fse.FullPath)
+	}
+	return i.fse.Name
+}
+
+func (i entryInfo) Size() int64 {
+	return int64(i.fse.Size)
+}
+
+func (i entryInfo) Mode() fs.FileMode {
+	return i.fse.FileMode()
+}
+
+func (i entryInfo) ModTime() time.Time {
+	return i.fse.ModTime
+}
+
+func (i entryInfo) IsDir() bool {
+	return i.fse.IsDir
+}
+
+// Sys returns the underlying *FileSystemEntry.
+func (i entryInfo) Sys() any {
+	return i.fse
+}
+
+func (i entryInfo) Type() fs.FileMode {
+	return i.fse.FileMode().Type()
+}
+
+func (i entryInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+func (i entryInfo) String() string {
+	return fs.FormatFileInfo(i)
+}
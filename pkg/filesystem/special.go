@@ -0,0 +1,77 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SpecialFilePolicy controls how device nodes, FIFOs and sockets are handled on extraction.
+type SpecialFilePolicy int
+
+const (
+	// SPECIAL_FILE_POLICY_SKIP skips special files, listing them in the manifest when one is configured (default)
+	SPECIAL_FILE_POLICY_SKIP SpecialFilePolicy = iota
+	// SPECIAL_FILE_POLICY_CREATE recreates special files with mknod and mkfifo. Device nodes are only created when
+	// running as root and are skipped otherwise.
+	SPECIAL_FILE_POLICY_CREATE
+	// SPECIAL_FILE_POLICY_ERROR fails the extraction when a special file is found
+	SPECIAL_FILE_POLICY_ERROR
+)
+
+// ErrSpecialFile is returned when a device node, FIFO or socket is not extracted.
+var ErrSpecialFile = errors.New("special file not extracted")
+
+// IsSpecialFile reports whether the entry is a device node, FIFO or socket.
+func (fse *FileSystemEntry) IsSpecialFile() bool {
+	return fse.Mode&(fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe|fs.ModeSocket) != 0
+}
+
+// ExtractSpecialFile recreates the device node, FIFO or socket below outputDir. ErrSpecialFile is returned when the
+// file can not be created, i.e. for device nodes when not running as root or on platforms without mknod.
+func (fse *FileSystemEntry) ExtractSpecialFile(outputDir string) error {
+	if !fse.IsSpecialFile() {
+		return fmt.Errorf("%s is not a special file", fse.FullPath)
+	}
+
+	outputPath := filepath.Join(outputDir, fse.FullPath)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directories for %s: %w", outputPath, err)
+	}
+	if _, err := os.Lstat(outputPath); err == nil {
+		if err := os.Remove(outputPath); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", outputPath, err)
+		}
+	}
+
+	if err := makeSpecialFile(outputPath, fse.Mode, fse.DeviceMajor, fse.DeviceMinor); err != nil {
+		return fmt.Errorf("failed to create %s: %w", outputPath, err)
+	}
+	return nil
+}
+
+// ManifestLine describes the special file in the format of the Linux gen_init_cpio file list so that it can be
+// recreated later, e.g. "nod /dev/console 0600 0 0 c 5 1".
+func (fse *FileSystemEntry) ManifestLine() string {
+	var uid, gid uint32
+	if fse.UID != nil {
+		uid = *fse.UID
+	}
+	if fse.GID != nil {
+		gid = *fse.GID
+	}
+	perm := fse.Mode.Perm()
+
+	switch {
+	case fse.Mode&fs.ModeNamedPipe != 0:
+		return fmt.Sprintf("pipe %s %04o %d %d", fse.FullPath, perm, uid, gid)
+	case fse.Mode&fs.ModeSocket != 0:
+		return fmt.Sprintf("sock %s %04o %d %d", fse.FullPath, perm, uid, gid)
+	case fse.Mode&fs.ModeCharDevice != 0:
+		return fmt.Sprintf("nod %s %04o %d %d c %d %d", fse.FullPath, perm, uid, gid, fse.DeviceMajor, fse.DeviceMinor)
+	default:
+		return fmt.Sprintf("nod %s %04o %d %d b %d %d", fse.FullPath, perm, uid, gid, fse.DeviceMajor, fse.DeviceMinor)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package filesystem
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+// makeSpecialFile is not supported on platforms without mknod.
+func makeSpecialFile(path string, mode fs.FileMode, major, minor uint32) error {
+	return fmt.Errorf("special files are not supported on this platform: %w", ErrSpecialFile)
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package filesystem
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/fs"
+)
+
+// makeSpecialFile creates a FIFO with mkfifo and device nodes and sockets with mknod.
+func makeSpecialFile(path string, mode fs.FileMode, major, minor uint32) error {
+	perm := uint32(mode.Perm())
+	switch {
+	case mode&fs.ModeNamedPipe != 0:
+		return unix.Mkfifo(path, perm)
+	case mode&fs.ModeSocket != 0:
+		return unix.Mknod(path, unix.S_IFSOCK|perm, 0)
+	}
+
+	if unix.Geteuid() != 0 {
+		return fmt.Errorf("device nodes can only be created by root: %w", ErrSpecialFile)
+	}
+	kind := uint32(unix.S_IFBLK)
+	if mode&fs.ModeCharDevice != 0 {
+		kind = unix.S_IFCHR
+	}
+	return mknod(unix.Mknod, path, kind|perm, unix.Mkdev(major, minor))
+}
+
+// mknod calls unix.Mknod, whose device argument is an int on most platforms and a uint64 on FreeBSD.
+func mknod[D int | uint64](fn func(string, uint32, D) error, path string, mode uint32, dev uint64) error {
+	return fn(path, mode, D(dev))
+}
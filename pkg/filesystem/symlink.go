@@ -0,0 +1,162 @@
+package filesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SymlinkPolicy controls how symbolic links with absolute targets, or targets that resolve outside of the output
+// directory, are handled on extraction.
+type SymlinkPolicy int
+
+const (
+	// SYMLINK_POLICY_SKIP skips links with absolute or escaping targets (default)
+	SYMLINK_POLICY_SKIP SymlinkPolicy = iota
+	// SYMLINK_POLICY_ERROR fails the extraction when a link has an absolute or escaping target
+	SYMLINK_POLICY_ERROR
+	// SYMLINK_POLICY_REWRITE rewrites absolute targets relative to the output directory and skips escaping targets
+	SYMLINK_POLICY_REWRITE
+	// SYMLINK_POLICY_PRESERVE creates every link exactly as recorded. Only use it for trusted images.
+	SYMLINK_POLICY_PRESERVE
+)
+
+// ErrUnsafeSymlink is returned when a symbolic link is not created because of its target.
+var ErrUnsafeSymlink = errors.New("symbolic link target is absolute or outside of the output directory")
+
+// IsSymlink reports whether the entry is a symbolic link.
+func (fse *FileSystemEntry) IsSymlink() bool {
+	return fse.SymlinkTarget != ""
+}
+
+// ExtractSymlink creates the symbolic link below outputDir. Links that the policy does not allow are not created and
+// ErrUnsafeSymlink is returned; SYMLINK_POLICY_SKIP and SYMLINK_POLICY_ERROR only differ in how Extract reacts to it.
+func (fse *FileSystemEntry) ExtractSymlink(outputDir string, policy SymlinkPolicy) error {
+	if !fse.IsSymlink() {
+		return fmt.Errorf("%s is not a symbolic link", fse.FullPath)
+	}
+
+	linkPath := filepath.Join(outputDir, fse.FullPath)
+	target, err := symlinkTarget(outputDir, linkPath, fse.SymlinkTarget, policy)
+	if err != nil {
+		return fmt.Errorf("%s -> %s: %w", fse.FullPath, fse.SymlinkTarget, err)
+	}
+
+	// Ensure parent directory exists and nothing is in the way of the link
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directories for %s: %w", linkPath, err)
+	}
+	if _, err := os.Lstat(linkPath); err == nil {
+		if err := os.Remove(linkPath); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", linkPath, err)
+		}
+	}
+
+	if err := os.Symlink(target, linkPath); err != nil {
+		return fmt.Errorf("failed to create symbolic link %s: %w", linkPath, err)
+	}
+	return nil
+}
+
+// symlinkTarget applies the policy to the recorded target and returns the target to create.
+func symlinkTarget(outputDir, linkPath, target string, policy SymlinkPolicy) (string, error) {
+	if policy == SYMLINK_POLICY_PRESERVE {
+		return target, nil
+	}
+
+	root, err := filepath.Abs(outputDir)
+	if err != nil {
+		return "", err
+	}
+	linkDir, err := filepath.Abs(filepath.Dir(linkPath))
+	if err != nil {
+		return "", err
+	}
+
+	if filepath.IsAbs(target) {
+		if policy != SYMLINK_POLICY_REWRITE {
+			return "", ErrUnsafeSymlink
+		}
+		if target, err = filepath.Rel(linkDir, filepath.Join(root, target)); err != nil {
+			return "", err
+		}
+	}
+
+	// Links already extracted are followed, both in the directory the link is created in and in its target, so a
+	// chain of links that each stay inside cannot be combined to leave the output directory
+	realDir, err := ResolveInside(root, linkDir)
+	if err != nil {
+		return "", ErrUnsafeSymlink
+	}
+	if _, err := ResolveInside(root, realDir+string(filepath.Separator)+target); err != nil {
+		return "", ErrUnsafeSymlink
+	}
+	return target, nil
+}
+
+// ResolveInside resolves the symbolic links found on disk along p and returns the real path, or an error if it is
+// not below root. Components of p that do not exist yet are resolved lexically. p is not cleaned before resolving, so
+// a ".." following a link refers to the parent of the link's target as it would for the operating system.
+func ResolveInside(root, p string) (string, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	if realRoot, err = filepath.Abs(realRoot); err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(p) {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		p = wd + string(filepath.Separator) + p
+	}
+	real, err := resolvePath(p, 0)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(realRoot, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of %s", p, root)
+	}
+	return real, nil
+}
+
+// resolvePath resolves the links of the longest existing prefix of the absolute path p.
+func resolvePath(p string, depth int) (string, error) {
+	if depth > 255 {
+		return "", fmt.Errorf("%s: too many levels of missing directories or links", p)
+	}
+	real, err := filepath.EvalSymlinks(p)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return real, err
+	}
+
+	dir, base := filepath.Split(p)
+	parent := strings.TrimRight(dir, string(filepath.Separator))
+	if parent == "" || parent == p {
+		return filepath.Clean(p), nil
+	}
+	realParent, err := resolvePath(parent, depth+1)
+	if err != nil {
+		return "", err
+	}
+	// The parent is free of links, so ".." can be applied lexically. The last component may itself be a link, even
+	// a dangling one that a file would be written through.
+	joined := filepath.Join(realParent, base)
+	if info, err := os.Lstat(joined); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(joined)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(target) {
+			target = realParent + string(filepath.Separator) + target
+		}
+		return resolvePath(target, depth+1)
+	}
+	return joined, nil
+}
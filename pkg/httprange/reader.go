@@ -0,0 +1,203 @@
+package httprange
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Default size of the blocks fetched and cached by a Reader, a multiple of the sector size (64 KiB)
+	DEFAULT_BLOCK_SIZE = 32 * consts.ISO9660_SECTOR_SIZE
+
+	// Default number of blocks kept in memory by a Reader (16 MiB)
+	DEFAULT_CACHE_BLOCKS = 256
+)
+
+// Reader is an io.ReaderAt over a file served over HTTP, fetched with Range requests. The file is read in blocks
+// aligned to the sector size; the blocks missing for a read are fetched together with one request per run of
+// consecutive blocks, and the most recently used blocks are kept in memory.
+type Reader struct {
+	url       string
+	client    *http.Client
+	size      int64
+	blockSize int64
+	// etag identifies the version of the file, so a file replaced while it is read is detected
+	etag string
+
+	mu        sync.Mutex
+	cacheSize int
+	lru       *list.List
+	blocks    map[int64]*list.Element
+}
+
+// cachedBlock is a block of the file held by a Reader. Data is shorter than a block at the end of the file.
+type cachedBlock struct {
+	number int64
+	data   []byte
+}
+
+// NewReader checks that the server at url supports Range requests, reads the size of the file and returns a Reader
+// fetching blocks of blockSize bytes, rounded up to a multiple of the sector size, and keeping at most cacheBlocks of
+// them in memory. Zero values select http.DefaultClient, DEFAULT_BLOCK_SIZE and DEFAULT_CACHE_BLOCKS.
+func NewReader(url string, client *http.Client, blockSize, cacheBlocks int) (*Reader, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if blockSize <= 0 {
+		blockSize = DEFAULT_BLOCK_SIZE
+	}
+	blockSize = (blockSize + consts.ISO9660_SECTOR_SIZE - 1) / consts.ISO9660_SECTOR_SIZE * consts.ISO9660_SECTOR_SIZE
+	if cacheBlocks <= 0 {
+		cacheBlocks = DEFAULT_CACHE_BLOCKS
+	}
+	r := &Reader{
+		url:       url,
+		client:    client,
+		blockSize: int64(blockSize),
+		cacheSize: cacheBlocks,
+		lru:       list.New(),
+		blocks:    make(map[int64]*list.Element),
+	}
+
+	// The size of the file is given by the Content-Range of a request for its first byte
+	resp, err := r.get(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	_, total, ok := strings.Cut(resp.Header.Get("Content-Range"), "/")
+	if r.size, err = strconv.ParseInt(total, 10, 64); !ok || err != nil {
+		return nil, fmt.Errorf("server did not report the size of %s", url)
+	}
+	r.etag = resp.Header.Get("ETag")
+	return r, nil
+}
+
+// Size returns the size of the file.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt, fetching the blocks covering the requested range that are not cached.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("httprange: negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Blocks are copied as soon as they are found or fetched, so fetching later runs may evict them
+	end := min(off+int64(len(p)), r.size)
+	first, last := off/r.blockSize, (end-1)/r.blockSize
+	n := 0
+	for number := first; number <= last; {
+		if elem, ok := r.blocks[number]; ok {
+			r.lru.MoveToFront(elem)
+			n += r.copyBlock(p[n:], off+int64(n), elem.Value.(*cachedBlock))
+			number++
+			continue
+		}
+		run := number
+		for run < last {
+			if _, ok := r.blocks[run+1]; ok {
+				break
+			}
+			run++
+		}
+		blocks, err := r.fetch(number, run)
+		if err != nil {
+			return n, err
+		}
+		for _, block := range blocks {
+			n += r.copyBlock(p[n:], off+int64(n), block)
+		}
+		number = run + 1
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Close drops the cached blocks.
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lru.Init()
+	clear(r.blocks)
+	return nil
+}
+
+// copyBlock copies the data of block from offset off of the file into p.
+func (r *Reader) copyBlock(p []byte, off int64, block *cachedBlock) int {
+	return copy(p, block.data[off-block.number*r.blockSize:])
+}
+
+// fetch reads the blocks first to last with a single request, caches them, evicting the least recently used ones, and
+// returns them.
+func (r *Reader) fetch(first, last int64) ([]*cachedBlock, error) {
+	start := first * r.blockSize
+	end := min((last+1)*r.blockSize, r.size) - 1
+	resp, err := r.get(start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data := make([]byte, end-start+1)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, fmt.Errorf("failed to read bytes %d-%d of %s: %w", start, end, r.url, err)
+	}
+	var blocks []*cachedBlock
+	for number := first; number <= last; number++ {
+		offset := (number - first) * r.blockSize
+		block := &cachedBlock{number: number, data: data[offset:min(offset+r.blockSize, int64(len(data)))]}
+		r.blocks[number] = r.lru.PushFront(block)
+		blocks = append(blocks, block)
+	}
+	for r.lru.Len() > r.cacheSize {
+		oldest := r.lru.Back()
+		r.lru.Remove(oldest)
+		delete(r.blocks, oldest.Value.(*cachedBlock).number)
+	}
+	return blocks, nil
+}
+
+// get requests bytes start to end of the file. The request is conditional on the version of the file first read, so
+// a server holding another version answers with the whole file, which is reported as an error.
+func (r *Reader) get(start, end int64) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if r.etag != "" {
+		req.Header.Set("If-Range", r.etag)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		return resp, nil
+	case resp.StatusCode == http.StatusOK && r.etag != "":
+		err = fmt.Errorf("%s changed while it was read", r.url)
+	case resp.StatusCode == http.StatusOK:
+		err = fmt.Errorf("server does not support range requests for %s", r.url)
+	default:
+		err = fmt.Errorf("failed to fetch %s: %s", r.url, resp.Status)
+	}
+	resp.Body.Close()
+	return nil, err
+}
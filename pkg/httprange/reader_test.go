@@ -0,0 +1,96 @@
+package httprange
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	content := make([]byte, 10*4096+100)
+	for i := range content {
+		content[i] = byte(i * 31 >> 2)
+	}
+	var ranges []string
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ranges = append(ranges, req.Header.Get("Range"))
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, req, "image.iso", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	r, err := NewReader(server.URL, server.Client(), 4000, 4)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), r.Size())
+	require.Equal(t, []string{"bytes=0-0"}, ranges)
+
+	// Blocks are rounded up to 4096 bytes and the missing ones fetched with one request
+	buf := make([]byte, 6000)
+	n, err := r.ReadAt(buf, 1000)
+	require.NoError(t, err)
+	require.Equal(t, content[1000:7000], buf[:n])
+	require.Equal(t, "bytes=0-8191", ranges[1])
+
+	// Cached blocks are not fetched again and the missing run around them is fetched alone
+	n, err = r.ReadAt(buf, 5000)
+	require.NoError(t, err)
+	require.Equal(t, content[5000:11000], buf[:n])
+	require.Equal(t, "bytes=8192-12287", ranges[2])
+	require.Len(t, ranges, 3)
+
+	// Reads past the end of the file return the bytes before it
+	n, err = r.ReadAt(buf, int64(len(content))-50)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, content[len(content)-50:], buf[:n])
+	_, err = r.ReadAt(buf, int64(len(content)))
+	require.ErrorIs(t, err, io.EOF)
+
+	// The least recently used block is evicted and fetched again
+	_, err = r.ReadAt(buf[:1], 20000)
+	require.NoError(t, err)
+	_, err = r.ReadAt(buf[:1], 0)
+	require.NoError(t, err)
+	require.Equal(t, "bytes=0-4095", ranges[len(ranges)-1])
+
+	// A file replaced on the server is not mixed with the cached blocks
+	etag = `"v2"`
+	_, err = r.ReadAt(buf[:1], 30000)
+	require.ErrorContains(t, err, "changed")
+}
+
+func TestReaderLargerThanCache(t *testing.T) {
+	content := make([]byte, 4*2048)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeContent(w, req, "image.iso", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	// A read spanning a cached block needs two runs, the first evicted while the second is fetched
+	r, err := NewReader(server.URL, server.Client(), 2048, 1)
+	require.NoError(t, err)
+	_, err = r.ReadAt(make([]byte, 10), 2048)
+	require.NoError(t, err)
+	buf := make([]byte, 6144)
+	n, err := r.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, content[:6144], buf[:n])
+}
+
+func TestReaderWithoutRanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(w, strings.NewReader("whole file"))
+	}))
+	defer server.Close()
+
+	_, err := NewReader(server.URL, server.Client(), 0, 0)
+	require.ErrorContains(t, err, "range requests")
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 )
@@ -21,6 +22,8 @@ const (
 	EL_TORITO_DEFAULT_CATALOG = "BOOT.CAT"
 	// Default catalog name for Rock Ridge filesystems
 	EL_TORITO_DEFAULT_CATALOG_RR = "boot.catalog"
+	// Manufacturer/developer ID recorded in the validation entry of catalogs written by this library
+	EL_TORITO_MANUFACTURER_ID = "iso-kit"
 )
 
 // PartitionType represents the type of partition in the boot image.
@@ -235,43 +238,73 @@ func (et *ElTorito) Marshal() ([]byte, error) {
 	if len(et.Entries) == 0 {
 		return nil, fmt.Errorf("El Torito Boot Catalog has no entries")
 	}
+	if slices.Contains(et.Entries, nil) {
+		return nil, fmt.Errorf("El Torito Boot Catalog has a nil entry")
+	}
 
 	// Boot Catalog is stored in 2048-byte sectors, ensure correct alignment
 	data := make([]byte, consts.ISO9660_SECTOR_SIZE)
 
-	// 1️⃣ Write Validation Entry (First 32 bytes)
-	data[0] = 0x01                    // Header ID
-	copy(data[1:6], "EL TORITO SPEC") // Identifier
+	// Validation Entry (first 32 bytes)
+	data[0] = 0x01                              // Header ID
+	data[1] = byte(et.Entries[0].Platform)      // Platform ID
+	copy(data[4:28], EL_TORITO_MANUFACTURER_ID) // ID string
 	data[0x1E] = 0x55
 	data[0x1F] = 0xAA
 
-	// Compute checksum
+	// Checksum makes the sum of all 16-bit words in the validation entry zero
 	checksum := uint16(0)
 	for i := 0; i < 32; i += 2 {
 		checksum += binary.LittleEndian.Uint16(data[i : i+2])
 	}
-	binary.LittleEndian.PutUint16(data[0x1C:0x1E], -checksum) // Store negative checksum
-
-	// 2️⃣ Write Initial Boot Entry (First Boot Entry, starts at offset 32)
-	offset := 32
-	for _, entry := range et.Entries {
-		if offset+32 > len(data) {
+	binary.LittleEndian.PutUint16(data[0x1C:0x1E], -checksum)
+
+	// Initial/Default Entry
+	marshalEntry(data[32:64], et.Entries[0])
+
+	// Section headers and section entries. Consecutive entries for the same platform share a section.
+	offset := 64
+	remaining := et.Entries[1:]
+	for len(remaining) > 0 {
+		count := 1
+		for count < len(remaining) && remaining[count].Platform == remaining[0].Platform {
+			count++
+		}
+		if offset+32*(count+1) > len(data) {
 			return nil, fmt.Errorf("Boot catalog exceeds sector size limit")
 		}
 
-		data[offset] = 0x88                    // Boot Indicator (0x88 = Bootable)
-		data[offset+1] = byte(entry.Platform)  // Platform ID
-		data[offset+2] = byte(entry.Emulation) // Emulation Type
-		binary.LittleEndian.PutUint16(data[offset+4:], entry.LoadSegment)
-		binary.LittleEndian.PutUint16(data[offset+6:], entry.size)     // Size in 512-byte blocks
-		binary.LittleEndian.PutUint32(data[offset+8:], entry.location) // Location in 2048-byte sectors
+		// Section Header Entry
+		data[offset] = 0x90
+		if count == len(remaining) {
+			data[offset] = 0x91 // Final header
+		}
+		data[offset+1] = byte(remaining[0].Platform)
+		binary.LittleEndian.PutUint16(data[offset+2:], uint16(count))
+		offset += 32
 
-		offset += 32 // Move to next entry
+		for _, entry := range remaining[:count] {
+			marshalEntry(data[offset:offset+32], entry)
+			offset += 32
+		}
+		remaining = remaining[count:]
 	}
 
 	return data, nil
 }
 
+// marshalEntry writes an initial/default or section entry into the 32-byte buffer.
+func marshalEntry(data []byte, entry *ElToritoEntry) {
+	if entry.Bootable {
+		data[0] = 0x88 // Bootable
+	}
+	data[1] = byte(entry.Emulation)
+	binary.LittleEndian.PutUint16(data[2:4], entry.LoadSegment)
+	data[4] = byte(entry.PartitionType)
+	binary.LittleEndian.PutUint16(data[6:8], entry.size)      // Size in 512-byte virtual sectors
+	binary.LittleEndian.PutUint32(data[8:12], entry.location) // Location in 2048-byte sectors
+}
+
 // UnmarshalBinary decodes an El-Torito Boot Catalog from binary form
 func (et *ElTorito) UnmarshalBinary(data []byte) error {
 	if et.Logger != nil {
@@ -296,11 +329,13 @@ func (et *ElTorito) UnmarshalBinary(data []byte) error {
 
 	// Parse Boot Entries
 	sectionCount := 0
-	for offset := 32; offset < len(data); offset += 32 {
+	platform := Platform(data[1])
+	et.Platform = platform
+	for offset := 32; offset+32 <= len(data); offset += 32 {
 		entryData := data[offset : offset+32]
 
-		// Check for End of Catalog
-		if entryData[0] == 0x00 {
+		// Check for End of Catalog. Non-bootable section entries also start with 0x00 so only stop outside a section.
+		if entryData[0] == 0x00 && sectionCount == 0 {
 			if et.Logger != nil {
 				et.Logger.Debug("End of El Torito Boot Catalog reached", "offset", offset)
 			}
@@ -310,6 +345,7 @@ func (et *ElTorito) UnmarshalBinary(data []byte) error {
 		// Handle Section Headers
 		if entryData[0] == 0x90 || entryData[0] == 0x91 {
 			sectionCount = int(binary.LittleEndian.Uint16(entryData[2:4]))
+			platform = Platform(entryData[1])
 			if et.Logger != nil {
 				et.Logger.Debug("Section header found", "offset", offset, "entries", sectionCount)
 			}
@@ -318,7 +354,7 @@ func (et *ElTorito) UnmarshalBinary(data []byte) error {
 
 		// Parse Section Entries
 		if sectionCount > 0 {
-			entry := parseSectionEntry(entryData)
+			entry := parseSectionEntry(entryData, platform)
 			if et.Logger != nil {
 				et.Logger.Trace("Parsed section entry", "entry", entry)
 			}
@@ -328,7 +364,7 @@ func (et *ElTorito) UnmarshalBinary(data []byte) error {
 		}
 
 		// Parse Initial/Default Entry
-		entry := parseInitialEntry(entryData)
+		entry := parseInitialEntry(entryData, platform)
 		if et.Logger != nil {
 			et.Logger.Trace("Parsed initial entry", "entry", entry)
 		}
@@ -340,8 +376,22 @@ func (et *ElTorito) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+// NewElToritoEntry creates a boot catalog entry for a boot image recorded at the given location. The sector count is
+// the number of 512-byte virtual sectors loaded by the firmware.
+func NewElToritoEntry(platform Platform, emulation Emulation, loadSegment uint16, sectorCount uint16, location uint32, bootable bool) *ElToritoEntry {
+	return &ElToritoEntry{
+		Bootable:    bootable,
+		Platform:    platform,
+		Emulation:   emulation,
+		LoadSegment: loadSegment,
+		size:        sectorCount,
+		location:    location,
+	}
+}
+
 // ElToritoEntry represents a single entry in an El-Torito boot catalog.
 type ElToritoEntry struct {
+	Bootable      bool          // Whether the entry is marked bootable (0x88)
 	Platform      Platform      // Target platform
 	Emulation     Emulation     // Emulation mode
 	BootFile      string        // Path to the boot file
@@ -352,6 +402,16 @@ type ElToritoEntry struct {
 	location      uint32        // Location of the boot file in 2048-byte sectors
 }
 
+// Location returns the location of the boot image in 2048-byte sectors.
+func (e *ElToritoEntry) Location() uint32 {
+	return e.location
+}
+
+// SectorCount returns the number of 512-byte virtual sectors loaded from the boot image.
+func (e *ElToritoEntry) SectorCount() uint16 {
+	return e.size
+}
+
 // SectionHeader represents a header for grouping entries in the boot catalog.
 type SectionHeader struct {
 	Indicator byte     // Indicator byte (0x90 or 0x91 for the last section)
@@ -498,26 +558,21 @@ func IsElTorito(bootSystemIdentifier string) bool {
 	return trimmed == consts.EL_TORITO_BOOT_SYSTEM_ID
 }
 
-func parseInitialEntry(data []byte) *ElToritoEntry {
+func parseInitialEntry(data []byte, platform Platform) *ElToritoEntry {
 	return &ElToritoEntry{
-		Platform:      Platform(data[1]),
-		Emulation:     Emulation(data[2]),
-		LoadSegment:   binary.LittleEndian.Uint16(data[4:6]),
+		Bootable:      data[0] == 0x88,
+		Platform:      platform,
+		Emulation:     Emulation(data[1] & 0x0F),
+		LoadSegment:   binary.LittleEndian.Uint16(data[2:4]),
 		PartitionType: PartitionType(data[4]),
 		size:          binary.LittleEndian.Uint16(data[6:8]),
 		location:      binary.LittleEndian.Uint32(data[8:12]),
 	}
 }
 
-func parseSectionEntry(data []byte) *ElToritoEntry {
-	return &ElToritoEntry{
-		Platform:      Platform(data[1]),
-		Emulation:     Emulation(data[2]),
-		LoadSegment:   binary.LittleEndian.Uint16(data[4:6]),
-		PartitionType: PartitionType(data[4]),
-		size:          binary.LittleEndian.Uint16(data[6:8]),
-		location:      binary.LittleEndian.Uint32(data[8:12]),
-	}
+func parseSectionEntry(data []byte, platform Platform) *ElToritoEntry {
+	// Section entries share the layout of the initial entry, bits 4-7 of the media type byte carry extra flags
+	return parseInitialEntry(data, platform)
 }
 
 func parseValidationEntry(data []byte) error {
@@ -1,6 +1,7 @@
 package descriptor
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"github.com/bgrewell/iso-kit/pkg/consts"
@@ -26,7 +27,7 @@ func (d *SupplementaryVolumeDescriptor) DescriptorType() VolumeDescriptorType {
 }
 
 func (d *SupplementaryVolumeDescriptor) LocationOfPathTableL() uint32 {
-	return d.SupplementaryVolumeDescriptorBody.LocationOfTypeMPathTable
+	return d.SupplementaryVolumeDescriptorBody.LocationOfTypeLPathTable
 }
 
 func (d *SupplementaryVolumeDescriptor) LocationOfPathTableM() uint32 {
@@ -90,8 +91,14 @@ func (d *SupplementaryVolumeDescriptor) VolumeEffectiveDateTime() time.Time {
 }
 
 func (d *SupplementaryVolumeDescriptor) HasJoliet() bool {
-	// TODO: Should actually detect the Escape Sequences field to determine if Joliet is present.
-	return true
+	return d.IsJoliet()
+}
+
+// IsEnhanced returns true if the descriptor is an Enhanced Volume Descriptor as defined by ISO 9660:1999. EVDs are
+// recorded as version 2 supplementary descriptors, carry no escape sequences and describe a hierarchy whose
+// identifiers may be up to 207 bytes long and have no version number.
+func (d *SupplementaryVolumeDescriptor) IsEnhanced() bool {
+	return d.VolumeDescriptorVersion == consts.ISO9660_ENHANCED_VOLUME_DESC_VERSION && !d.IsJoliet()
 }
 
 func (d *SupplementaryVolumeDescriptor) HasRockRidge() bool {
@@ -334,18 +341,18 @@ func (svdb *SupplementaryVolumeDescriptorBody) Marshal() ([]byte, error) {
 	data[offset] = svdb.VolumeFlags
 	offset++
 
-	// 2. systemIdentifier: 32 bytes (UCS2).
-	sysID := encoding.EncodeUCS2BigEndian(svdb.SystemIdentifier)
+	// 2. systemIdentifier: 32 bytes (UCS2 for Joliet).
+	sysID := svdb.encodeString(svdb.SystemIdentifier, 32)
 	if len(sysID) > 32 {
-		return data[:], fmt.Errorf("systemIdentifier (%d bytes) exceeds 32 bytes after UCS2 encoding", len(sysID))
+		return data[:], fmt.Errorf("systemIdentifier (%d bytes) exceeds 32 bytes after encoding", len(sysID))
 	}
 	copy(data[offset:offset+len(sysID)], sysID)
 	offset += 32
 
-	// 3. volumeIdentifier: 32 bytes (UCS2).
-	volID := encoding.EncodeUCS2BigEndian(svdb.VolumeIdentifier)
+	// 3. volumeIdentifier: 32 bytes (UCS2 for Joliet).
+	volID := svdb.encodeString(svdb.VolumeIdentifier, 32)
 	if len(volID) > 32 {
-		return data[:], fmt.Errorf("volumeIdentifier (%d bytes) exceeds 32 bytes after UCS2 encoding", len(volID))
+		return data[:], fmt.Errorf("volumeIdentifier (%d bytes) exceeds 32 bytes after encoding", len(volID))
 	}
 	copy(data[offset:offset+len(volID)], volID)
 	offset += 32
@@ -409,58 +416,58 @@ func (svdb *SupplementaryVolumeDescriptorBody) Marshal() ([]byte, error) {
 	copy(data[offset:offset+34], rdBytes)
 	offset += 34
 
-	// 16. volumeSetIdentifier: 128 bytes (UCS2).
-	vsi := encoding.EncodeUCS2BigEndian(svdb.VolumeSetIdentifier)
+	// 16. volumeSetIdentifier: 128 bytes (UCS2 for Joliet).
+	vsi := svdb.encodeString(svdb.VolumeSetIdentifier, 128)
 	if len(vsi) > 128 {
-		return data[:], fmt.Errorf("volumeSetIdentifier exceeds 128 bytes after UCS2 encoding")
+		return data[:], fmt.Errorf("volumeSetIdentifier exceeds 128 bytes after encoding")
 	}
 	copy(data[offset:offset+len(vsi)], vsi)
 	offset += 128
 
-	// 17. publisherIdentifier: 128 bytes (UCS2).
-	pubID := encoding.EncodeUCS2BigEndian(svdb.PublisherIdentifier)
+	// 17. publisherIdentifier: 128 bytes (UCS2 for Joliet).
+	pubID := svdb.encodeString(svdb.PublisherIdentifier, 128)
 	if len(pubID) > 128 {
-		return data[:], fmt.Errorf("publisherIdentifier exceeds 128 bytes after UCS2 encoding")
+		return data[:], fmt.Errorf("publisherIdentifier exceeds 128 bytes after encoding")
 	}
 	copy(data[offset:offset+len(pubID)], pubID)
 	offset += 128
 
-	// 18. dataPreparerIdentifier: 128 bytes (UCS2).
-	dpID := encoding.EncodeUCS2BigEndian(svdb.DataPreparerIdentifier)
+	// 18. dataPreparerIdentifier: 128 bytes (UCS2 for Joliet).
+	dpID := svdb.encodeString(svdb.DataPreparerIdentifier, 128)
 	if len(dpID) > 128 {
-		return data[:], fmt.Errorf("dataPreparerIdentifier exceeds 128 bytes after UCS2 encoding")
+		return data[:], fmt.Errorf("dataPreparerIdentifier exceeds 128 bytes after encoding")
 	}
 	copy(data[offset:offset+len(dpID)], dpID)
 	offset += 128
 
-	// 19. applicationIdentifier: 128 bytes (UCS2).
-	appID := encoding.EncodeUCS2BigEndian(svdb.ApplicationIdentifier)
+	// 19. applicationIdentifier: 128 bytes (UCS2 for Joliet).
+	appID := svdb.encodeString(svdb.ApplicationIdentifier, 128)
 	if len(appID) > 128 {
-		return data[:], fmt.Errorf("applicationIdentifier exceeds 128 bytes after UCS2 encoding")
+		return data[:], fmt.Errorf("applicationIdentifier exceeds 128 bytes after encoding")
 	}
 	copy(data[offset:offset+len(appID)], appID)
 	offset += 128
 
-	// 20. copyrightFileIdentifier: 37 bytes (UCS2).
-	cfID := encoding.EncodeUCS2BigEndian(svdb.CopyrightFileIdentifier)
+	// 20. copyrightFileIdentifier: 37 bytes (UCS2 for Joliet).
+	cfID := svdb.encodeString(svdb.CopyrightFileIdentifier, 37)
 	if len(cfID) > 37 {
-		return data[:], fmt.Errorf("copyrightFileIdentifier exceeds 37 bytes after UCS2 encoding")
+		return data[:], fmt.Errorf("copyrightFileIdentifier exceeds 37 bytes after encoding")
 	}
 	copy(data[offset:offset+len(cfID)], cfID)
 	offset += 37
 
-	// 21. abstractFileIdentifier: 37 bytes (UCS2).
-	afID := encoding.EncodeUCS2BigEndian(svdb.AbstractFileIdentifier)
+	// 21. abstractFileIdentifier: 37 bytes (UCS2 for Joliet).
+	afID := svdb.encodeString(svdb.AbstractFileIdentifier, 37)
 	if len(afID) > 37 {
-		return data[:], fmt.Errorf("abstractFileIdentifier exceeds 37 bytes after UCS2 encoding")
+		return data[:], fmt.Errorf("abstractFileIdentifier exceeds 37 bytes after encoding")
 	}
 	copy(data[offset:offset+len(afID)], afID)
 	offset += 37
 
-	// 22. bibliographicFileIdentifier: 37 bytes (UCS2).
-	bfID := encoding.EncodeUCS2BigEndian(svdb.BibliographicFileIdentifier)
+	// 22. bibliographicFileIdentifier: 37 bytes (UCS2 for Joliet).
+	bfID := svdb.encodeString(svdb.BibliographicFileIdentifier, 37)
 	if len(bfID) > 37 {
-		return data[:], fmt.Errorf("bibliographicFileIdentifier exceeds 37 bytes after UCS2 encoding")
+		return data[:], fmt.Errorf("bibliographicFileIdentifier exceeds 37 bytes after encoding")
 	}
 	copy(data[offset:offset+len(bfID)], bfID)
 	offset += 37
@@ -731,6 +738,18 @@ func (svdb *SupplementaryVolumeDescriptorBody) Unmarshal(data []byte) error {
 	return nil
 }
 
+// encodeString encodes a fixed width string field, as UCS-2 for Joliet descriptors and padded with spaces otherwise.
+func (svdb *SupplementaryVolumeDescriptorBody) encodeString(s string, size int) []byte {
+	if svdb.IsJoliet() {
+		return encoding.EncodeUCS2BigEndian(s)
+	}
+	b := []byte(s)
+	if len(b) < size {
+		b = append(b, bytes.Repeat([]byte{' '}, size-len(b))...)
+	}
+	return b
+}
+
 // Check if the SVD is Joliet by inspecting the Escape Sequences
 func (svdb *SupplementaryVolumeDescriptorBody) IsJoliet() bool {
 	return string(svdb.EscapeSequences[:3]) == consts.JOLIET_LEVEL_1_ESCAPE ||
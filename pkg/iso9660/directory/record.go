@@ -83,6 +83,8 @@ type DirectoryRecord struct {
 	SystemUse []byte `json:"system_use"`
 	// RockRidge is a field to store Rock Ridge extensions if they exist
 	RockRidge *extensions.RockRidgeExtensions `json:"rock_ridge"`
+	// XA is a field to store the CD-ROM XA attributes recorded at the start of the System Use field, if present
+	XA *extensions.XAAttributes `json:"xa,omitempty"`
 	// Joliet is a field to store if this record is from a volume with Joliet extensions
 	Joliet bool `json:"joliet"`
 	// --- Fields that are not part of the ISO9660 object ---
@@ -146,7 +148,9 @@ func (dr *DirectoryRecord) IsSpecial() bool {
 	return dr.FileIdentifier == "\x00" || dr.FileIdentifier == "\x01"
 }
 
-// GetBestName retrieves the best file name
+// GetBestName retrieves the best file name. When Rock Ridge is enabled this is the complete NM name, assembled from
+// every NM entry including those recorded in continuation areas. NM entries flagged as naming the current or parent
+// directory only apply to the "." and ".." records and are ignored elsewhere.
 func (dr *DirectoryRecord) GetBestName(RockRidgeEnabled bool) string {
 
 	// Handle special cases 'root' or 'parent'
@@ -157,8 +161,10 @@ func (dr *DirectoryRecord) GetBestName(RockRidgeEnabled bool) string {
 		return ".."
 	}
 
-	if RockRidgeEnabled && dr.RockRidge != nil && dr.RockRidge.AlternateName != nil {
-		return *dr.RockRidge.AlternateName
+	if RockRidgeEnabled && dr.RockRidge != nil && dr.RockRidge.AlternateName != nil && *dr.RockRidge.AlternateName != "" {
+		if flags := dr.RockRidge.AlternateNameFlags; flags == nil || !(flags.Current || flags.Parent) {
+			return *dr.RockRidge.AlternateName
+		}
 	}
 	return dr.FileIdentifier // Default to ISO 9660 name
 }
@@ -182,8 +188,11 @@ func (dr *DirectoryRecord) GetOwnership(RockRidgeEnabled bool) (uid, gid *uint32
 	return nil, nil
 }
 
-// GetTimestamps retrieves creation & modification time
+// GetTimestamps retrieves creation & modification time. The recording date of the directory record is used for any
+// time stamp that is not recorded in a Rock Ridge TF entry.
 func (dr *DirectoryRecord) GetTimestamps(RockRidgeEnabled bool) (creation, modification time.Time) {
+	creation = dr.RecordingDateAndTime
+	modification = dr.RecordingDateAndTime
 	if RockRidgeEnabled && dr.RockRidge != nil {
 		if dr.RockRidge.CreationTime != nil {
 			creation = *dr.RockRidge.CreationTime
@@ -191,9 +200,6 @@ func (dr *DirectoryRecord) GetTimestamps(RockRidgeEnabled bool) (creation, modif
 		if dr.RockRidge.ModificationTime != nil {
 			modification = *dr.RockRidge.ModificationTime
 		}
-	} else {
-		creation = dr.RecordingDateAndTime
-		modification = dr.RecordingDateAndTime
 	}
 	return creation, modification
 }
@@ -218,10 +224,14 @@ func (dr *DirectoryRecord) Marshal() ([]byte, error) {
 	dataLenBytes := encoding.MarshalBothByteOrders32(dr.DataLength)
 	buf = append(buf, dataLenBytes[:]...)
 
-	// Recording Date and Time: 7 bytes
-	recTimeBytes, err := encoding.MarshalRecordingDateTime(dr.RecordingDateAndTime)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal RecordingDateAndTime: %w", err)
+	// Recording Date and Time: 7 bytes (all zero when not specified)
+	var recTimeBytes [7]byte
+	if !dr.RecordingDateAndTime.IsZero() {
+		var err error
+		recTimeBytes, err = encoding.MarshalRecordingDateTime(dr.RecordingDateAndTime)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal RecordingDateAndTime: %w", err)
+		}
 	}
 	buf = append(buf, recTimeBytes[:]...)
 
@@ -239,8 +249,12 @@ func (dr *DirectoryRecord) Marshal() ([]byte, error) {
 	buf = append(buf, volSeqBytes[:]...)
 
 	// File Identifier:
-	// First, the Length of File Identifier (1 byte)
+	// First, the Length of File Identifier (1 byte). Joliet identifiers are recorded as UCS-2 except for the special
+	// single byte identifiers used for the current and parent directories.
 	fileIDBytes := []byte(dr.FileIdentifier)
+	if dr.Joliet && !dr.IsSpecial() {
+		fileIDBytes = encoding.EncodeUCS2BigEndian(dr.FileIdentifier)
+	}
 	fiLen := uint8(len(fileIDBytes))
 	buf = append(buf, fiLen)
 
@@ -256,6 +270,9 @@ func (dr *DirectoryRecord) Marshal() ([]byte, error) {
 	buf = append(buf, dr.SystemUse...)
 
 	// Now that we know the total length, set the LengthOfDirectoryRecord.
+	if len(buf) > 255 {
+		return nil, fmt.Errorf("record length %d exceeds 255 bytes", len(buf))
+	}
 	recordLength := uint8(len(buf))
 	if recordLength == 0 {
 		return nil, fmt.Errorf("record length is zero")
@@ -264,6 +281,7 @@ func (dr *DirectoryRecord) Marshal() ([]byte, error) {
 
 	// (Optional) You might want to store recordLength into dr.LengthOfDirectoryRecord.
 	dr.LengthOfDirectoryRecord = recordLength
+	dr.LengthOfFileIdentifier = fiLen
 
 	return buf, nil
 }
@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/encoding"
 	"io/fs"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -40,6 +42,36 @@ const (
 	ROCK_RIDGE RockRidgeEntryType = "RR"
 )
 
+// TF flags (RRIP 4.1.6) selecting which time stamps are recorded and their format
+const (
+	TF_CREATION   = 0x01
+	TF_MODIFY     = 0x02
+	TF_ACCESS     = 0x04
+	TF_ATTRIBUTES = 0x08
+	TF_BACKUP     = 0x10
+	TF_EXPIRATION = 0x20
+	TF_EFFECTIVE  = 0x40
+	TF_LONG_FORM  = 0x80
+)
+
+// SL component record flags (RRIP 4.1.3.1)
+const (
+	SL_CONTINUE    = 0x01
+	SL_CURRENT     = 0x02
+	SL_PARENT      = 0x04
+	SL_ROOT        = 0x08
+	SL_VOLUME_ROOT = 0x10
+	SL_HOST        = 0x20
+)
+
+// NM flags (RRIP 4.1.4)
+const (
+	NM_CONTINUE = 0x01
+	NM_CURRENT  = 0x02
+	NM_PARENT   = 0x04
+	NM_HOST     = 0x20
+)
+
 type NameEntryFlags struct {
 	Continue  bool // Bit 0: Alternate Name continues in the next "NM" entry
 	Current   bool // Bit 1: Alternate Name refers to the current directory ("." in POSIX)
@@ -53,9 +85,11 @@ type NameEntryFlags struct {
 
 type RockRidgeExtensions struct {
 	// PX - POSIX file permissions (UID, GID, Mode)
-	UID         *uint32      // User ID
-	GID         *uint32      // Group ID
-	Permissions *fs.FileMode // File permissions
+	UID          *uint32      // User ID
+	GID          *uint32      // Group ID
+	Permissions  *fs.FileMode // File permissions
+	LinkCount    *uint32      // Number of links (st_nlink)
+	SerialNumber *uint32      // File serial number (st_ino), only recorded by RRIP 1.12 writers
 
 	// PN - Device number (if block/char device)
 	Major *uint32
@@ -78,10 +112,17 @@ type RockRidgeExtensions struct {
 	// RE - Relocated directory flag
 	IsRelocated *bool
 
-	// TF - Time stamps (creation, modification, access)
-	CreationTime     *time.Time
-	ModificationTime *time.Time
-	AccessTime       *time.Time
+	// TF - Time stamps (creation, modification, access, attribute change, backup, expiration, effective)
+	CreationTime        *time.Time
+	ModificationTime    *time.Time
+	AccessTime          *time.Time
+	AttributeChangeTime *time.Time
+	BackupTime          *time.Time
+	ExpirationTime      *time.Time
+	EffectiveTime       *time.Time
+	// LongFormTimeStamps records the time stamps in the 17-byte volume descriptor format instead of the 7-byte
+	// directory record format
+	LongFormTimeStamps bool
 
 	// SF - Sparse file info (if applicable)
 	IsSparse *bool
@@ -89,11 +130,31 @@ type RockRidgeExtensions struct {
 
 // HasRockRidge determines if any Rock Ridge extensions were set.
 func (r *RockRidgeExtensions) HasRockRidge() bool {
-	return r.UID != nil || r.GID != nil || r.Permissions != nil ||
+	return r.UID != nil || r.GID != nil || r.Permissions != nil || r.SerialNumber != nil ||
 		r.Major != nil || r.Minor != nil || r.SymlinkTarget != nil ||
 		r.AlternateName != nil || r.ChildLinkLBA != nil || r.ParentLinkLBA != nil ||
 		r.IsRelocated != nil || r.CreationTime != nil || r.ModificationTime != nil ||
-		r.AccessTime != nil || r.IsSparse != nil
+		r.AccessTime != nil || r.AttributeChangeTime != nil || r.BackupTime != nil || r.ExpirationTime != nil ||
+		r.EffectiveTime != nil || r.IsSparse != nil
+}
+
+// timeStamps returns the TF time stamp fields in the order they are recorded, keyed by their TF flag.
+func (r *RockRidgeExtensions) timeStamps() []struct {
+	flag byte
+	time **time.Time
+} {
+	return []struct {
+		flag byte
+		time **time.Time
+	}{
+		{TF_CREATION, &r.CreationTime},
+		{TF_MODIFY, &r.ModificationTime},
+		{TF_ACCESS, &r.AccessTime},
+		{TF_ATTRIBUTES, &r.AttributeChangeTime},
+		{TF_BACKUP, &r.BackupTime},
+		{TF_EXPIRATION, &r.ExpirationTime},
+		{TF_EFFECTIVE, &r.EffectiveTime},
+	}
 }
 
 func UnmarshalRockRidge(data []byte) (*RockRidgeExtensions, error) {
@@ -103,6 +164,9 @@ func UnmarshalRockRidge(data []byte) (*RockRidgeExtensions, error) {
 
 	rr := &RockRidgeExtensions{}
 	reader := bytes.NewReader(data)
+	var link symlinkDecoder
+	var name []byte
+	var nameContinued bool
 
 	for reader.Len() > 4 {
 		// Read signature (2-byte identifier)
@@ -134,8 +198,8 @@ func UnmarshalRockRidge(data []byte) (*RockRidgeExtensions, error) {
 		switch RockRidgeEntryType(entryType) {
 		case POSIX_FILE_PERMS: // PX (POSIX permissions)
 			if len(payload) >= 32 {
-				// Payload is the bytes from offset 4 to 36 (32 bytes) ... technically there are another 8 bytes for the
-				// file serial number but that generally hasn't been present so it is ignored here.
+				// Payload is the bytes from offset 4 to 36 (32 bytes). RRIP 1.12 writers such as xorriso add another 8
+				// bytes holding the file serial number (st_ino), which is decoded when present.
 				// Decode 8-byte File Mode (Permissions)
 				mode, err := encoding.UnmarshalUint32LSBMSB([8]byte(payload[0:8]))
 				if err == nil {
@@ -144,10 +208,11 @@ func UnmarshalRockRidge(data []byte) (*RockRidgeExtensions, error) {
 				}
 
 				// Decode 8-byte Number of Links
-				_, err = encoding.UnmarshalUint32LSBMSB([8]byte(payload[8:16]))
+				links, err := encoding.UnmarshalUint32LSBMSB([8]byte(payload[8:16]))
 				if err != nil {
 					return nil, errors.New("failed to parse PX link count")
 				}
+				rr.LinkCount = &links
 
 				// Decode 8-byte UID
 				uid, err := encoding.UnmarshalUint32LSBMSB([8]byte(payload[16:24]))
@@ -160,33 +225,46 @@ func UnmarshalRockRidge(data []byte) (*RockRidgeExtensions, error) {
 				if err == nil {
 					rr.GID = &gid
 				}
+
+				// Decode 8-byte File Serial Number
+				if len(payload) >= 40 {
+					serial, err := encoding.UnmarshalUint32LSBMSB([8]byte(payload[32:40]))
+					if err == nil {
+						rr.SerialNumber = &serial
+					}
+				}
 			}
 		case TIME_STAMPS: // TF (Timestamps)
-			// Rock Ridge TF entry uses a **variable-length encoding**
-			offset := 0
-			for offset < len(payload) {
-				flag := payload[offset]
-				offset++
-
-				if flag&0x01 != 0 && offset+7 <= len(payload) { // Creation time
-					seconds := int64(binary.LittleEndian.Uint32(payload[offset : offset+4]))
-					rr.CreationTime = new(time.Time)
-					*rr.CreationTime = time.Unix(seconds, 0)
-					offset += 7
+			// A single flags byte selects which time stamps follow, in flag order, and whether they use the 7-byte
+			// directory record format or the 17-byte (LONG_FORM) volume descriptor format
+			if len(payload) < 1 {
+				break
+			}
+			flags := payload[0]
+			rr.LongFormTimeStamps = flags&TF_LONG_FORM != 0
+			offset := 1
+			for _, ts := range rr.timeStamps() {
+				if flags&ts.flag == 0 {
+					continue
 				}
-
-				if flag&0x02 != 0 && offset+7 <= len(payload) { // Modification time
-					seconds := int64(binary.LittleEndian.Uint32(payload[offset : offset+4]))
-					rr.ModificationTime = new(time.Time)
-					*rr.ModificationTime = time.Unix(seconds, 0)
+				var stamp time.Time
+				var err error
+				if rr.LongFormTimeStamps {
+					if offset+17 > len(payload) {
+						break
+					}
+					stamp, err = encoding.UnmarshalDateTime([17]byte(payload[offset : offset+17]))
+					offset += 17
+				} else {
+					if offset+7 > len(payload) {
+						break
+					}
+					stamp, err = encoding.UnmarshalRecordingDateTime([7]byte(payload[offset : offset+7]))
 					offset += 7
 				}
-
-				if flag&0x04 != 0 && offset+7 <= len(payload) { // Access time
-					seconds := int64(binary.LittleEndian.Uint32(payload[offset : offset+4]))
-					rr.AccessTime = new(time.Time)
-					*rr.AccessTime = time.Unix(seconds, 0)
-					offset += 7
+				// Unspecified or malformed stamps are left unset rather than reported as the zero time
+				if err == nil && !stamp.IsZero() {
+					*ts.time = &stamp
 				}
 			}
 
@@ -200,23 +278,73 @@ func UnmarshalRockRidge(data []byte) (*RockRidgeExtensions, error) {
 			//   Bit 5: Historical - Historically contains the network node name.
 			//   Bit 6: Reserved - Should be set to 0.
 			//   Bit 7: Reserved - Should be set to 0.
+			//
+			// A name continued over several entries is assembled in order. The flags of the first entry are kept, with
+			// CONTINUE cleared once the last entry completes the name.
+			if len(payload) < 1 {
+				break
+			}
 			flags := payload[0]
-			rr.AlternateNameFlags = &NameEntryFlags{
-				Continue:  flags&0x01 > 0,
-				Current:   flags&0x02 > 0,
-				Parent:    flags&0x04 > 0,
-				Reserved1: flags&0x08 > 0,
-				Reserved2: flags&0x10 > 0,
-				Reserved3: flags&0x20 > 0,
-				Reserved4: flags&0x40 > 0,
-				Reserved5: flags&0x80 > 0,
+			if !nameContinued || rr.AlternateNameFlags == nil {
+				rr.AlternateNameFlags = &NameEntryFlags{
+					Continue:  flags&NM_CONTINUE > 0,
+					Current:   flags&NM_CURRENT > 0,
+					Parent:    flags&NM_PARENT > 0,
+					Reserved1: flags&0x08 > 0,
+					Reserved2: flags&0x10 > 0,
+					Reserved3: flags&NM_HOST > 0,
+					Reserved4: flags&0x40 > 0,
+					Reserved5: flags&0x80 > 0,
+				}
+				name = nil
+			}
+			switch {
+			case flags&NM_CURRENT != 0:
+				name = []byte(".")
+			case flags&NM_PARENT != 0:
+				name = []byte("..")
+			case flags&NM_HOST != 0:
+				// The network node name has no meaning here, the name content is not used
+			default:
+				name = append(name, payload[1:]...)
 			}
+			nameContinued = flags&NM_CONTINUE != 0
+			rr.AlternateNameFlags.Continue = nameContinued
 			rr.AlternateName = new(string)
-			*rr.AlternateName = string(payload[1:])
+			*rr.AlternateName = string(name)
+
+		case POSIX_DEVICE_NUM: // PN (Device number)
+			// The device number is recorded as two 32-bit halves, Dev_t High and Dev_t Low
+			if len(payload) < 16 {
+				break
+			}
+			high, err := encoding.UnmarshalUint32LSBMSB([8]byte(payload[0:8]))
+			if err != nil {
+				return nil, errors.New("failed to parse PN high device number")
+			}
+			low, err := encoding.UnmarshalUint32LSBMSB([8]byte(payload[8:16]))
+			if err != nil {
+				return nil, errors.New("failed to parse PN low device number")
+			}
+			major, minor := decodeDevice(high, low)
+			rr.Major = &major
+			rr.Minor = &minor
 
 		case SYMBOLIC_LINK: // SL (Symbolic link)
-			rr.SymlinkTarget = new(string)
-			*rr.SymlinkTarget = string(payload[1:]) // Skip flags byte
+			// The flags byte only carries CONTINUE, meaning the link is continued in the next SL entry. Component
+			// records follow, each made of flags, length and content.
+			if len(payload) < 1 {
+				break
+			}
+			if rr.SymlinkFlags == nil {
+				rr.SymlinkFlags = new(byte)
+				*rr.SymlinkFlags = payload[0]
+			}
+			if err := link.decode(payload[1:]); err != nil {
+				return nil, err
+			}
+			target := link.target()
+			rr.SymlinkTarget = &target
 		}
 	}
 
@@ -225,74 +353,311 @@ func UnmarshalRockRidge(data []byte) (*RockRidgeExtensions, error) {
 
 // MarshalRockRidge serializes Rock Ridge extension fields into ISO format.
 func MarshalRockRidge(rr *RockRidgeExtensions) ([]byte, error) {
+	entries, err := MarshalRockRidgeEntries(rr)
+	if err != nil {
+		return nil, err
+	}
+
 	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.Write(entry)
+	}
+
+	return buf.Bytes(), nil
+}
 
-	//TODO: Fix this whole function, there were a lot of errors with sizes and offsets
-	if rr.UID != nil && rr.GID != nil && rr.Permissions != nil {
-		buf.Write([]byte("PX"))           // Signature
-		buf.WriteByte(10 + 4)             // Length (10 bytes data + header)
-		buf.WriteByte(ROCK_RIDGE_VERSION) // Version
-		binary.Write(&buf, binary.LittleEndian, *rr.UID)
-		binary.Write(&buf, binary.LittleEndian, *rr.GID)
-		binary.Write(&buf, binary.LittleEndian, *rr.Permissions)
+// MarshalRockRidgeEntries serializes Rock Ridge extension fields into individual System Use entries. The entries are
+// returned separately so callers can split them between a directory record and a continuation area without breaking
+// an entry in two.
+func MarshalRockRidgeEntries(rr *RockRidgeExtensions) ([][]byte, error) {
+	var entries [][]byte
+
+	// PX - POSIX file attributes (RRIP 1991A layout without the serial number)
+	if rr.Permissions != nil {
+		links := uint32(1)
+		if rr.LinkCount != nil {
+			links = *rr.LinkCount
+		}
+		var uid, gid uint32
+		if rr.UID != nil {
+			uid = *rr.UID
+		}
+		if rr.GID != nil {
+			gid = *rr.GID
+		}
+		entry := newSystemUseEntry(string(POSIX_FILE_PERMS), 32)
+		mode := encoding.MarshalBothByteOrders32(marshalFileMode(*rr.Permissions))
+		linkBytes := encoding.MarshalBothByteOrders32(links)
+		uidBytes := encoding.MarshalBothByteOrders32(uid)
+		gidBytes := encoding.MarshalBothByteOrders32(gid)
+		copy(entry[4:12], mode[:])
+		copy(entry[12:20], linkBytes[:])
+		copy(entry[20:28], uidBytes[:])
+		copy(entry[28:36], gidBytes[:])
+		entries = append(entries, entry)
 	}
 
+	// PN - Device numbers
 	if rr.Major != nil && rr.Minor != nil {
-		buf.Write([]byte("PN"))           // Signature
-		buf.WriteByte(8 + 4)              // Length (8 bytes data + header)
-		buf.WriteByte(ROCK_RIDGE_VERSION) // Version
-		binary.Write(&buf, binary.LittleEndian, *rr.Major)
-		binary.Write(&buf, binary.LittleEndian, *rr.Minor)
+		entry := newSystemUseEntry(string(POSIX_DEVICE_NUM), 16)
+		devHigh, devLow := encodeDevice(*rr.Major, *rr.Minor)
+		high := encoding.MarshalBothByteOrders32(devHigh)
+		low := encoding.MarshalBothByteOrders32(devLow)
+		copy(entry[4:12], high[:])
+		copy(entry[12:20], low[:])
+		entries = append(entries, entry)
 	}
 
+	// SL - Symbolic link, split into as many entries as needed
 	if rr.SymlinkTarget != nil {
-		buf.Write([]byte("SL")) // Signature
-		buf.WriteByte(byte(len(*rr.SymlinkTarget) + 4))
-		buf.WriteByte(ROCK_RIDGE_VERSION)
-		buf.WriteString(*rr.SymlinkTarget)
+		slEntries, err := marshalSymlink(*rr.SymlinkTarget)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, slEntries...)
 	}
 
+	// NM - Alternate name, split into as many entries as needed
 	if rr.AlternateName != nil {
-		buf.Write([]byte("NM")) // Signature
-		buf.WriteByte(byte(len(*rr.AlternateName) + 4))
-		buf.WriteByte(ROCK_RIDGE_VERSION)
-		buf.WriteString(*rr.AlternateName)
+		name := []byte(*rr.AlternateName)
+		for {
+			chunk := name
+			if len(chunk) > MAX_SYSTEM_USE_PAYLOAD-1 {
+				chunk = chunk[:MAX_SYSTEM_USE_PAYLOAD-1]
+			}
+			name = name[len(chunk):]
+			entry := newSystemUseEntry(string(ALTERNATE_NAME), 1+len(chunk))
+			if len(name) > 0 {
+				entry[4] = NM_CONTINUE
+			}
+			copy(entry[5:], chunk)
+			entries = append(entries, entry)
+			if len(name) == 0 {
+				break
+			}
+		}
 	}
 
+	// CL - Child link
 	if rr.ChildLinkLBA != nil {
-		buf.Write([]byte("CL")) // Signature
-		buf.WriteByte(4 + 4)
-		buf.WriteByte(ROCK_RIDGE_VERSION)
-		binary.Write(&buf, binary.LittleEndian, *rr.ChildLinkLBA)
+		entry := newSystemUseEntry(string(CHILD_LINK), 8)
+		lba := encoding.MarshalBothByteOrders32(*rr.ChildLinkLBA)
+		copy(entry[4:12], lba[:])
+		entries = append(entries, entry)
 	}
 
+	// PL - Parent link
 	if rr.ParentLinkLBA != nil {
-		buf.Write([]byte("PL")) // Signature
-		buf.WriteByte(4 + 4)
-		buf.WriteByte(ROCK_RIDGE_VERSION)
-		binary.Write(&buf, binary.LittleEndian, *rr.ParentLinkLBA)
+		entry := newSystemUseEntry(string(PARENT_LINK), 8)
+		lba := encoding.MarshalBothByteOrders32(*rr.ParentLinkLBA)
+		copy(entry[4:12], lba[:])
+		entries = append(entries, entry)
 	}
 
+	// RE - Relocated directory
 	if rr.IsRelocated != nil && *rr.IsRelocated {
-		buf.Write([]byte("RE")) // Signature
-		buf.WriteByte(4)
-		buf.WriteByte(ROCK_RIDGE_VERSION)
+		entries = append(entries, newSystemUseEntry(string(RELOCATED_DIR), 0))
 	}
 
-	if rr.CreationTime != nil {
-		buf.Write([]byte("TF")) // Signature
-		buf.WriteByte(7 + 4)
-		buf.WriteByte(ROCK_RIDGE_VERSION)
-		binary.Write(&buf, binary.LittleEndian, uint32(rr.CreationTime.Unix()))
+	// TF - Time stamps using the 7-byte recording date and time format, or the 17-byte format when long form is set
+	var flags byte
+	var stamps []byte
+	for _, ts := range rr.timeStamps() {
+		if *ts.time == nil {
+			continue
+		}
+		if rr.LongFormTimeStamps {
+			b, err := encoding.MarshalDateTime(**ts.time)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal TF time stamp: %w", err)
+			}
+			stamps = append(stamps, b[:]...)
+		} else {
+			b, err := encoding.MarshalRecordingDateTime(**ts.time)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal TF time stamp: %w", err)
+			}
+			stamps = append(stamps, b[:]...)
+		}
+		flags |= ts.flag
+	}
+	if flags != 0 && rr.LongFormTimeStamps {
+		flags |= TF_LONG_FORM
 	}
+	if flags != 0 {
+		entry := newSystemUseEntry(string(TIME_STAMPS), 1+len(stamps))
+		entry[4] = flags
+		copy(entry[5:], stamps)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
 
-	if rr.IsSparse != nil && *rr.IsSparse {
-		buf.Write([]byte("SF")) // Signature
-		buf.WriteByte(4)
-		buf.WriteByte(ROCK_RIDGE_VERSION)
+// symlinkDecoder assembles a symbolic link target from the component records of one or more SL entries.
+type symlinkDecoder struct {
+	root    bool
+	parts   []string
+	partial []byte
+	pending bool
+}
+
+// decode consumes the component records of a single SL entry.
+func (d *symlinkDecoder) decode(records []byte) error {
+	for len(records) > 0 {
+		if len(records) < 2 || len(records) < 2+int(records[1]) {
+			return errors.New("truncated SL component record")
+		}
+		flags, content := records[0], records[2:2+int(records[1])]
+		records = records[2+len(content):]
+
+		switch {
+		case flags&(SL_ROOT|SL_VOLUME_ROOT) != 0:
+			d.root = true
+			d.parts = nil
+		case flags&SL_CURRENT != 0:
+			d.parts = append(d.parts, ".")
+		case flags&SL_PARENT != 0:
+			d.parts = append(d.parts, "..")
+		case flags&SL_HOST != 0:
+			// The host name component has no meaning on extraction
+		default:
+			// A component with CONTINUE set is completed by the next component record
+			d.partial = append(d.partial, content...)
+			d.pending = flags&SL_CONTINUE != 0
+			if !d.pending {
+				d.parts = append(d.parts, string(d.partial))
+				d.partial = nil
+			}
+		}
 	}
+	return nil
+}
 
-	return buf.Bytes(), nil
+// target returns the path assembled so far.
+func (d *symlinkDecoder) target() string {
+	parts := d.parts
+	if d.pending {
+		parts = append(parts[:len(parts):len(parts)], string(d.partial))
+	}
+	target := strings.Join(parts, "/")
+	if d.root {
+		return "/" + target
+	}
+	return target
+}
+
+// marshalSymlink encodes a symbolic link target as one or more SL entries made up of component records.
+func marshalSymlink(target string) ([][]byte, error) {
+	type component struct {
+		flags   byte
+		content []byte
+	}
+
+	var components []component
+	if strings.HasPrefix(target, "/") {
+		components = append(components, component{flags: SL_ROOT})
+	}
+	for _, part := range strings.Split(target, "/") {
+		switch part {
+		case "":
+			continue
+		case ".":
+			components = append(components, component{flags: SL_CURRENT})
+		case "..":
+			components = append(components, component{flags: SL_PARENT})
+		default:
+			// Components that do not fit in a single entry are split using the CONTINUE flag. Each record has a two
+			// byte header and the entry payload starts with the SL flags byte.
+			content := []byte(part)
+			for limit := MAX_SYSTEM_USE_PAYLOAD - 3; len(content) > limit; content = content[limit:] {
+				components = append(components, component{flags: SL_CONTINUE, content: content[:limit]})
+			}
+			components = append(components, component{content: content})
+		}
+	}
+	if len(components) == 0 {
+		return nil, fmt.Errorf("invalid symbolic link target %q", target)
+	}
+
+	var entries [][]byte
+	var payload []byte
+	for _, c := range components {
+		record := append([]byte{c.flags, byte(len(c.content))}, c.content...)
+		if len(payload)+len(record) > MAX_SYSTEM_USE_PAYLOAD-1 {
+			entry := newSystemUseEntry(string(SYMBOLIC_LINK), 1+len(payload))
+			entry[4] = SL_CONTINUE
+			copy(entry[5:], payload)
+			entries = append(entries, entry)
+			payload = nil
+		}
+		payload = append(payload, record...)
+	}
+	entry := newSystemUseEntry(string(SYMBOLIC_LINK), 1+len(payload))
+	copy(entry[5:], payload)
+	entries = append(entries, entry)
+
+	return entries, nil
+}
+
+// decodeDevice returns the major and minor numbers of a PN entry. Writers either record a 64-bit dev_t split in two
+// halves, leaving the high half zero for all but the largest device numbers, or record the major number in the high
+// half and the minor number in the low half. As in the Linux kernel, a non-zero high half is read as the split form and
+// otherwise the low half is decoded as a dev_t.
+func decodeDevice(high, low uint32) (major, minor uint32) {
+	if high != 0 {
+		return high, low
+	}
+	return (low >> 8) & 0xfff, (low & 0xff) | ((low >> 12) & 0xfff00)
+}
+
+// encodeDevice returns the halves of a PN entry for the given major and minor numbers, using the split form read by
+// decodeDevice. Major number zero can not be told apart from the dev_t form so it is recorded as a dev_t.
+func encodeDevice(major, minor uint32) (high, low uint32) {
+	if major != 0 {
+		return major, minor
+	}
+	return 0, (minor & 0xff) | ((minor & 0xfff00) << 12)
+}
+
+// marshalFileMode converts an fs.FileMode into the POSIX st_mode value recorded in a PX entry. It is the inverse of
+// parseFileMode.
+func marshalFileMode(fileMode fs.FileMode) uint32 {
+	var mode uint32
+
+	// File type bits
+	switch {
+	case fileMode&fs.ModeSocket != 0:
+		mode |= 0xC000
+	case fileMode&fs.ModeSymlink != 0:
+		mode |= 0xA000
+	case fileMode&fs.ModeCharDevice != 0:
+		mode |= 0x2000
+	case fileMode&fs.ModeDevice != 0:
+		mode |= 0x6000
+	case fileMode&fs.ModeDir != 0:
+		mode |= 0x4000
+	case fileMode&fs.ModeNamedPipe != 0:
+		mode |= 0x1000
+	default:
+		mode |= 0x8000
+	}
+
+	// Permission bits map directly
+	mode |= uint32(fileMode.Perm())
+
+	// Special mode bits
+	if fileMode&os.ModeSetuid != 0 {
+		mode |= 0x0800
+	}
+	if fileMode&os.ModeSetgid != 0 {
+		mode |= 0x0400
+	}
+	if fileMode&os.ModeSticky != 0 {
+		mode |= 0x0200
+	}
+
+	return mode
 }
 
 // parseFileMode converts a 32-bit unsigned integer into an fs.FileMode struct
@@ -310,7 +675,7 @@ func parseFileMode(mode uint32) fs.FileMode {
 	case 0x6000:
 		fileMode |= fs.ModeDevice
 	case 0x2000:
-		fileMode |= fs.ModeCharDevice
+		fileMode |= fs.ModeDevice | fs.ModeCharDevice
 	case 0x4000:
 		fileMode |= fs.ModeDir
 	case 0x1000:
@@ -0,0 +1,141 @@
+package extensions
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/iso9660/encoding"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeStamps(t *testing.T) {
+	zone := time.FixedZone("", -5*60*60)
+	created := time.Date(2021, 3, 4, 5, 6, 7, 0, zone)
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, zone)
+	accessed := time.Date(2024, 6, 7, 8, 9, 10, 0, zone)
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, longForm := range []bool{false, true} {
+		data, err := MarshalRockRidge(&RockRidgeExtensions{
+			CreationTime:       &created,
+			ModificationTime:   &modified,
+			AccessTime:         &accessed,
+			ExpirationTime:     &expires,
+			LongFormTimeStamps: longForm,
+		})
+		require.NoError(t, err)
+
+		rr, err := UnmarshalRockRidge(data)
+		require.NoError(t, err)
+		require.Equal(t, longForm, rr.LongFormTimeStamps)
+		require.True(t, created.Equal(*rr.CreationTime))
+		require.True(t, modified.Equal(*rr.ModificationTime))
+		require.True(t, accessed.Equal(*rr.AccessTime))
+		require.True(t, expires.Equal(*rr.ExpirationTime))
+		require.Nil(t, rr.AttributeChangeTime)
+		require.Nil(t, rr.BackupTime)
+		require.Nil(t, rr.EffectiveTime)
+
+		// The GMT offset of the stamp is kept
+		_, offset := rr.ModificationTime.Zone()
+		require.Equal(t, -5*60*60, offset)
+	}
+}
+
+func TestSymlinkTarget(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	for _, target := range []string{"/usr/../lib/./x", "../share/doc", "./" + long + "/y"} {
+		data, err := MarshalRockRidge(&RockRidgeExtensions{SymlinkTarget: &target})
+		require.NoError(t, err)
+
+		rr, err := UnmarshalRockRidge(data)
+		require.NoError(t, err)
+		require.NotNil(t, rr.SymlinkTarget)
+		require.Equal(t, target, *rr.SymlinkTarget)
+	}
+
+	// A component record running past the end of the entry is rejected
+	_, err := UnmarshalRockRidge([]byte{'S', 'L', 9, 1, 0, 0, 5, 'a', 'b'})
+	require.Error(t, err)
+}
+
+func TestAlternateName(t *testing.T) {
+	name := strings.Repeat("long-name-", 40)
+	data, err := MarshalRockRidge(&RockRidgeExtensions{AlternateName: &name})
+	require.NoError(t, err)
+	rr, err := UnmarshalRockRidge(data)
+	require.NoError(t, err)
+	require.Equal(t, name, *rr.AlternateName)
+	require.False(t, rr.AlternateNameFlags.Continue)
+
+	// A name whose last entry is still continued is incomplete
+	rr, err = UnmarshalRockRidge([]byte{'N', 'M', 6, 1, NM_CONTINUE, 'a'})
+	require.NoError(t, err)
+	require.Equal(t, "a", *rr.AlternateName)
+	require.True(t, rr.AlternateNameFlags.Continue)
+
+	// CURRENT and PARENT name the directory itself and its parent
+	rr, err = UnmarshalRockRidge([]byte{'N', 'M', 5, 1, NM_PARENT})
+	require.NoError(t, err)
+	require.Equal(t, "..", *rr.AlternateName)
+	require.True(t, rr.AlternateNameFlags.Parent)
+}
+
+func TestDeviceNumbers(t *testing.T) {
+	for _, dev := range [][2]uint32{{8, 1}, {0, 300}, {259, 1048575}} {
+		major, minor := dev[0], dev[1]
+		data, err := MarshalRockRidge(&RockRidgeExtensions{Major: &major, Minor: &minor})
+		require.NoError(t, err)
+		rr, err := UnmarshalRockRidge(data)
+		require.NoError(t, err)
+		require.Equal(t, major, *rr.Major)
+		require.Equal(t, minor, *rr.Minor)
+	}
+
+	// A 64-bit dev_t with an empty high half, as recorded by mkisofs for /dev/tty1
+	high := encoding.MarshalBothByteOrders32(0)
+	low := encoding.MarshalBothByteOrders32(4<<8 | 1)
+	rr, err := UnmarshalRockRidge(append(append([]byte{'P', 'N', 20, 1}, high[:]...), low[:]...))
+	require.NoError(t, err)
+	require.Equal(t, uint32(4), *rr.Major)
+	require.Equal(t, uint32(1), *rr.Minor)
+}
+
+func TestSerialNumber(t *testing.T) {
+	// RRIP 1.12 PX entry as written by xorriso, with the file serial number after the GID
+	entry := []byte{'P', 'X', 44, 1}
+	for _, v := range []uint32{0o100644, 2, 0, 0, 1234} {
+		field := encoding.MarshalBothByteOrders32(v)
+		entry = append(entry, field[:]...)
+	}
+	rr, err := UnmarshalRockRidge(entry)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), *rr.LinkCount)
+	require.Equal(t, uint32(1234), *rr.SerialNumber)
+
+	// RRIP 1991A entries have no serial number
+	short := append([]byte{}, entry[:36]...)
+	short[2] = 36
+	rr, err = UnmarshalRockRidge(short)
+	require.NoError(t, err)
+	require.Nil(t, rr.SerialNumber)
+}
+
+func TestXAAttributes(t *testing.T) {
+	// Rock Ridge entries follow the XA information on discs carrying both
+	data := append([]byte{0, 1, 0, 2, 0x0D, 0x55, 'X', 'A', 3, 0, 0, 0, 0, 0}, 'N', 'M', 8, 1, 0, 'a', '.', 'b')
+	xa, entries := UnmarshalXA(data)
+	require.NotNil(t, xa)
+	require.Equal(t, uint16(1), xa.GroupID)
+	require.Equal(t, uint16(2), xa.UserID)
+	require.Equal(t, uint8(3), xa.FileNumber)
+	require.True(t, xa.IsForm1())
+	require.False(t, xa.IsForm2())
+	rr, err := UnmarshalRockRidge(entries)
+	require.NoError(t, err)
+	require.Equal(t, "a.b", *rr.AlternateName)
+
+	xa, entries = UnmarshalXA(entries)
+	require.Nil(t, xa)
+	require.Len(t, entries, 8)
+}
@@ -0,0 +1,300 @@
+package extensions
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/encoding"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/info"
+	"io"
+	"time"
+)
+
+const (
+	// MAX_SYSTEM_USE_PAYLOAD is the largest payload a single System Use entry can carry (255 byte entry - 4 byte header)
+	MAX_SYSTEM_USE_PAYLOAD = 251
+	// CONTINUATION_ENTRY_LENGTH is the length of a SUSP CE entry
+	CONTINUATION_ENTRY_LENGTH = 28
+
+	// Descriptor and source recorded in the ER entry for RRIP_1991A
+	ROCK_RIDGE_DESCRIPTOR = "THE ROCK RIDGE INTERCHANGE PROTOCOL PROVIDES SUPPORT FOR POSIX FILE SYSTEM SEMANTICS"
+	ROCK_RIDGE_SOURCE     = "PLEASE CONTACT DISC PUBLISHER FOR SPECIFICATION SOURCE.  SEE PUBLISHER IDENTIFIER IN PRIMARY VOLUME DESCRIPTOR FOR CONTACT INFORMATION."
+)
+
+type SystemUseEntryType string
+
+const (
+	// Continuation area (SUSP 5.1)
+	SUSP_CONTINUATION_AREA SystemUseEntryType = "CE"
+	// Padding field (SUSP 5.2)
+	SUSP_PADDING SystemUseEntryType = "PD"
+	// System use sharing protocol indicator (SUSP 5.3)
+	SUSP_SHARING_PROTOCOL SystemUseEntryType = "SP"
+	// System use sharing protocol terminator (SUSP 5.4)
+	SUSP_TERMINATOR SystemUseEntryType = "ST"
+	// Extensions reference (SUSP 5.5)
+	SUSP_EXTENSIONS_REFERENCE SystemUseEntryType = "ER"
+	// Extension selector (SUSP 5.6)
+	SUSP_EXTENSION_SELECTOR SystemUseEntryType = "ES"
+)
+
+// newSystemUseEntry allocates a System Use entry with its 4-byte header filled in and room for payloadLen bytes.
+func newSystemUseEntry(signature string, payloadLen int) []byte {
+	entry := make([]byte, 4+payloadLen)
+	copy(entry[0:2], signature)
+	entry[2] = byte(len(entry))
+	entry[3] = ROCK_RIDGE_VERSION
+	return entry
+}
+
+// MarshalSharingProtocolEntry returns the SP entry that must be the first entry of the root directory's "." record.
+func MarshalSharingProtocolEntry() []byte {
+	entry := newSystemUseEntry(string(SUSP_SHARING_PROTOCOL), 3)
+	entry[4] = 0xBE
+	entry[5] = 0xEF
+	entry[6] = 0 // LEN_SKP
+	return entry
+}
+
+// MarshalExtensionsReferenceEntry returns the ER entry identifying the Rock Ridge extension.
+func MarshalExtensionsReferenceEntry() []byte {
+	id, des, src := ROCK_RIDGE_IDENTIFIER, ROCK_RIDGE_DESCRIPTOR, ROCK_RIDGE_SOURCE
+	entry := newSystemUseEntry(string(SUSP_EXTENSIONS_REFERENCE), 4+len(id)+len(des)+len(src))
+	entry[4] = byte(len(id))
+	entry[5] = byte(len(des))
+	entry[6] = byte(len(src))
+	entry[7] = ROCK_RIDGE_VERSION
+	offset := 8
+	offset += copy(entry[offset:], id)
+	offset += copy(entry[offset:], des)
+	copy(entry[offset:], src)
+	return entry
+}
+
+// MarshalContinuationEntry returns a CE entry pointing at length bytes starting at offset within the given block.
+func MarshalContinuationEntry(block, offset, length uint32) []byte {
+	entry := newSystemUseEntry(string(SUSP_CONTINUATION_AREA), 24)
+	b := encoding.MarshalBothByteOrders32(block)
+	o := encoding.MarshalBothByteOrders32(offset)
+	l := encoding.MarshalBothByteOrders32(length)
+	copy(entry[4:12], b[:])
+	copy(entry[12:20], o[:])
+	copy(entry[20:28], l[:])
+	return entry
+}
+
+// ContinuationArea holds System Use entries that did not fit in a directory record and are referenced by a CE entry.
+type ContinuationArea struct {
+	// Block is the logical block the area is recorded in
+	Block uint32 `json:"block"`
+	// BlockOffset is the byte offset of the area within the block
+	BlockOffset uint32 `json:"block_offset"`
+	// Data is the raw System Use entries recorded in the area
+	Data []byte `json:"data"`
+	// Owner is the name of the directory record that references the area
+	Owner string `json:"owner"`
+}
+
+func (ca *ContinuationArea) Type() string {
+	return "Continuation Area"
+}
+
+func (ca *ContinuationArea) Name() string {
+	return fmt.Sprintf("Continuation Area (%s)", ca.Owner)
+}
+
+func (ca *ContinuationArea) Description() string {
+	return ""
+}
+
+func (ca *ContinuationArea) Properties() map[string]interface{} {
+	return map[string]interface{}{
+		"Block":  ca.Block,
+		"Offset": ca.BlockOffset,
+		"Length": len(ca.Data),
+	}
+}
+
+func (ca *ContinuationArea) Offset() int64 {
+	return int64(ca.Block)*consts.ISO9660_SECTOR_SIZE + int64(ca.BlockOffset)
+}
+
+func (ca *ContinuationArea) Size() int {
+	return len(ca.Data)
+}
+
+func (ca *ContinuationArea) GetObjects() []info.ImageObject {
+	return []info.ImageObject{ca}
+}
+
+func (ca *ContinuationArea) Marshal() ([]byte, error) {
+	return ca.Data, nil
+}
+
+// ContinuationReference is the location of a continuation area as recorded in a CE entry.
+type ContinuationReference struct {
+	Block  uint32
+	Offset uint32
+	Length uint32
+}
+
+// parseContinuationEntry decodes the payload of a CE entry.
+func parseContinuationEntry(entry []byte) (ContinuationReference, error) {
+	if len(entry) < CONTINUATION_ENTRY_LENGTH {
+		return ContinuationReference{}, fmt.Errorf("CE entry too short: %d bytes", len(entry))
+	}
+	block, err := encoding.UnmarshalUint32LSBMSB([8]byte(entry[4:12]))
+	if err != nil {
+		return ContinuationReference{}, fmt.Errorf("invalid CE block: %w", err)
+	}
+	offset, err := encoding.UnmarshalUint32LSBMSB([8]byte(entry[12:20]))
+	if err != nil {
+		return ContinuationReference{}, fmt.Errorf("invalid CE offset: %w", err)
+	}
+	length, err := encoding.UnmarshalUint32LSBMSB([8]byte(entry[20:28]))
+	if err != nil {
+		return ContinuationReference{}, fmt.Errorf("invalid CE length: %w", err)
+	}
+	ce := ContinuationReference{Block: block, Offset: offset, Length: length}
+	// A continuation area never crosses the end of its logical block
+	if uint64(offset)+uint64(length) > consts.ISO9660_SECTOR_SIZE {
+		return ContinuationReference{}, fmt.Errorf("CE area of %d bytes at offset %d exceeds the logical block", length, offset)
+	}
+	return ce, nil
+}
+
+// ReadContinuationArea reads the continuation area a CE entry points at from r.
+func ReadContinuationArea(r io.ReaderAt, ce ContinuationReference) ([]byte, error) {
+	if uint64(ce.Offset)+uint64(ce.Length) > consts.ISO9660_SECTOR_SIZE {
+		return nil, fmt.Errorf("CE area of %d bytes at offset %d exceeds the logical block", ce.Length, ce.Offset)
+	}
+	area := make([]byte, ce.Length)
+	if _, err := r.ReadAt(area, int64(ce.Block)*consts.ISO9660_SECTOR_SIZE+int64(ce.Offset)); err != nil {
+		return nil, fmt.Errorf("failed to read continuation area: %w", err)
+	}
+	return area, nil
+}
+
+// walkSystemUseEntries calls fn with the signature and bytes of every entry in data until an ST entry or the end of
+// the data. The entry slices alias data so fn may modify them in place.
+func walkSystemUseEntries(data []byte, fn func(signature string, entry []byte) error) error {
+	for offset := 0; offset+4 <= len(data); {
+		length := int(data[offset+2])
+		if length < 4 || offset+length > len(data) {
+			// Padding or a malformed entry ends the System Use area
+			return nil
+		}
+		signature := string(data[offset : offset+2])
+		if signature == string(SUSP_TERMINATOR) {
+			return nil
+		}
+		if err := fn(signature, data[offset:offset+length]); err != nil {
+			return err
+		}
+		offset += length
+	}
+	return nil
+}
+
+// ExtensionIdentifiers returns the identifiers of the extensions referenced by the ER entries in the System Use data.
+func ExtensionIdentifiers(data []byte) []string {
+	var ids []string
+	walkSystemUseEntries(data, func(signature string, entry []byte) error {
+		if signature == string(SUSP_EXTENSIONS_REFERENCE) && len(entry) >= 8 && 8+int(entry[4]) <= len(entry) {
+			ids = append(ids, string(entry[8:8+int(entry[4])]))
+		}
+		return nil
+	})
+	return ids
+}
+
+// ReadSystemUse returns the System Use entries of a directory record followed by the entries of the continuation areas
+// its CE entries point at, read from r. The CE entries themselves are dropped so the result can be decoded as if every
+// entry had been recorded in the directory record.
+func ReadSystemUse(data []byte, r io.ReaderAt) ([]byte, error) {
+	var entries []byte
+	visited := make(map[ContinuationReference]bool)
+	for area := data; area != nil; {
+		var next *ContinuationReference
+		err := walkSystemUseEntries(area, func(signature string, entry []byte) error {
+			if signature != string(SUSP_CONTINUATION_AREA) {
+				entries = append(entries, entry...)
+				return nil
+			}
+			ce, err := parseContinuationEntry(entry)
+			if err != nil {
+				return err
+			}
+			next = &ce
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		area = nil
+		if next == nil {
+			break
+		}
+		if visited[*next] || len(visited) > consts.ISO9660_SECTOR_SIZE {
+			return nil, errors.New("continuation areas form a loop")
+		}
+		visited[*next] = true
+		if area, err = ReadContinuationArea(r, *next); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// UpdateTimeStamps rewrites the modification, access and attribute change stamps of every TF entry in the System Use
+// data in place, keeping the short or long form the entry was recorded with. The CE entries found in data are
+// returned so the caller can update the continuation areas as well.
+func UpdateTimeStamps(data []byte, t time.Time) ([]ContinuationReference, error) {
+	short, err := encoding.MarshalRecordingDateTime(t)
+	if err != nil {
+		return nil, err
+	}
+	long, err := encoding.MarshalDateTime(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var continuations []ContinuationReference
+	err = walkSystemUseEntries(data, func(signature string, entry []byte) error {
+		switch signature {
+		case string(SUSP_CONTINUATION_AREA):
+			ce, err := parseContinuationEntry(entry)
+			if err != nil {
+				return err
+			}
+			continuations = append(continuations, ce)
+		case string(TIME_STAMPS):
+			if len(entry) < 5 {
+				return nil
+			}
+			flags := entry[4]
+			stamp := short[:]
+			if flags&TF_LONG_FORM != 0 {
+				stamp = long[:]
+			}
+			offset := 5
+			for bit := byte(0); bit < 7; bit++ {
+				if flags&(1<<bit) == 0 {
+					continue
+				}
+				if offset+len(stamp) > len(entry) {
+					return fmt.Errorf("TF entry too short for its flags")
+				}
+				switch 1 << bit {
+				case TF_MODIFY, TF_ACCESS, TF_ATTRIBUTES:
+					copy(entry[offset:], stamp)
+				}
+				offset += len(stamp)
+			}
+		}
+		return nil
+	})
+
+	return continuations, err
+}
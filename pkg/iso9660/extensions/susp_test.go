@@ -0,0 +1,37 @@
+package extensions
+
+import (
+	"bytes"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/encoding"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// continuationEntry returns a CE entry pointing at the given area.
+func continuationEntry(block, offset, length uint32) []byte {
+	entry := []byte{'C', 'E', CONTINUATION_ENTRY_LENGTH, 1}
+	for _, v := range []uint32{block, offset, length} {
+		both := encoding.MarshalBothByteOrders32(v)
+		entry = append(entry, both[:]...)
+	}
+	return entry
+}
+
+func TestReadSystemUse(t *testing.T) {
+	// The continuation area at block 1, offset 100 holds an NM entry
+	image := make([]byte, 2*2048)
+	nm := []byte{'N', 'M', 9, 1, 0, 'n', 'a', 'm', 'e'}
+	copy(image[2048+100:], nm)
+	data := append([]byte{'P', 'X', 4, 1}, continuationEntry(1, 100, uint32(len(nm)))...)
+	entries, err := ReadSystemUse(data, bytes.NewReader(image))
+	require.NoError(t, err)
+	require.Equal(t, append([]byte{'P', 'X', 4, 1}, nm...), entries)
+
+	// Areas crossing the end of their logical block are rejected rather than allocated
+	for _, ce := range [][3]uint32{{1, 0, 0xFFFFFFFF}, {1, 2000, 100}, {1, 0xFFFFFFFF, 2}} {
+		_, err := ReadSystemUse(continuationEntry(ce[0], ce[1], ce[2]), bytes.NewReader(image))
+		require.ErrorContains(t, err, "exceeds the logical block")
+	}
+	_, err = ReadContinuationArea(bytes.NewReader(image), ContinuationReference{Block: 1, Length: 0x80000000})
+	require.ErrorContains(t, err, "exceeds the logical block")
+}
@@ -0,0 +1,108 @@
+package extensions
+
+import (
+	"encoding/binary"
+	"io/fs"
+)
+
+const (
+	// XA_SIGNATURE identifies the CD-ROM XA system use information at bytes 6-7 of the System Use field
+	XA_SIGNATURE = "XA"
+	// XA_RECORD_LENGTH is the length of the CD-ROM XA system use information
+	XA_RECORD_LENGTH = 14
+)
+
+// CD-ROM XA attribute bits
+const (
+	XA_OWNER_READ    = 0x0001
+	XA_OWNER_EXECUTE = 0x0004
+	XA_GROUP_READ    = 0x0010
+	XA_GROUP_EXECUTE = 0x0040
+	XA_WORLD_READ    = 0x0100
+	XA_WORLD_EXECUTE = 0x0400
+	// The file is recorded in Mode 2 Form 1 sectors
+	XA_MODE2_FORM1 = 0x0800
+	// The file is recorded in Mode 2 Form 2 sectors
+	XA_MODE2_FORM2 = 0x1000
+	// The file holds interleaved Form 1 and Form 2 sectors
+	XA_INTERLEAVED = 0x2000
+	// The file is a CD-DA audio track
+	XA_CDDA = 0x4000
+	// The record describes a directory
+	XA_DIRECTORY = 0x8000
+)
+
+// XAAttributes is the CD-ROM XA system use information recorded at the start of the System Use field of every
+// directory record on VideoCD, PlayStation and other Mode 2 discs. Its fields are big-endian.
+type XAAttributes struct {
+	// Owner group and user identifiers
+	GroupID uint16 `json:"group_id"`
+	UserID  uint16 `json:"user_id"`
+	// Attributes holds the permission bits and the XA_MODE2_FORM1, XA_MODE2_FORM2, XA_INTERLEAVED, XA_CDDA and
+	// XA_DIRECTORY flags
+	Attributes uint16 `json:"attributes"`
+	// FileNumber identifies the file in the subheader of its sectors, used to pick out interleaved streams
+	FileNumber uint8 `json:"file_number"`
+}
+
+// UnmarshalXA decodes the CD-ROM XA system use information at the start of a System Use field. It returns nil and the
+// data unchanged when there is none, and otherwise the System Use entries that follow it.
+func UnmarshalXA(data []byte) (*XAAttributes, []byte) {
+	if len(data) < XA_RECORD_LENGTH || string(data[6:8]) != XA_SIGNATURE {
+		return nil, data
+	}
+	xa := &XAAttributes{
+		GroupID:    binary.BigEndian.Uint16(data[0:2]),
+		UserID:     binary.BigEndian.Uint16(data[2:4]),
+		Attributes: binary.BigEndian.Uint16(data[4:6]),
+		FileNumber: data[8],
+	}
+	return xa, data[XA_RECORD_LENGTH:]
+}
+
+// IsForm1 returns true if the file is recorded in Mode 2 Form 1 sectors.
+func (xa *XAAttributes) IsForm1() bool {
+	return xa.Attributes&XA_MODE2_FORM1 != 0
+}
+
+// IsForm2 returns true if the file is recorded in Mode 2 Form 2 sectors, whose 2324 bytes of user data are not
+// protected by error correction. Directory records give the size of such files in 2048-byte sectors.
+func (xa *XAAttributes) IsForm2() bool {
+	return xa.Attributes&XA_MODE2_FORM2 != 0
+}
+
+// IsInterleaved returns true if the file holds interleaved Form 1 and Form 2 sectors.
+func (xa *XAAttributes) IsInterleaved() bool {
+	return xa.Attributes&XA_INTERLEAVED != 0
+}
+
+// IsCDDA returns true if the record describes a CD-DA audio track.
+func (xa *XAAttributes) IsCDDA() bool {
+	return xa.Attributes&XA_CDDA != 0
+}
+
+// IsDirectory returns true if the record describes a directory.
+func (xa *XAAttributes) IsDirectory() bool {
+	return xa.Attributes&XA_DIRECTORY != 0
+}
+
+// Permissions returns the read and execute permission bits of the attributes. XA does not record write permission.
+func (xa *XAAttributes) Permissions() fs.FileMode {
+	var mode fs.FileMode
+	for _, bit := range []struct {
+		attribute uint16
+		mode      fs.FileMode
+	}{
+		{XA_OWNER_READ, 0o400},
+		{XA_OWNER_EXECUTE, 0o100},
+		{XA_GROUP_READ, 0o040},
+		{XA_GROUP_EXECUTE, 0o010},
+		{XA_WORLD_READ, 0o004},
+		{XA_WORLD_EXECUTE, 0o001},
+	} {
+		if xa.Attributes&bit.attribute != 0 {
+			mode |= bit.mode
+		}
+	}
+	return mode
+}
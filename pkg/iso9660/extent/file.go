@@ -2,6 +2,7 @@ package extent
 
 import (
 	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/cdrom"
 	"github.com/bgrewell/iso-kit/pkg/consts"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/info"
 	"io"
@@ -13,7 +14,15 @@ type FileExtent struct {
 	Joliet         bool   `json:"joliet"`
 	LocationOfFile uint32 `json:"location_of_file"`
 	SizeOfFile     uint32 `json:"size_of_file"`
-	Reader         io.ReaderAt
+	// FileUnitSize and InterleaveGapSize describe the interleave pattern, in sectors, of files recorded in interleaved
+	// mode. A FileUnitSize of zero means the file is recorded in a single contiguous extent.
+	FileUnitSize      uint8 `json:"file_unit_size"`
+	InterleaveGapSize uint8 `json:"interleave_gap_size"`
+	// Reader is the image the extent is read from at its recorded offset
+	Reader io.ReaderAt
+	// Source, when set, provides the content of a newly added file starting at offset zero instead of reading it from
+	// Reader
+	Source io.ReaderAt
 }
 
 func (f FileExtent) Type() string {
@@ -29,31 +38,62 @@ func (f FileExtent) Description() string {
 }
 
 func (f FileExtent) Properties() map[string]interface{} {
-	return map[string]interface{}{
+	properties := map[string]interface{}{
 		"LocationOfFile": f.LocationOfFile,
 		"SizeOfFile":     f.SizeOfFile,
 	}
+	if f.Interleaved() {
+		properties["FileUnitSize"] = f.FileUnitSize
+		properties["InterleaveGapSize"] = f.InterleaveGapSize
+	}
+	return properties
 }
 
 func (f FileExtent) Offset() int64 {
-	return int64(f.LocationOfFile * consts.ISO9660_SECTOR_SIZE)
+	return int64(f.LocationOfFile) * consts.ISO9660_SECTOR_SIZE
 }
 
 func (f FileExtent) Size() int {
 	return int(f.SizeOfFile)
 }
 
+// GetObjects returns the extent itself or, for interleaved files, one object for every file unit so the layout shows
+// the sectors that are actually occupied.
 func (f FileExtent) GetObjects() []info.ImageObject {
-	return []info.ImageObject{f}
+	if !f.Interleaved() || f.Source != nil {
+		return []info.ImageObject{f}
+	}
+
+	unit := uint32(f.FileUnitSize) * consts.ISO9660_SECTOR_SIZE
+	stride := uint32(f.FileUnitSize) + uint32(f.InterleaveGapSize)
+	var objects []info.ImageObject
+	for i, remaining := uint32(0), f.SizeOfFile; remaining > 0; i++ {
+		size := min(remaining, unit)
+		objects = append(objects, FileExtent{
+			FileIdentifier: f.FileIdentifier,
+			Joliet:         f.Joliet,
+			LocationOfFile: f.LocationOfFile + i*stride,
+			SizeOfFile:     size,
+			Reader:         f.Reader,
+		})
+		remaining -= size
+	}
+	return objects
+}
+
+// Interleaved reports whether the file is recorded in interleaved mode.
+func (f FileExtent) Interleaved() bool {
+	return f.FileUnitSize > 0
 }
 
 func (f FileExtent) Marshal() ([]byte, error) {
 	// Allocate a buffer of the file's size
 	buf := make([]byte, f.SizeOfFile)
 
-	// Read from the Reader at the specified offset
-	n, err := f.Reader.ReadAt(buf, f.Offset())
-	if err != nil {
+	// Read from the Source or from the Reader at the specified offset
+	reader, offset := f.source()
+	n, err := reader.ReadAt(buf, offset)
+	if err != nil && !(err == io.EOF && uint32(n) == f.SizeOfFile) {
 		return nil, fmt.Errorf("failed to read file extent %s: %w", f.FileIdentifier, err)
 	}
 
@@ -64,3 +104,125 @@ func (f FileExtent) Marshal() ([]byte, error) {
 
 	return buf, nil
 }
+
+// CopyTo streams the content of the extent to its offset in w without holding the whole file in memory.
+// Sources that can be opened, like files grafted from the host, are opened once for the whole copy.
+func (f FileExtent) CopyTo(w io.WriterAt) error {
+	reader, offset := f.source()
+	var src io.Reader = io.NewSectionReader(reader, offset, int64(f.SizeOfFile))
+	if opener, ok := f.Source.(interface{ Open() (io.ReadCloser, error) }); ok {
+		rc, err := opener.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open file extent %s: %w", f.FileIdentifier, err)
+		}
+		defer rc.Close()
+		src = io.LimitReader(rc, int64(f.SizeOfFile))
+	}
+	n, err := io.CopyBuffer(io.NewOffsetWriter(w, f.Offset()), src, make([]byte, 1024*1024))
+	if err != nil {
+		return fmt.Errorf("failed to copy file extent %s: %w", f.FileIdentifier, err)
+	}
+	if n != int64(f.SizeOfFile) {
+		return fmt.Errorf("unexpected copy size for %s: got %d, expected %d", f.FileIdentifier, n, f.SizeOfFile)
+	}
+	return nil
+}
+
+func (f FileExtent) source() (io.ReaderAt, int64) {
+	if f.Source != nil {
+		return f.Source, 0
+	}
+	if f.Interleaved() {
+		return NewInterleavedReader(f.Reader, f.LocationOfFile, f.FileUnitSize, f.InterleaveGapSize), 0
+	}
+	return f.Reader, f.Offset()
+}
+
+// InterleavedReader reads the content of a file recorded in interleaved mode. Offset zero is the first byte of the
+// file; reads cross from one file unit to the next skipping the interleave gap between them.
+type InterleavedReader struct {
+	reader   io.ReaderAt
+	base     int64
+	unitSize int64
+	stride   int64
+}
+
+// NewInterleavedReader returns a reader over the file starting at the given sector, recorded in units of unitSize
+// sectors separated by gaps of gapSize sectors.
+func NewInterleavedReader(r io.ReaderAt, location uint32, unitSize, gapSize uint8) *InterleavedReader {
+	return &InterleavedReader{
+		reader:   r,
+		base:     int64(location) * consts.ISO9660_SECTOR_SIZE,
+		unitSize: int64(unitSize) * consts.ISO9660_SECTOR_SIZE,
+		stride:   (int64(unitSize) + int64(gapSize)) * consts.ISO9660_SECTOR_SIZE,
+	}
+}
+
+func (r *InterleavedReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if r.unitSize == 0 {
+		return r.reader.ReadAt(p, r.base+off)
+	}
+
+	var total int
+	for len(p) > 0 {
+		within := off % r.unitSize
+		chunk := min(int64(len(p)), r.unitSize-within)
+		n, err := r.reader.ReadAt(p[:chunk], r.base+(off/r.unitSize)*r.stride+within)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+		off += int64(n)
+	}
+	return total, nil
+}
+
+// SectorReader reads the user data of single sectors of an image made of raw CD sectors, such as cdrom.Reader.
+type SectorReader interface {
+	ReadSector(lba int64) ([]byte, cdrom.SectorMode, error)
+}
+
+// Form2Size returns the size of the user data of a CD-ROM XA Form 2 file whose directory record gives dataLength,
+// which counts its sectors as 2048 bytes each.
+func Form2Size(dataLength uint32) uint32 {
+	sectors := (dataLength + consts.ISO9660_SECTOR_SIZE - 1) / consts.ISO9660_SECTOR_SIZE
+	return sectors * consts.CD_FORM2_DATA_SIZE
+}
+
+// Form2Reader reads the content of a CD-ROM XA Form 2 file from an image made of raw sectors. Offset zero is the first
+// byte of the file and every sector contributes 2324 bytes; Form 1 sectors found in the file are padded with zeros.
+type Form2Reader struct {
+	reader   SectorReader
+	location int64
+}
+
+// NewForm2Reader returns a reader over the Form 2 file starting at the given sector.
+func NewForm2Reader(r SectorReader, location uint32) *Form2Reader {
+	return &Form2Reader{reader: r, location: int64(location)}
+}
+
+func (r *Form2Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+
+	var total int
+	for len(p) > 0 {
+		within := int(off % consts.CD_FORM2_DATA_SIZE)
+		data, _, err := r.reader.ReadSector(r.location + off/consts.CD_FORM2_DATA_SIZE)
+		if err != nil {
+			return total, err
+		}
+		payload := make([]byte, consts.CD_FORM2_DATA_SIZE)
+		copy(payload, data)
+		n := copy(p, payload[within:])
+		total += n
+		p = p[n:]
+		off += int64(n)
+	}
+	return total, nil
+}
@@ -0,0 +1,43 @@
+package extent
+
+import (
+	"bytes"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestInterleaved(t *testing.T) {
+	// Sectors 10-11 and 13-14 hold the file, sectors 12 and 15 are the interleave gaps
+	image := make([]byte, 16*consts.ISO9660_SECTOR_SIZE)
+	for sector, fill := range map[int]byte{10: 'a', 11: 'b', 12: 'x', 13: 'c', 14: 'd', 15: 'x'} {
+		copy(image[sector*consts.ISO9660_SECTOR_SIZE:], bytes.Repeat([]byte{fill}, consts.ISO9660_SECTOR_SIZE))
+	}
+	size := 3*consts.ISO9660_SECTOR_SIZE + 100
+
+	f := FileExtent{
+		LocationOfFile:    10,
+		SizeOfFile:        uint32(size),
+		FileUnitSize:      2,
+		InterleaveGapSize: 1,
+		Reader:            bytes.NewReader(image),
+	}
+
+	data, err := io.ReadAll(io.NewSectionReader(NewInterleavedReader(f.Reader, 10, 2, 1), 0, int64(size)))
+	require.NoError(t, err)
+	require.Len(t, data, size)
+	require.NotContains(t, string(data), "x")
+	require.Equal(t, byte('c'), data[2*consts.ISO9660_SECTOR_SIZE])
+
+	marshaled, err := f.Marshal()
+	require.NoError(t, err)
+	require.Equal(t, data, marshaled)
+
+	objects := f.GetObjects()
+	require.Len(t, objects, 2)
+	require.Equal(t, int64(10*consts.ISO9660_SECTOR_SIZE), objects[0].Offset())
+	require.Equal(t, 2*consts.ISO9660_SECTOR_SIZE, objects[0].Size())
+	require.Equal(t, int64(13*consts.ISO9660_SECTOR_SIZE), objects[1].Offset())
+	require.Equal(t, consts.ISO9660_SECTOR_SIZE+100, objects[1].Size())
+}
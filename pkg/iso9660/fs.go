@@ -0,0 +1,240 @@
+package iso9660
+
+import (
+	"errors"
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Compile time checks that the image can be used wherever an fs.FS is accepted
+var (
+	_ fs.ReadDirFS  = (*ISO9660)(nil)
+	_ fs.ReadFileFS = (*ISO9660)(nil)
+	_ fs.StatFS     = (*ISO9660)(nil)
+)
+
+// fsIndex maps slash separated fs.FS names to the filesystem entries of an opened image.
+type fsIndex struct {
+	root     *filesystem.FileSystemEntry
+	entries  map[string]*filesystem.FileSystemEntry
+	children map[string][]*filesystem.FileSystemEntry
+}
+
+// Open opens the named file or directory for reading, implementing fs.FS. Names follow the fs.ValidPath rules so they
+// are unrooted and "." names the root directory. Regular files also implement io.Seeker and io.ReaderAt.
+func (iso *ISO9660) Open(name string) (fs.File, error) {
+	entry, err := iso.lookupName("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsDir {
+		children, err := iso.children("open", name)
+		if err != nil {
+			return nil, err
+		}
+		return &openDir{name: name, entry: entry, children: children}, nil
+	}
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &openFile{FileReader: reader, entry: entry}, nil
+}
+
+// ReadDir returns the entries of the named directory sorted by name, implementing fs.ReadDirFS.
+func (iso *ISO9660) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := iso.lookupName("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	children, err := iso.children("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		list[i] = child.DirEntry()
+	}
+	return list, nil
+}
+
+// Stat returns the fs.FileInfo of the named file or directory, implementing fs.StatFS. The Sys method of the result
+// returns the underlying *filesystem.FileSystemEntry.
+func (iso *ISO9660) Stat(name string) (fs.FileInfo, error) {
+	entry, err := iso.lookupName("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return entry.FileInfo(), nil
+}
+
+// ReadFile returns the content of the named file, implementing fs.ReadFileFS.
+func (iso *ISO9660) ReadFile(name string) ([]byte, error) {
+	entry, err := iso.lookupName("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsDir {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+	return entry.GetBytes()
+}
+
+// lookupName returns the entry with the given fs.FS name.
+func (iso *ISO9660) lookupName(op, name string) (*filesystem.FileSystemEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return iso.rootEntry(), nil
+	}
+	if iso.lazy != nil {
+		entry, err := iso.lookupLazy(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		return entry, nil
+	}
+	entry, ok := iso.index().entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// children returns the entries of the directory with the given fs.FS name sorted by name.
+func (iso *ISO9660) children(op, name string) ([]*filesystem.FileSystemEntry, error) {
+	if iso.lazy != nil {
+		children, err := iso.readDirectoryEntries(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: err}
+		}
+		return children, nil
+	}
+	return iso.index().children[name], nil
+}
+
+// rootEntry returns the entry of the root directory of the hierarchy in use.
+func (iso *ISO9660) rootEntry() *filesystem.FileSystemEntry {
+	if iso.lazy != nil {
+		return iso.newRootEntry()
+	}
+	return iso.index().root
+}
+
+// newRootEntry makes an entry for the root directory, which has no entry of its own, from its directory record.
+func (iso *ISO9660) newRootEntry() *filesystem.FileSystemEntry {
+	root := iso.volumeDescriptorSet.Primary.RootDirectoryRecord
+	if svd := iso.preferredSupplementary(); svd != nil && !iso.openOptions.MergedView {
+		root = svd.RootDirectoryRecord
+	}
+	if root == nil {
+		return filesystem.NewFileSystemEntry(".", "/", true, 0, 0, nil, nil, 0o755, iso.GetCreationDateTime(),
+			iso.GetCreationDateTime(), nil, iso.isoReader)
+	}
+	rockRidge := iso.openOptions.RockRidgeEnabled && !root.Joliet
+	uid, gid := root.GetOwnership(rockRidge)
+	created, modified := root.GetTimestamps(rockRidge)
+	return filesystem.NewFileSystemEntry(".", "/", true, root.DataLength, root.LocationOfExtent, uid, gid,
+		root.GetPermissions(rockRidge), created, modified, root, iso.isoReader)
+}
+
+// index builds the name lookup tables the first time the image is used as an fs.FS.
+func (iso *ISO9660) index() *fsIndex {
+	iso.nameIndexOnce.Do(func() {
+		idx := &fsIndex{
+			entries:  make(map[string]*filesystem.FileSystemEntry),
+			children: make(map[string][]*filesystem.FileSystemEntry),
+		}
+
+		idx.root = iso.newRootEntry()
+
+		entries, err := iso.entries()
+		if err != nil {
+			iso.logger.Error(err, "Failed to read directories")
+		}
+		for _, entry := range entries {
+			name := fsName(entry)
+			if name == "." || !fs.ValidPath(name) {
+				continue
+			}
+			idx.entries[name] = entry
+		}
+		for name, entry := range idx.entries {
+			parent := path.Dir(name)
+			idx.children[parent] = append(idx.children[parent], entry)
+		}
+		for _, children := range idx.children {
+			slices.SortFunc(children, func(a, b *filesystem.FileSystemEntry) int {
+				return strings.Compare(fsName(a), fsName(b))
+			})
+		}
+
+		iso.nameIndex = idx
+	})
+	return iso.nameIndex
+}
+
+// fsName converts the full path of an entry to an fs.FS name.
+func fsName(entry *filesystem.FileSystemEntry) string {
+	name := strings.TrimPrefix(path.Clean("/"+entry.FullPath), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// openFile is a regular file opened through the fs.FS interface.
+type openFile struct {
+	*filesystem.FileReader
+	entry *filesystem.FileSystemEntry
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) {
+	return f.entry.FileInfo(), nil
+}
+
+// openDir is a directory opened through the fs.FS interface.
+type openDir struct {
+	name     string
+	entry    *filesystem.FileSystemEntry
+	children []*filesystem.FileSystemEntry
+	offset   int
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) {
+	return d.entry.FileInfo(), nil
+}
+
+func (d *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *openDir) Close() error {
+	return nil
+}
+
+// ReadDir implements fs.ReadDirFile, returning at most n entries when n > 0 and io.EOF once all have been read.
+func (d *openDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.children[d.offset:]
+	if n > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && len(remaining) > n {
+		remaining = remaining[:n]
+	}
+	d.offset += len(remaining)
+
+	list := make([]fs.DirEntry, len(remaining))
+	for i, child := range remaining {
+		list[i] = child.DirEntry()
+	}
+	return list, nil
+}
@@ -0,0 +1,38 @@
+package iso9660
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	img, err := Create("FS_TEST", option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/etc/hosts", []byte("127.0.0.1 localhost\n")))
+	require.NoError(t, img.SetFileOptions("/etc/hosts", option.WithFileMode(0o600)))
+	require.NoError(t, img.AddFile("/docs/readme.txt", []byte("hello")))
+	require.NoError(t, img.AddDirectory("/empty"))
+
+	out := isotest.File(t, img)
+
+	opened, err := Open(out, option.WithRockRidgeEnabled(true))
+	require.NoError(t, err)
+	require.NoError(t, fstest.TestFS(opened, "etc/hosts", "docs/readme.txt", "empty"))
+
+	info, err := fs.Stat(opened, "etc/hosts")
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0o600), info.Mode())
+
+	data, err := fs.ReadFile(opened, "docs/readme.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	_, err = opened.Open("/etc/hosts")
+	require.ErrorIs(t, err, fs.ErrInvalid)
+	_, err = opened.Open("missing")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
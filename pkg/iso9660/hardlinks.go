@@ -0,0 +1,64 @@
+package iso9660
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"os"
+	"slices"
+	"strings"
+)
+
+// link creates the hard links of extracted files. Tests replace it to exercise the fallback to copies.
+var link = os.Link
+
+// hardLinkKey identifies the file a hard link refers to, either by its Rock Ridge serial number or by its extent.
+type hardLinkKey struct {
+	serial   bool
+	location uint32
+	size     uint32
+}
+
+// hardLinkKeyOf returns the key grouping the entry with its hard links. The Rock Ridge serial number is used when one
+// was recorded, otherwise files sharing a non-empty extent of the same size are treated as hard links. Directories,
+// symbolic links, special files and empty files without a serial number are never linked.
+func hardLinkKeyOf(entry *filesystem.FileSystemEntry) (hardLinkKey, bool) {
+	if entry.IsDir || entry.IsSymlink() || entry.IsSpecialFile() {
+		return hardLinkKey{}, false
+	}
+	if entry.SerialNumber != 0 {
+		return hardLinkKey{serial: true, location: entry.SerialNumber}, true
+	}
+	if entry.Size > 0 {
+		return hardLinkKey{location: entry.Location, size: entry.Size}, true
+	}
+	return hardLinkKey{}, false
+}
+
+// HardLinks returns the groups of files that are hard links of each other, grouped by Rock Ridge serial number or, when
+// none was recorded, by extent. Only groups of two or more files are returned, each sorted by path.
+func (iso *ISO9660) HardLinks() [][]*filesystem.FileSystemEntry {
+	entries, err := iso.entries()
+	if err != nil {
+		iso.logger.Error(err, "Failed to read directories")
+	}
+	groups := make(map[hardLinkKey][]*filesystem.FileSystemEntry)
+	for _, entry := range entries {
+		if key, ok := hardLinkKeyOf(entry); ok {
+			groups[key] = append(groups[key], entry)
+		}
+	}
+
+	var links [][]*filesystem.FileSystemEntry
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+		slices.SortFunc(group, func(a, b *filesystem.FileSystemEntry) int {
+			return strings.Compare(a.FullPath, b.FullPath)
+		})
+		links = append(links, group)
+	}
+	slices.SortFunc(links, func(a, b []*filesystem.FileSystemEntry) int {
+		return strings.Compare(a[0].FullPath, b[0].FullPath)
+	})
+	return links
+}
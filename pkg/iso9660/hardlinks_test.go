@@ -0,0 +1,102 @@
+package iso9660
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHardLinks(t *testing.T) {
+	img, err := Create("LINKS")
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/a.txt", []byte("shared")))
+	require.NoError(t, img.AddFile("/dir/b.txt", []byte("SHARED")))
+	require.NoError(t, img.AddFile("/c.txt", []byte("alone")))
+	data := isotest.Bytes(t, img)
+
+	// Point the record of b.txt at the extent of a.txt, as mkisofs does for hard links without Rock Ridge
+	opened, err := Open(bytes.NewReader(data))
+	require.NoError(t, err)
+	a, err := opened.findEntry("/A.TXT;1")
+	require.NoError(t, err)
+	b, err := opened.findEntry("/DIR/B.TXT;1")
+	require.NoError(t, err)
+	record := b.DirectoryRecord().ObjectLocation
+	binary.LittleEndian.PutUint32(data[record+2:], a.Location)
+	binary.BigEndian.PutUint32(data[record+6:], a.Location)
+
+	opened, err = Open(bytes.NewReader(data), option.WithRockRidgeEnabled(false))
+	require.NoError(t, err)
+	links := opened.HardLinks()
+	require.Len(t, links, 1)
+	require.Len(t, links[0], 2)
+	require.Equal(t, "/A.TXT;1", links[0][0].FullPath)
+	require.Equal(t, "/DIR/B.TXT;1", links[0][1].FullPath)
+
+	t.Run("linked", func(t *testing.T) {
+		out := t.TempDir()
+		require.NoError(t, opened.Extract(out))
+		requireSameFile(t, true, filepath.Join(out, "A.TXT"), filepath.Join(out, "DIR", "B.TXT"))
+		requireSameFile(t, false, filepath.Join(out, "A.TXT"), filepath.Join(out, "C.TXT"))
+	})
+
+	t.Run("copied when linking fails", func(t *testing.T) {
+		link = func(string, string) error { return errors.New("links not supported") }
+		defer func() { link = os.Link }()
+		out := t.TempDir()
+		require.NoError(t, opened.Extract(out))
+		requireSameFile(t, false, filepath.Join(out, "A.TXT"), filepath.Join(out, "DIR", "B.TXT"))
+		content, err := os.ReadFile(filepath.Join(out, "DIR", "B.TXT"))
+		require.NoError(t, err)
+		require.Equal(t, "shared", string(content))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		unlinked, err := Open(bytes.NewReader(data), option.WithRockRidgeEnabled(false), option.WithHardLinksEnabled(false))
+		require.NoError(t, err)
+		out := t.TempDir()
+		require.NoError(t, unlinked.Extract(out))
+		requireSameFile(t, false, filepath.Join(out, "A.TXT"), filepath.Join(out, "DIR", "B.TXT"))
+	})
+}
+
+func TestHardLinkKey(t *testing.T) {
+	// Rock Ridge serial numbers group files whatever their extents, and are not confused with extents
+	first := &filesystem.FileSystemEntry{FullPath: "/a", Location: 30, Size: 5, SerialNumber: 7}
+	second := &filesystem.FileSystemEntry{FullPath: "/b", Location: 31, Size: 5, SerialNumber: 7}
+	byExtent := &filesystem.FileSystemEntry{FullPath: "/c", Location: 7, Size: 5}
+	keyA, ok := hardLinkKeyOf(first)
+	require.True(t, ok)
+	keyB, _ := hardLinkKeyOf(second)
+	require.Equal(t, keyA, keyB)
+	keyC, ok := hardLinkKeyOf(byExtent)
+	require.True(t, ok)
+	require.NotEqual(t, keyA, keyC)
+
+	// Empty files, directories and symbolic links are never linked
+	for _, entry := range []*filesystem.FileSystemEntry{
+		{FullPath: "/empty", Location: 40},
+		{FullPath: "/dir", IsDir: true, Location: 41, Size: 2048},
+		{FullPath: "/link", SymlinkTarget: "a", Location: 42, Size: 1},
+	} {
+		_, ok := hardLinkKeyOf(entry)
+		require.False(t, ok, entry.FullPath)
+	}
+}
+
+// requireSameFile checks whether the two paths are the same file on disk.
+func requireSameFile(t *testing.T, same bool, a, b string) {
+	t.Helper()
+	infoA, err := os.Stat(a)
+	require.NoError(t, err)
+	infoB, err := os.Stat(b)
+	require.NoError(t, err)
+	require.Equal(t, same, os.SameFile(infoA, infoB), "%s and %s", a, b)
+}
@@ -52,6 +52,8 @@ func (i *ISOLayout) Print(w io.Writer, verbose, useColor, useHexOffset bool) {
 		"Directory Record":  color.New(color.FgCyan, color.Bold).SprintFunc(),
 		"Directory Extent":  color.New(color.FgGreen, color.Bold).SprintFunc(),
 		"File Extent":       color.New(color.FgRed, color.Bold).SprintFunc(),
+		"Boot Catalog":      color.New(color.FgHiBlue, color.Bold).SprintFunc(),
+		"Continuation Area": color.New(color.FgHiCyan, color.Bold).SprintFunc(),
 	}
 
 	offsetColor := color.New(color.FgGreen).SprintFunc()
@@ -65,6 +67,14 @@ func (i *ISOLayout) Print(w io.Writer, verbose, useColor, useHexOffset bool) {
 		lengthColor = func(a ...interface{}) string { return fmt.Sprint(a...) }
 	}
 
+	// Object types without a color of their own are printed plainly
+	categoryColor := func(category string) func(a ...interface{}) string {
+		if fn, ok := colorMap[category]; ok {
+			return fn
+		}
+		return fmt.Sprint
+	}
+
 	// Print header
 	fmt.Fprintln(w, color.New(color.FgCyan, color.Bold).Sprint("\n=== ISO Layout Details ==="))
 
@@ -87,8 +97,8 @@ func (i *ISOLayout) Print(w io.Writer, verbose, useColor, useHexOffset bool) {
 
 		fmt.Fprintf(w, "[%s] [%s] [%s] %s\n",
 			offsetColor(offsetStr), // Offset (decimal or hex)
-			colorMap[obj.Type()](fmt.Sprintf("%-*s", categoryWidth, obj.Type())), // Category
-			lengthColor(fmt.Sprintf("%*s", lengthWidth, formatSize(obj.Size()))), // Size
+			categoryColor(obj.Type())(fmt.Sprintf("%-*s", categoryWidth, obj.Type())), // Category
+			lengthColor(fmt.Sprintf("%*s", lengthWidth, formatSize(obj.Size()))),      // Size
 			obj.Name(), // Object name
 		)
 	}
@@ -1,12 +1,15 @@
 package iso9660
 
 import (
+	"cmp"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"github.com/bgrewell/iso-kit/pkg/consts"
 	"github.com/bgrewell/iso-kit/pkg/filesystem"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/boot"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/descriptor"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extensions"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/info"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/parser"
 	"github.com/bgrewell/iso-kit/pkg/iso9660/pathtable"
@@ -19,6 +22,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -35,7 +39,11 @@ func Open(isoReader io.ReaderAt, opts ...option.OpenOption) (*ISO9660, error) {
 		StripVersionInfo:           true,
 		RockRidgeEnabled:           true,
 		ElToritoEnabled:            true,
+		HardLinksEnabled:           true,
 		PreferJoliet:               false,
+		PreferEnhanced:             false,
+		MergedView:                 false,
+		Session:                    0,
 		BootFileExtractLocation:    "[BOOT]",
 		ExtractionProgressCallback: emptyCallback,
 		Logger:                     logging.DefaultLogger(),
@@ -45,21 +53,33 @@ func Open(isoReader io.ReaderAt, opts ...option.OpenOption) (*ISO9660, error) {
 		opt(openOptions)
 	}
 
+	// Create a parser
+	p := parser.NewParser(isoReader, openOptions)
+
 	// Read the System Area
 	saBuf := [consts.ISO9660_SECTOR_SIZE * consts.ISO9660_SYSTEM_AREA_SECTORS]byte{}
 	if _, err := isoReader.ReadAt(saBuf[:], 0); err != nil {
-		return nil, err
+		if err = p.Tolerate(err, 0, "system area"); err != nil {
+			return nil, err
+		}
 	}
 	sa := systemarea.SystemArea{
 		Contents: saBuf,
 	}
 
-	// Create a parser
-	p := parser.NewParser(isoReader, openOptions)
+	// Select the session to read, the first one unless another was chosen
+	session, err := selectSession(isoReader, openOptions.Session)
+	if err != nil {
+		return nil, err
+	}
+	p.SetSessionStart(session.StartSector)
+	openOptions.Logger.Debug("Reading session", "session", session.Number, "sector", session.StartSector)
 
-	// Read the boot record
+	// Read the boot record. With tolerant parsing damaged descriptors other than the primary volume descriptor are
+	// skipped.
+	descriptorSet := session.StartSector + consts.ISO9660_SYSTEM_AREA_SECTORS
 	bootRecord, err := p.GetBootRecord()
-	if err != nil {
+	if err = p.Tolerate(err, descriptorSet, "boot record"); err != nil {
 		return nil, err
 	}
 
@@ -67,7 +87,7 @@ func Open(isoReader io.ReaderAt, opts ...option.OpenOption) (*ISO9660, error) {
 	var et *boot.ElTorito
 	if bootRecord != nil && boot.IsElTorito(bootRecord.BootSystemIdentifier) && openOptions.ElToritoEnabled {
 		et, err = p.GetElTorito(bootRecord)
-		if err != nil {
+		if err = p.Tolerate(err, binary.LittleEndian.Uint32(bootRecord.BootSystemUse[:4]), "boot catalog"); err != nil {
 			return nil, err
 		}
 	}
@@ -80,57 +100,39 @@ func Open(isoReader io.ReaderAt, opts ...option.OpenOption) (*ISO9660, error) {
 
 	// Read the supplementary volume descriptors
 	svds, err := p.GetSupplementaryVolumeDescriptors()
-	if err != nil {
+	if err = p.Tolerate(err, descriptorSet, "supplementary volume descriptor"); err != nil {
 		return nil, err
 	}
 
 	// Read any partition volume descriptors
 	partitionvds, err := p.GetVolumePartitionDescriptors()
-	if err != nil {
+	if err = p.Tolerate(err, descriptorSet, "volume partition descriptor"); err != nil {
 		return nil, err
 	}
 
 	// Mark the end of the volume descriptors
 	term, err := p.GetVolumeDescriptorSetTerminator()
-	if err != nil {
-		return nil, err
-	}
-
-	// Handle walking the pvd directory records
-	pvd.DirectoryRecords, err = p.WalkDirectoryRecords(pvd.RootDirectoryRecord)
-	if err != nil {
+	if err = p.Tolerate(err, descriptorSet, "volume descriptor set terminator"); err != nil {
 		return nil, err
 	}
 
-	// Handle walking the svd directory records
-	for _, svd := range svds {
-		svd.DirectoryRecords, err = p.WalkDirectoryRecords(svd.RootDirectoryRecord)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	// Handle processing volume descriptor
-	var filesystemEntries []*filesystem.FileSystemEntry
-	if openOptions.PreferJoliet && len(svds) > 0 {
-		// Open the Joliet filesystem
-		filesystemEntries, err = p.BuildFileSystemEntries(svds[0].RootDirectoryRecord, false)
-	} else {
-		filesystemEntries, err = p.BuildFileSystemEntries(pvd.RootDirectoryRecord, openOptions.RockRidgeEnabled)
-	}
-
 	// Handle the path tables
 	tables, err := p.GetPathTables(pvd)
-	if err != nil {
+	if err = p.Tolerate(err, pvd.LocationOfPathTableL(), "path table"); err != nil {
 		return nil, err
 	}
 	for _, svd := range svds {
 		svdTables, err := p.GetPathTables(svd)
-		if err != nil {
+		if err = p.Tolerate(err, svd.LocationOfPathTableL(), "path table"); err != nil {
 			return nil, err
 		}
 		tables = append(tables, svdTables...)
 	}
+	if len(tables) != 2*(len(svds)+1) {
+		// Without every path table directories are found by walking each hierarchy from its root
+		tables = nil
+		openOptions.ParseOnOpen = true
+	}
 
 	volumeDescSet := &descriptor.VolumeDescriptorSet{
 		Primary:       pvd,
@@ -146,119 +148,115 @@ func Open(isoReader io.ReaderAt, opts ...option.OpenOption) (*ISO9660, error) {
 		systemArea:          sa,
 		volumeDescriptorSet: volumeDescSet,
 		pathTables:          tables,
-		filesystemEntries:   filesystemEntries,
 		elTorito:            et,
+		session:             session,
+		parser:              p,
 		logger:              openOptions.Logger,
 		isPacked:            true,
 	}
 
+	// Directories are only read up front when parsing on open, otherwise they are read as they are looked up
+	if !openOptions.ParseOnOpen {
+		iso.enableLazyLoading()
+		return iso, nil
+	}
+	if openOptions.PreloadDir {
+		if err := iso.loadDirectoryRecords(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := iso.entries(); err != nil {
+		return nil, err
+	}
+
 	return iso, nil
 }
 
+// Create creates a new, empty ISO9660 filesystem with the given volume identifier. Entries are added with AddFile,
+// AddDirectory, AddSymlink and AddLocalPath and the image is laid out when it is saved. The primary volume
+// descriptor records the identifiers in upper case with unsupported characters replaced by '_', the Joliet descriptor
+// records them as given.
 func Create(name string, opts ...option.CreateOption) (*ISO9660, error) {
 	// Set default create options
 	createOptions := &option.CreateOptions{
-		Preparer: fmt.Sprintf("iso-kit %s %s (%s) %s", version.Version(), version.Revision(), version.Branch(), version.Date()),
+		Preparer:         fmt.Sprintf("iso-kit %s %s (%s) %s", version.Version(), version.Revision(), version.Branch(), version.Date()),
+		InterchangeLevel: 1,
 	}
 
 	for _, opt := range opts {
 		opt(createOptions)
 	}
 
-	//// Create a root directory record
-	//rootDir := &directory.DirectoryRecord{
-	//	FileIdentifier:                "\x00",
-	//	LengthOfDirectoryRecord:       0,
-	//	ExtendedAttributeRecordLength: 0,
-	//	LocationOfExtent:              0, // Updated when writing directory structures
-	//	DataLength:                    0,
-	//	RecordingDateAndTime:          time.Now(),
-	//	FileFlags:                     directory.FileFlags{Directory: true},
-	//	VolumeSequenceNumber:          1, // Volume sequence should be set
-	//}
-
-	//// 1: Create system area (First 16 sectors reserved, boot record might go here)
-	//sa := systemarea.SystemArea{}
-	//
-	//// 2: Create volume descriptor set
-	//pvd := descriptor.PrimaryVolumeDescriptor{
-	//	VolumeDescriptorHeader: descriptor.VolumeDescriptorHeader{
-	//		VolumeDescriptorType:    descriptor.TYPE_PRIMARY_DESCRIPTOR,
-	//		StandardIdentifier:      consts.ISO9660_STD_IDENTIFIER,
-	//		VolumeDescriptorVersion: consts.ISO9660_VOLUME_DESC_VERSION,
-	//	},
-	//	PrimaryVolumeDescriptorBody: descriptor.PrimaryVolumeDescriptorBody{
-	//		SystemIdentifier:              "",
-	//		VolumeIdentifier:              name,
-	//		VolumeSpaceSize:               0, // Set later
-	//		VolumeSetSize:                 1, // Single volume
-	//		VolumeSequenceNumber:          1,
-	//		LogicalBlockSize:              consts.ISO9660_SECTOR_SIZE,
-	//		RootDirectoryRecord:           rootDir,
-	//		VolumeSetIdentifier:           "",
-	//		PublisherIdentifier:           "",
-	//		DataPreparerIdentifier:        createOptions.Preparer,
-	//		ApplicationIdentifier:         "",
-	//		VolumeCreationDateAndTime:     time.Now(),
-	//		VolumeModificationDateAndTime: time.Now(),
-	//		VolumeExpirationDateAndTime:   time.Now(),
-	//		VolumeEffectiveDateAndTime:    time.Now(),
-	//		FileStructureVersion:          1,
-	//	},
-	//}
-	//
-	//// 2.2: Create supplementary volume descriptor (if Joliet is enabled)
-	//var svds []*descriptor.SupplementaryVolumeDescriptor
-	//if createOptions.JolietEnabled {
-	//	svd := descriptor.SupplementaryVolumeDescriptor{
-	//		VolumeDescriptorHeader: descriptor.VolumeDescriptorHeader{
-	//			VolumeDescriptorType:    descriptor.TYPE_SUPPLEMENTARY_DESCRIPTOR,
-	//			StandardIdentifier:      consts.ISO9660_STD_IDENTIFIER,
-	//			VolumeDescriptorVersion: consts.ISO9660_VOLUME_DESC_VERSION,
-	//		},
-	//		SupplementaryVolumeDescriptorBody: descriptor.SupplementaryVolumeDescriptorBody{
-	//			VolumeFlags:                   0,
-	//			SystemIdentifier:              "",
-	//			VolumeIdentifier:              name,
-	//			VolumeSpaceSize:               [8]byte{}, // Needs to be set later
-	//			RootDirectoryRecord:           rootDir,
-	//			VolumeSetIdentifier:           "",
-	//			PublisherIdentifier:           "",
-	//			DataPreparerIdentifier:        createOptions.Preparer,
-	//			ApplicationIdentifier:         "",
-	//			VolumeCreationDateAndTime:     time.Now(),
-	//			VolumeModificationDateAndTime: time.Now(),
-	//			VolumeExpirationDateAndTime:   time.Now(),
-	//			VolumeEffectiveDateAndTime:    time.Now(),
-	//			FileStructureVersion:          1,
-	//		},
-	//	}
-	//	// Copy the Joliet escape sequence (%/@ for Level 3)
-	//	copy(svd.SupplementaryVolumeDescriptorBody.EscapeSequences[:], []byte(consts.JOLIET_LEVEL_3_ESCAPE))
-	//	svds = append(svds, &svd)
-	//}
-
-	// 2.3: Create volume partition descriptor(s) (not used often in basic ISO9660)
-	//var pvds []*descriptor.VolumePartitionDescriptor
-	//
-	//// 2.4: Create boot record (only needed for bootable ISOs)
-	//br := descriptor.BootRecordDescriptor{}
-
-	// 3: Initialize path tables (Will need to be generated)
-	// Placeholder for path table setup
-
-	// 4: Create directory records (Root directory will be updated dynamically)
-	// Placeholder for directory handling
-
-	// Build ISO structure
+	if name == "" {
+		name = createOptions.VolumeID
+	}
+	createOptions.VolumeID = name
+	if createOptions.InterchangeLevel < 1 || createOptions.InterchangeLevel > 3 {
+		return nil, fmt.Errorf("unsupported interchange level %d", createOptions.InterchangeLevel)
+	}
+	if createOptions.CreationTime.IsZero() {
+		createOptions.CreationTime = time.Now()
+	}
+	if createOptions.ModificationTime.IsZero() {
+		createOptions.ModificationTime = createOptions.CreationTime
+	}
+	if createOptions.Logger == nil {
+		createOptions.Logger = logging.DefaultLogger()
+	}
+
+	// The getters of the image are shared with opened images so mirror the relevant create options
+	openOptions := &option.OpenOptions{
+		RockRidgeEnabled: createOptions.RockRidgeEnabled,
+		ElToritoEnabled:  true,
+		StripVersionInfo: true,
+		Logger:           createOptions.Logger,
+		ExtractionProgressCallback: func(currentFilename string, bytesTransferred int64, totalBytes int64, currentFileNumber int, totalFileCount int) {
+		},
+	}
+
+	pvd := &descriptor.PrimaryVolumeDescriptor{
+		VolumeDescriptorHeader: descriptor.VolumeDescriptorHeader{
+			VolumeDescriptorType:    descriptor.TYPE_PRIMARY_DESCRIPTOR,
+			StandardIdentifier:      consts.ISO9660_STD_IDENTIFIER,
+			VolumeDescriptorVersion: consts.ISO9660_VOLUME_DESC_VERSION,
+		},
+		PrimaryVolumeDescriptorBody: descriptor.PrimaryVolumeDescriptorBody{
+			SystemIdentifier:              createOptions.SystemID,
+			VolumeIdentifier:              name,
+			VolumeSetSize:                 1,
+			VolumeSequenceNumber:          1,
+			LogicalBlockSize:              consts.ISO9660_SECTOR_SIZE,
+			VolumeSetIdentifier:           createOptions.VolumeSetID,
+			PublisherIdentifier:           createOptions.PublisherID,
+			DataPreparerIdentifier:        createOptions.Preparer,
+			ApplicationIdentifier:         createOptions.ApplicationID,
+			CopyrightFileIdentifier:       createOptions.CopyrightFileID,
+			AbstractFileIdentifier:        createOptions.AbstractFileID,
+			BibliographicFileIdentifier:   createOptions.BibliographicID,
+			VolumeCreationDateAndTime:     createOptions.CreationTime,
+			VolumeModificationDateAndTime: createOptions.ModificationTime,
+			VolumeExpirationDateAndTime:   createOptions.ExpirationTime,
+			VolumeEffectiveDateAndTime:    createOptions.EffectiveTime,
+			FileStructureVersion:          1,
+			Logger:                        createOptions.Logger,
+		},
+	}
+
 	iso := &ISO9660{
-		//createOptions: createOptions,
-		//systemArea:    sa,
-		//bootRecord:    &br,
-		//pvd:           &pvd,
-		//svds:          svds,
-		//partitionvds:  pvds,
-		//logger:        createOptions.Logger,
+		openOptions:   openOptions,
+		createOptions: createOptions,
+		volumeDescriptorSet: &descriptor.VolumeDescriptorSet{
+			Primary:    pvd,
+			Terminator: descriptor.NewVolumeDescriptorSetTerminator(),
+		},
+		logger: createOptions.Logger,
+	}
+	iso.root = iso.newNode("", true)
+
+	if createOptions.RootDir != "" {
+		if err := iso.AddLocalPath(createOptions.RootDir, "/"); err != nil {
+			return nil, fmt.Errorf("failed to add root directory %s: %w", createOptions.RootDir, err)
+		}
 	}
 
 	return iso, nil
@@ -282,6 +280,28 @@ type ISO9660 struct {
 	elTorito *boot.ElTorito
 	// FileSystemEntries
 	filesystemEntries []*filesystem.FileSystemEntry
+	// Entries found in only one hierarchy when the merged view is used
+	mergeReport *MergeReport
+	// Parser of opened images, used to read directories after Open returns
+	parser *parser.Parser
+	// Filesystem entries and descriptor directory records are read on first use unless they were read by Open
+	entriesOnce sync.Once
+	entriesErr  error
+	recordsOnce sync.Once
+	recordsErr  error
+	// Path table and sector cache used to look up entries without reading every directory
+	lazy *lazyLookup
+	// Continuation areas holding System Use entries of created images
+	continuationAreas []*extensions.ContinuationArea
+	// Root of the staging tree of created images
+	root *node
+	// Attribute overrides applied to the staging tree when packing
+	overrides []fileOverride
+	// Session of a multisession image the volume descriptors were read from
+	session Session
+	// Name lookup tables used by the fs.FS implementation, built on first use
+	nameIndex     *fsIndex
+	nameIndexOnce sync.Once
 	// Logger
 	logger *logging.Logger
 	// isPacked represents if the ISO9660 filesystem is packed and ready to write to disk
@@ -290,16 +310,16 @@ type ISO9660 struct {
 
 // GetVolumeID returns the volume identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetVolumeID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].VolumeIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.VolumeIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.VolumeIdentifier()
 }
 
 // GetSystemID returns the system identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetSystemID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].SystemIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.SystemIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.SystemIdentifier()
 }
@@ -311,88 +331,88 @@ func (iso *ISO9660) GetVolumeSize() uint32 {
 
 // GetVolumeSetID returns the volume set identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetVolumeSetID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].VolumeSetIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.VolumeSetIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.VolumeSetIdentifier()
 }
 
 // GetPublisherID returns the publisher identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetPublisherID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].PublisherIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.PublisherIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.PublisherIdentifier()
 }
 
 // GetDataPreparerID returns the data preparer identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetDataPreparerID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].DataPreparerIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.DataPreparerIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.DataPreparerIdentifier()
 }
 
 // GetApplicationID returns the application identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetApplicationID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].ApplicationIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.ApplicationIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.ApplicationIdentifier()
 }
 
 // GetCopyrightID returns the copyright identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetCopyrightID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].CopyrightFileIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.CopyrightFileIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.CopyrightFileIdentifier()
 }
 
 // GetAbstractID returns the abstract identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetAbstractID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].AbstractFileIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.AbstractFileIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.AbstractFileIdentifier()
 }
 
 // GetBibliographicID returns the bibliographic identifier of the ISO9660 filesystem.
 func (iso *ISO9660) GetBibliographicID() string {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].BibliographicFileIdentifier()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.BibliographicFileIdentifier()
 	}
 	return iso.volumeDescriptorSet.Primary.BibliographicFileIdentifier()
 }
 
 // GetCreationDateTime returns the creation date and time of the ISO9660 filesystem.
 func (iso *ISO9660) GetCreationDateTime() time.Time {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].VolumeCreationDateTime()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.VolumeCreationDateTime()
 	}
 	return iso.volumeDescriptorSet.Primary.VolumeCreationDateTime()
 }
 
 // GetModificationDateTime returns the modification date and time of the ISO9660 filesystem.
 func (iso *ISO9660) GetModificationDateTime() time.Time {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].VolumeModificationDateTime()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.VolumeModificationDateTime()
 	}
 	return iso.volumeDescriptorSet.Primary.VolumeModificationDateTime()
 }
 
 // GetExpirationDateTime returns the expiration date and time of the ISO9660 filesystem.
 func (iso *ISO9660) GetExpirationDateTime() time.Time {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].VolumeExpirationDateTime()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.VolumeExpirationDateTime()
 	}
 	return iso.volumeDescriptorSet.Primary.VolumeExpirationDateTime()
 }
 
 // GetEffectiveDateTime returns the effective date and time of the ISO9660 filesystem.
 func (iso *ISO9660) GetEffectiveDateTime() time.Time {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].VolumeEffectiveDateTime()
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.VolumeEffectiveDateTime()
 	}
 	return iso.volumeDescriptorSet.Primary.VolumeEffectiveDateTime()
 }
@@ -407,6 +427,16 @@ func (iso *ISO9660) HasJoliet() bool {
 	return false
 }
 
+// HasEnhanced returns true if the ISO9660 filesystem has an ISO 9660:1999 Enhanced Volume Descriptor.
+func (iso *ISO9660) HasEnhanced() bool {
+	for _, svd := range iso.volumeDescriptorSet.Supplementary {
+		if svd.IsEnhanced() {
+			return true
+		}
+	}
+	return false
+}
+
 // HasRockRidge returns true if the ISO9660 filesystem has Rock Ridge extensions.
 func (iso *ISO9660) HasRockRidge() bool {
 	return iso.volumeDescriptorSet.Primary.HasRockRidge()
@@ -419,12 +449,121 @@ func (iso *ISO9660) HasElTorito() bool {
 
 // RootDirectoryLocation returns the location of the root directory in the ISO9660 filesystem.
 func (iso *ISO9660) RootDirectoryLocation() uint32 {
-	if iso.openOptions.PreferJoliet && iso.volumeDescriptorSet.Supplementary != nil {
-		return iso.volumeDescriptorSet.Supplementary[0].RootDirectoryRecord.LocationOfExtent
+	if svd := iso.preferredSupplementary(); svd != nil {
+		return svd.RootDirectoryRecord.LocationOfExtent
 	}
 	return iso.volumeDescriptorSet.Primary.RootDirectoryRecord.LocationOfExtent
 }
 
+// preferredSupplementary returns the supplementary descriptor whose hierarchy the ISO9660 filesystem was opened with,
+// or nil if the primary hierarchy is used.
+func (iso *ISO9660) preferredSupplementary() *descriptor.SupplementaryVolumeDescriptor {
+	return selectSupplementary(iso.volumeDescriptorSet.Supplementary, iso.openOptions)
+}
+
+// selectSupplementary picks the enhanced descriptor when PreferEnhanced is set and the Joliet descriptor when
+// PreferJoliet is set, the enhanced one taking precedence. It returns nil if neither is preferred or present.
+func selectSupplementary(svds []*descriptor.SupplementaryVolumeDescriptor, opts *option.OpenOptions) *descriptor.SupplementaryVolumeDescriptor {
+	if opts.PreferEnhanced {
+		for _, svd := range svds {
+			if svd.IsEnhanced() {
+				return svd
+			}
+		}
+	}
+	if opts.PreferJoliet {
+		for _, svd := range svds {
+			if svd.IsJoliet() {
+				return svd
+			}
+		}
+	}
+	return nil
+}
+
+// mergeSource returns the supplementary descriptor whose hierarchy is combined with the primary one in the merged view:
+// the preferred one, or the Joliet descriptor if none is preferred. It returns nil if the merged view is not used.
+func mergeSource(svds []*descriptor.SupplementaryVolumeDescriptor, opts *option.OpenOptions) *descriptor.SupplementaryVolumeDescriptor {
+	if !opts.MergedView {
+		return nil
+	}
+	if svd := selectSupplementary(svds, opts); svd != nil {
+		return svd
+	}
+	for _, svd := range svds {
+		if svd.IsJoliet() {
+			return svd
+		}
+	}
+	return nil
+}
+
+// entries returns the filesystem entries of the image, reading every directory the first time it is called on an
+// image whose directories were not parsed on open.
+func (iso *ISO9660) entries() ([]*filesystem.FileSystemEntry, error) {
+	if iso.parser == nil {
+		return iso.filesystemEntries, nil
+	}
+	iso.entriesOnce.Do(func() {
+		iso.filesystemEntries, iso.mergeReport, iso.entriesErr = buildEntries(iso.parser, iso.volumeDescriptorSet, iso.openOptions)
+	})
+	return iso.filesystemEntries, iso.entriesErr
+}
+
+// buildEntries walks the hierarchy selected by the open options and converts it into filesystem entries.
+func buildEntries(p *parser.Parser, vds *descriptor.VolumeDescriptorSet, opts *option.OpenOptions) ([]*filesystem.FileSystemEntry, *MergeReport, error) {
+	pvd := vds.Primary
+	if svd := mergeSource(vds.Supplementary, opts); svd != nil {
+		// Combine the primary and supplementary hierarchies
+		entries, err := p.BuildFileSystemEntries(pvd.RootDirectoryRecord, opts.RockRidgeEnabled)
+		if err != nil {
+			return nil, nil, err
+		}
+		svdEntries, err := p.BuildFileSystemEntries(svd.RootDirectoryRecord, opts.RockRidgeEnabled && svd.IsEnhanced())
+		if err != nil {
+			return nil, nil, err
+		}
+		entries, report := mergeHierarchies(entries, svdEntries, opts.RockRidgeEnabled)
+		return entries, report, nil
+	}
+	if svd := selectSupplementary(vds.Supplementary, opts); svd != nil {
+		// Open the Enhanced or Joliet filesystem, only the enhanced hierarchy may carry Rock Ridge entries
+		entries, err := p.BuildFileSystemEntries(svd.RootDirectoryRecord, opts.RockRidgeEnabled && svd.IsEnhanced())
+		return entries, nil, err
+	}
+	entries, err := p.BuildFileSystemEntries(pvd.RootDirectoryRecord, opts.RockRidgeEnabled)
+	return entries, nil, err
+}
+
+// loadDirectoryRecords walks the directory records of every hierarchy into the volume descriptors the first time it
+// is called on an opened image. The records are needed to patch files and to describe the image layout.
+func (iso *ISO9660) loadDirectoryRecords() error {
+	if iso.parser == nil {
+		return nil
+	}
+	iso.recordsOnce.Do(func() {
+		pvd := iso.volumeDescriptorSet.Primary
+		if pvd.DirectoryRecords, iso.recordsErr = iso.parser.WalkDirectoryRecords(pvd.RootDirectoryRecord); iso.recordsErr != nil {
+			return
+		}
+		for _, svd := range iso.volumeDescriptorSet.Supplementary {
+			if svd.DirectoryRecords, iso.recordsErr = iso.parser.WalkDirectoryRecords(svd.RootDirectoryRecord); iso.recordsErr != nil {
+				return
+			}
+		}
+	})
+	return iso.recordsErr
+}
+
+// MergeReport returns the entries that were found in only one of the hierarchies combined by the merged view, or nil
+// if the image was not opened with option.WithMergedView.
+func (iso *ISO9660) MergeReport() *MergeReport {
+	if _, err := iso.entries(); err != nil {
+		return nil
+	}
+	return iso.mergeReport
+}
+
 // ListBootEntries returns a list of all boot entries in the ISO9660 filesystem.
 func (iso *ISO9660) ListBootEntries() ([]*filesystem.FileSystemEntry, error) {
 	return iso.elTorito.BuildBootImageEntries()
@@ -432,8 +571,12 @@ func (iso *ISO9660) ListBootEntries() ([]*filesystem.FileSystemEntry, error) {
 
 // ListFiles returns a list of all files in the ISO9660 filesystem.
 func (iso *ISO9660) ListFiles() ([]*filesystem.FileSystemEntry, error) {
+	entries, err := iso.entries()
+	if err != nil {
+		return nil, err
+	}
 	files := make([]*filesystem.FileSystemEntry, 0)
-	for _, entry := range iso.filesystemEntries {
+	for _, entry := range entries {
 		if !entry.IsDir {
 			files = append(files, entry)
 		}
@@ -444,8 +587,12 @@ func (iso *ISO9660) ListFiles() ([]*filesystem.FileSystemEntry, error) {
 
 // ListDirectories returns a list of all directories in the ISO9660 filesystem.
 func (iso *ISO9660) ListDirectories() ([]*filesystem.FileSystemEntry, error) {
+	entries, err := iso.entries()
+	if err != nil {
+		return nil, err
+	}
 	dirs := make([]*filesystem.FileSystemEntry, 0)
-	for _, entry := range iso.filesystemEntries {
+	for _, entry := range entries {
 		if entry.IsDir {
 			dirs = append(dirs, entry)
 		}
@@ -454,21 +601,6 @@ func (iso *ISO9660) ListDirectories() ([]*filesystem.FileSystemEntry, error) {
 	return dirs, nil
 }
 
-func (iso *ISO9660) ReadFile(path string) ([]byte, error) {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (iso *ISO9660) AddFile(path string, data []byte) error {
-	//TODO implement me
-	panic("implement me")
-}
-
-func (iso *ISO9660) RemoveFile(path string) error {
-	//TODO implement me
-	panic("implement me")
-}
-
 // CreateDirectories creates all directories from the ISO in the specified path.
 func (iso *ISO9660) CreateDirectories(path string) error {
 	// Ensure output directory exists
@@ -482,7 +614,10 @@ func (iso *ISO9660) CreateDirectories(path string) error {
 		return fmt.Errorf("failed to list directories: %w", err)
 	}
 	for _, entry := range dirs {
-		dirPath := filepath.Join(path, entry.FullPath)
+		dirPath, err := extractPath(path, entry.FullPath)
+		if err != nil {
+			return err
+		}
 		if err := os.MkdirAll(dirPath, entry.Mode); err != nil {
 			return fmt.Errorf("failed to create directory %s: %w", dirPath, err)
 		}
@@ -491,6 +626,20 @@ func (iso *ISO9660) CreateDirectories(path string) error {
 	return nil
 }
 
+// extractPath returns the path an entry is extracted to under the output directory dir, refusing entry paths that
+// would end up outside of it, either lexically or through symbolic links already extracted.
+func extractPath(dir, entryPath string) (string, error) {
+	p := filepath.Join(dir, entryPath)
+	rel, err := filepath.Rel(dir, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of the output directory", entryPath)
+	}
+	if _, err := filesystem.ResolveInside(dir, p); err != nil {
+		return "", fmt.Errorf("%s is outside of the output directory: %w", entryPath, err)
+	}
+	return p, nil
+}
+
 // Extract extracts all files and directories from the ISO to the specified path.
 func (iso *ISO9660) Extract(path string) error {
 	// Create all directories first
@@ -501,7 +650,7 @@ func (iso *ISO9660) Extract(path string) error {
 	// Extract El Torito boot images if enabled
 	if iso.elTorito != nil && iso.openOptions.ElToritoEnabled {
 		err := iso.elTorito.ExtractBootImages(iso.isoReader, filepath.Join(path, iso.openOptions.BootFileExtractLocation))
-		if err != nil {
+		if err = iso.parser.Tolerate(err, uint32(iso.elTorito.ObjectLocation/consts.ISO9660_SECTOR_SIZE), "boot image"); err != nil {
 			return fmt.Errorf("failed to extract El Torito boot images: %w", err)
 		}
 	}
@@ -513,79 +662,194 @@ func (iso *ISO9660) Extract(path string) error {
 	}
 
 	totalFiles := len(files)
+	var skipped []string
+	linked := make(map[hardLinkKey]string)
 
 	// Extract files
 	for i, entry := range files {
-		outputPath := filepath.Join(path, entry.FullPath)
+		outputPath, err := extractPath(path, entry.FullPath)
+		if err != nil {
+			return err
+		}
 
 		// Ensure parent directories exist
 		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 			return fmt.Errorf("failed to create parent directories for %s: %w", outputPath, err)
 		}
 
-		// if the option to strip version info is enabled, enhanced and rr are not enabled then strip the version info
-		if iso.openOptions.StripVersionInfo && !iso.openOptions.RockRidgeEnabled && !iso.openOptions.PreferJoliet {
+		// Symbolic links are created as links, subject to the symlink policy
+		if entry.IsSymlink() {
+			err := entry.ExtractSymlink(path, iso.openOptions.SymlinkPolicy)
+			if errors.Is(err, filesystem.ErrUnsafeSymlink) && iso.openOptions.SymlinkPolicy != filesystem.SYMLINK_POLICY_ERROR {
+				iso.logger.Info("Skipping unsafe symbolic link", "path", entry.FullPath, "target", entry.SymlinkTarget)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// if the option to strip version info is enabled, enhanced, joliet, the merged view and rr are not enabled then
+		// strip the version info
+		if iso.openOptions.StripVersionInfo && !iso.openOptions.RockRidgeEnabled && iso.preferredSupplementary() == nil &&
+			!iso.openOptions.MergedView {
 			outputPath = strings.TrimRight(outputPath, ";1")
 		}
 
-		// Open output file for writing
-		outFile, err := os.Create(outputPath)
-		if err != nil {
-			return fmt.Errorf("failed to create file %s: %w", outputPath, err)
-		}
-		defer outFile.Close()
-
-		// Stream the file from the ISO
-		startOffset := int64(entry.Location) * int64(consts.ISO9660_SECTOR_SIZE)
-		size := int64(entry.Size)
-		bufferSize := 4096 // 4KB buffer
-		buffer := make([]byte, bufferSize)
-
-		var bytesTransferred int64
-		for bytesTransferred < size {
-			// Read chunk from ISO
-			bytesToRead := bufferSize
-			if remaining := size - bytesTransferred; remaining < int64(bufferSize) {
-				bytesToRead = int(remaining)
+		if entry.IsSpecialFile() {
+			// Device nodes, FIFOs and sockets are recreated, skipped or rejected according to the special file policy
+			err := filesystem.ErrSpecialFile
+			switch iso.openOptions.SpecialFilePolicy {
+			case filesystem.SPECIAL_FILE_POLICY_ERROR:
+				return fmt.Errorf("%s: %w", entry.FullPath, err)
+			case filesystem.SPECIAL_FILE_POLICY_CREATE:
+				err = entry.ExtractSpecialFile(path)
+			}
+			if errors.Is(err, filesystem.ErrSpecialFile) {
+				iso.logger.Info("Skipping special file", "path", entry.FullPath, "mode", entry.Mode)
+				skipped = append(skipped, entry.ManifestLine())
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			// Files sharing a serial number or extent with a file that was already written are linked to it
+			key, isLink := hardLinkKeyOf(entry)
+			if first, ok := linked[key]; isLink && ok && iso.openOptions.HardLinksEnabled {
+				if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to replace %s: %w", outputPath, err)
+				}
+				if err := link(first, outputPath); err == nil {
+					continue
+				}
+				iso.logger.Debug("Failed to create hard link, extracting a copy", "path", entry.FullPath, "target", first)
 			}
 
-			n, err := entry.ReadAt(buffer[:bytesToRead], startOffset+bytesTransferred)
-			if err != nil && err != io.EOF {
-				return fmt.Errorf("failed to read file %s from ISO: %w", entry.FullPath, err)
+			// Stream the file from the ISO
+			if err := iso.extractFile(entry, outputPath, i+1, totalFiles); err != nil {
+				return err
 			}
+			if isLink {
+				if _, ok := linked[key]; !ok {
+					linked[key] = outputPath
+				}
+			}
+		}
+
+		// Set correct file permissions
+		if err := os.Chmod(outputPath, entry.Mode); err != nil {
+			return fmt.Errorf("failed to set permissions on %s: %w", outputPath, err)
+		}
 
-			if n == 0 {
-				break // Reached EOF
+		// Set timestamps, using the Rock Ridge access time when one was recorded
+		if !entry.ModTime.IsZero() {
+			accessTime := entry.AccessTime
+			if accessTime.IsZero() {
+				accessTime = entry.ModTime
+			}
+			if err := os.Chtimes(outputPath, accessTime, entry.ModTime); err != nil {
+				return fmt.Errorf("failed to set timestamps on %s: %w", outputPath, err)
 			}
+		}
+	}
+
+	// Record the special files that were skipped so they can be recreated later
+	if iso.openOptions.SpecialFileManifest != "" && len(skipped) > 0 {
+		manifest := strings.Join(skipped, "\n") + "\n"
+		if err := os.WriteFile(iso.openOptions.SpecialFileManifest, []byte(manifest), 0644); err != nil {
+			return fmt.Errorf("failed to write special file manifest: %w", err)
+		}
+	}
+
+	return nil
+}
 
-			// Write chunk to file
+// extractFile streams the content of a file entry to outputPath using a bounded buffer, reporting progress as it goes.
+// With tolerant parsing a read error switches to reading one sector at a time, and sectors that cannot be read are
+// written as zeros and reported as problems.
+func (iso *ISO9660) extractFile(entry *filesystem.FileSystemEntry, outputPath string, fileNumber, totalFiles int) error {
+	outFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", outputPath, err)
+	}
+	defer outFile.Close()
+
+	reader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	size := int64(entry.Size)
+	buffer := make([]byte, 64*1024)
+	var bytesTransferred int64
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 {
 			if _, err := outFile.Write(buffer[:n]); err != nil {
 				return fmt.Errorf("failed to write to file %s: %w", outputPath, err)
 			}
-
-			// Update bytes transferred
 			bytesTransferred += int64(n)
 
 			// Invoke progress callback
 			if iso.openOptions.ExtractionProgressCallback != nil {
-				iso.openOptions.ExtractionProgressCallback(outputPath, bytesTransferred, size, i+1, totalFiles)
+				iso.openOptions.ExtractionProgressCallback(outputPath, bytesTransferred, size, fileNumber, totalFiles)
 			}
 		}
-
-		// Set correct file permissions
-		if err := os.Chmod(outputPath, entry.Mode); err != nil {
-			return fmt.Errorf("failed to set permissions on %s: %w", outputPath, err)
+		if err == io.EOF {
+			break
 		}
+		if err != nil {
+			if !iso.parser.Tolerant() {
+				return fmt.Errorf("failed to read file %s from ISO: %w", entry.FullPath, err)
+			}
+			if len(buffer) > consts.ISO9660_SECTOR_SIZE {
+				buffer = buffer[:consts.ISO9660_SECTOR_SIZE]
+				continue
+			}
 
-		// Set timestamps
-		if !entry.ModTime.IsZero() {
-			if err := os.Chtimes(outputPath, entry.ModTime, entry.ModTime); err != nil {
-				return fmt.Errorf("failed to set timestamps on %s: %w", outputPath, err)
+			// Skip the rest of the unreadable sector
+			next := min((bytesTransferred/consts.ISO9660_SECTOR_SIZE+1)*consts.ISO9660_SECTOR_SIZE, size)
+			iso.reportFileProblem(entry, bytesTransferred, err)
+			if _, err := outFile.Write(make([]byte, next-bytesTransferred)); err != nil {
+				return fmt.Errorf("failed to write to file %s: %w", outputPath, err)
+			}
+			bytesTransferred = next
+			if _, err := reader.Seek(next, io.SeekStart); err != nil {
+				return err
 			}
 		}
 	}
 
-	return nil
+	// Files recorded past the end of a truncated image are reported rather than silently cut short
+	if bytesTransferred < size && iso.parser.Tolerant() {
+		iso.reportFileProblem(entry, bytesTransferred, io.ErrUnexpectedEOF)
+	}
+
+	return outFile.Close()
+}
+
+// reportFileProblem records that the data of a file could not be read from the given offset in the file.
+func (iso *ISO9660) reportFileProblem(entry *filesystem.FileSystemEntry, fileOffset int64, err error) {
+	offset := int64(entry.Location)*consts.ISO9660_SECTOR_SIZE + fileOffset
+	iso.parser.Report(parser.Problem{
+		Structure: "file data",
+		Path:      entry.FullPath,
+		LBA:       uint32(offset / consts.ISO9660_SECTOR_SIZE),
+		Offset:    offset,
+		Err:       err,
+	})
+}
+
+// Problems returns the damaged structures skipped so far when the image was opened with option.WithTolerantParsing:
+// those found by Open, while reading directories and while extracting files.
+func (iso *ISO9660) Problems() []parser.Problem {
+	if iso.parser == nil {
+		return nil
+	}
+	return iso.parser.Problems()
 }
 
 // SetLogger sets the logger for the ISO9660 filesystem.
@@ -610,14 +874,22 @@ func (iso *ISO9660) GetLayout() *info.ISOLayout {
 func (iso *ISO9660) GetObjects() []info.ImageObject {
 	var objects []info.ImageObject
 
+	// The directory records are part of the descriptor objects
+	if err := iso.loadDirectoryRecords(); err != nil {
+		iso.logger.Error(err, "Failed to read directory records")
+	}
+
 	for _, objs := range []([]info.ImageObject){
 		iso.systemArea.GetObjects(),
 		iso.volumeDescriptorSet.Primary.GetObjects(),
-		iso.volumeDescriptorSet.Terminator.GetObjects(),
 	} {
 		objects = append(objects, objs...)
 	}
 
+	if iso.volumeDescriptorSet.Terminator != nil {
+		objects = append(objects, iso.volumeDescriptorSet.Terminator.GetObjects()...)
+	}
+
 	if iso.volumeDescriptorSet.Boot != nil {
 		objects = append(objects, iso.volumeDescriptorSet.Boot.GetObjects()...)
 	}
@@ -643,14 +915,22 @@ func (iso *ISO9660) GetObjects() []info.ImageObject {
 	if iso.elTorito != nil {
 		objects = append(objects, iso.elTorito.GetObjects()...)
 	}
+
+	for _, ca := range iso.continuationAreas {
+		objects = append(objects, ca.GetObjects()...)
+	}
 	return objects
 }
 
 func (iso *ISO9660) Save(writer io.WriterAt) error {
 	// Ensure the ISO is packed and all objects have been assigned locations
 	if !iso.isPacked {
-		// TODO: Make packing automatic
-		return errors.New("iso is not packed, cannot save")
+		if iso.root == nil {
+			return errors.New("iso is not packed, cannot save")
+		}
+		if err := iso.pack(); err != nil {
+			return fmt.Errorf("failed to pack image: %w", err)
+		}
 	}
 
 	// Get all objects
@@ -658,11 +938,21 @@ func (iso *ISO9660) Save(writer io.WriterAt) error {
 
 	// Sort objects by offset before writing
 	slices.SortFunc(objects, func(a, b info.ImageObject) int {
-		return int(a.Offset() - b.Offset())
+		return cmp.Compare(a.Offset(), b.Offset())
 	})
 
 	// Write each object at its assigned offset
+	var end int64
 	for _, obj := range objects {
+		// File extents are copied from their source rather than buffered in memory
+		if copier, ok := obj.(interface{ CopyTo(io.WriterAt) error }); ok {
+			if err := copier.CopyTo(writer); err != nil {
+				return fmt.Errorf("failed to write object %s at offset %d: %w", obj.Name(), obj.Offset(), err)
+			}
+			end = max(end, obj.Offset()+int64(obj.Size()))
+			continue
+		}
+
 		// Get raw data for the object
 		data, err := obj.Marshal()
 		if err != nil {
@@ -674,315 +964,24 @@ func (iso *ISO9660) Save(writer io.WriterAt) error {
 		if err != nil {
 			return fmt.Errorf("failed to write object %s at offset %d: %w", obj.Name(), obj.Offset(), err)
 		}
+		end = max(end, obj.Offset()+int64(len(data)))
 	}
 
-	return nil
-
-	//sectorSize := int64(consts.ISO9660_SECTOR_SIZE)
-	//saOffset := int64(0)
-	//
-	//// Calculate offsets for descriptors
-	//pvdSize := sectorSize
-	//bootSize := int64(0)
-	//if iso.bootRecord != nil {
-	//	bootSize = sectorSize
-	//}
-	//svdSize := int64(len(iso.svds)) * sectorSize
-	//ptvdSize := int64(len(iso.partitionvds)) * sectorSize
-	//
-	//pvdOffset := saOffset + consts.ISO9660_SYSTEM_AREA_SECTORS*sectorSize
-	//bootOffset := pvdOffset + pvdSize
-	//svdOffset := bootOffset + bootSize
-	//ptvdOffset := svdOffset + svdSize
-	//termOffset := ptvdOffset + ptvdSize
-	//
-	//type descriptorSetEntry struct {
-	//	descriptor descriptor.VolumeDescriptor
-	//	offset     int64
-	//}
-	//descriptorSet := []*descriptorSetEntry{
-	//	{descriptor: iso.pvd, offset: pvdOffset},
-	//}
-	//if iso.bootRecord != nil {
-	//	descriptorSet = append(descriptorSet,
-	//		&descriptorSetEntry{descriptor: iso.bootRecord, offset: bootOffset},
-	//	)
-	//}
-	//for i, svd := range iso.svds {
-	//	descriptorSet = append(descriptorSet,
-	//		&descriptorSetEntry{descriptor: svd, offset: svdOffset + int64(i)*sectorSize},
-	//	)
-	//}
-	//for i, ptvd := range iso.partitionvds {
-	//	descriptorSet = append(descriptorSet,
-	//		&descriptorSetEntry{descriptor: ptvd, offset: ptvdOffset + int64(i)*sectorSize},
-	//	)
-	//}
-	//descriptorSet = append(descriptorSet,
-	//	&descriptorSetEntry{descriptor: descriptor.NewVolumeDescriptorSetTerminator(), offset: termOffset})
-	//
-	//// Write system area
-	//_, err := writer.WriteAt(iso.systemArea.Contents[:], 0)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//// Write descriptor set
-	//for _, entry := range descriptorSet {
-	//	if err = writeDescriptor(writer, entry.descriptor, entry.offset); err != nil {
-	//		return err
-	//	}
-	//}
-	//
-	//// Write path tables according to their location in the volume descriptors
-	//err = iso.writePathTables(writer)
-	//if err != nil {
-	//	return err
-	//}
-
-	// Write directory records
-
-	//pathTableOffset := svdOffset + (len(iso.svds) * sectorSize)
-	//// Directory Record offsets should be
-	//
-	//
-
-	//// TODO: Clean up all of the offset calculations, instead this should be calculated somewhere
-	////       else and the locations should just be used to write the data or this should be wrapped
-	////       nicely in a function.
-	//// Write volume descriptor set
-	//pvdOffset := int64(16 * sectorSize)
-	//err = writeDescriptor(writer, iso.pvd, pvdOffset)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//svdOffset := pvdOffset + int64(1*sectorSize)
-	//for i, svd := range iso.svds {
-	//	err = writeDescriptor(writer, svd, svdOffset)
-	//	if err != nil {
-	//		return err
-	//	}
-	//	svdOffset = svdOffset+int64((i+1)*sectorSize)
-	//}
-	//
-	//ptvdOffset := svdOffset
-	//for i, pvd := range iso.partitionvds {
-	//	if err = writeDescriptor(writer, pvd, ptvdOffset); err != nil {
-	//		return err
-	//	}
-	//	ptvdOffset = ptvdOffset+int64((i+1)*sectorSize)
-	//}
-	//
-	//bootOffset := ptvdOffset
-	//if iso.bootRecord != nil {
-	//	if err = writeDescriptor(writer, iso.bootRecord, bootOffset); err != nil {
-	//		return err
-	//	}
-	//	bootOffset = bootOffset + int64(1*sectorSize)
-	//}
-	//
-	//tr := descriptor.NewVolumeDescriptorSetTerminator()
-	//if err := writeDescriptor(writer, tr, bootOffset); err != nil {
-	//	return err
-	//}
-
-	//// 3: Write path tables (Little & Big Endian versions)
-	//if err = iso.writePathTables(writer); err != nil {
-	//	return err
-	//}
-	//
-	//// 4: Write directory records (Root & Subdirectories)
-	//totalRecords := append(iso.pvdDirectoryRecords, iso.svdDirectoryRecords...)
-	//for i, dr := range totalRecords {
-	//	drOffset :=
-	//}
-	//
-	//
-	//// 5: Write file contents (Ensuring correct logical block placement)
-	//if err := iso.writeFileData(writer); err != nil {
-	//	return err
-	//}
-	//
-	//// 6: Align to sector size (Padding to 2048-byte boundaries)
-	//if err := padToSector(writer); err != nil {
-	//	return err
-	//}
+	// Make sure the output covers the whole volume space even when the last sectors are padding
+	volumeEnd := int64(iso.volumeDescriptorSet.Primary.VolumeSpaceSize) * consts.ISO9660_SECTOR_SIZE
+	if end < volumeEnd {
+		if _, err := writer.WriteAt([]byte{0}, volumeEnd-1); err != nil {
+			return fmt.Errorf("failed to pad image to %d bytes: %w", volumeEnd, err)
+		}
+	}
 
 	return nil
 }
 
 // Close closes the ISO9660 filesystem.
 func (iso *ISO9660) Close() error {
-	if f, ok := iso.isoReader.(*os.File); ok {
-		return f.Close()
+	if c, ok := iso.isoReader.(io.Closer); ok {
+		return c.Close()
 	}
 	return nil
 }
-
-// writePathTables writes the path tables to the ISO9660 filesystem.
-func (iso *ISO9660) writePathTables(writer io.WriterAt) error {
-	//if iso.pvd == nil {
-	//	return errors.New("PVD is missing")
-	//}
-	//
-	//buf, err := iso.pvdLPathTable.Marshal(true)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//if _, err = writer.WriteAt(buf, int64(iso.pvd.LocationOfTypeLPathTable)*consts.ISO9660_SECTOR_SIZE); err != nil {
-	//	return err
-	//}
-	//
-	//buf, err = iso.pvdMPathTable.Marshal(false)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//if _, err = writer.WriteAt(buf, int64(iso.pvd.LocationOfTypeMPathTable)*consts.ISO9660_SECTOR_SIZE); err != nil {
-	//	return err
-	//}
-	//
-	//if iso.svdLPathTable != nil {
-	//	buf, err = iso.svdLPathTable.Marshal(true)
-	//	if err != nil {
-	//		return err
-	//	}
-	//
-	//	if _, err = writer.WriteAt(buf, int64(iso.svds[0].LocationOfTypeLPathTable)*consts.ISO9660_SECTOR_SIZE); err != nil {
-	//		return err
-	//	}
-	//}
-	//
-	//if iso.svdMPathTable != nil {
-	//	buf, err = iso.svdMPathTable.Marshal(false)
-	//	if err != nil {
-	//		return err
-	//	}
-	//
-	//	if _, err = writer.WriteAt(buf, int64(iso.svds[0].LocationOfTypeMPathTable)*consts.ISO9660_SECTOR_SIZE); err != nil {
-	//		return err
-	//	}
-	//}
-
-	return nil
-}
-
-func (iso *ISO9660) writeDirectoryRecords(writer io.WriterAt) error {
-
-	//var rootDirOffset uint32
-	//
-	//// TODO: Write PVD Directory records starting with the root
-	//rootDirOffset = iso.pvd.RootDirectoryRecord.LocationOfExtent
-
-	// TODO: Write SVD Directory records starting with the root
-
-	//sectorSize := consts.ISO9660_SECTOR_SIZE
-	//
-	//// Root Directory
-	//rootDirData, err := iso.
-	//if err != nil {
-	//	return err
-	//}
-	//if _, err := writer.Write(rootDirData[:]); err != nil {
-	//	return err
-	//}
-	//
-	//// Write Subdirectories
-	//for _, dir := range iso.directories {
-	//	dirData, err := dir.Marshal()
-	//	if err != nil {
-	//		return err
-	//	}
-	//
-	//	// Ensure correct sector alignment
-	//	padding := sectorSize - (len(dirData) % sectorSize)
-	//	if padding < sectorSize {
-	//		dirData = append(dirData, make([]byte, padding)...)
-	//	}
-	//
-	//	if _, err := writer.Write(dirData[:]); err != nil {
-	//		return err
-	//	}
-	//}
-	//
-	//return nil
-	return errors.New("not implemented")
-}
-
-func (iso *ISO9660) writeFileData(writer io.Writer) error {
-	//sectorSize := consts.ISO9660_SECTOR_SIZE
-	//
-	//for _, file := range iso. {
-	//	fileData, err := file.ReadData()
-	//	if err != nil {
-	//		return err
-	//	}
-	//
-	//	if _, err := writer.Write(fileData); err != nil {
-	//		return err
-	//	}
-	//
-	//	// Align to sector size
-	//	padding := sectorSize - (len(fileData) % sectorSize)
-	//	if padding < sectorSize {
-	//		if _, err := writer.Write(make([]byte, padding)); err != nil {
-	//			return err
-	//		}
-	//	}
-	//}
-	//
-	//return nil
-	return errors.New("not implemented")
-}
-
-func padToSector(writer io.Writer) error {
-	//sectorSize := consts.ISO9660_SECTOR_SIZE
-	//
-	//// Get current file position
-	//offset, err := writer.Seek(0, io.SeekCurrent)
-	//if err != nil {
-	//	return err
-	//}
-	//
-	//// Compute padding
-	//padding := sectorSize - (offset % sectorSize)
-	//if padding < sectorSize {
-	//	_, err := writer.Write(make([]byte, padding))
-	//	return err
-	//}
-	//
-	//return nil
-	return errors.New("not implemented")
-}
-
-func writeDescriptor(writer io.WriterAt, descriptor descriptor.VolumeDescriptor, offset int64) error {
-	data, err := descriptor.Marshal()
-	if err != nil {
-		return err
-	}
-
-	n, err := writer.WriteAt(data[:], offset)
-	if err != nil {
-		return err
-	}
-	if n != len(data) {
-		return errors.New("failed to write descriptor")
-	}
-
-	return nil
-}
-
-func writePathTable(writer io.Writer, location uint32, littleEndian bool) error {
-	//if location == 0 {
-	//	return nil // Skip if no path table is set
-	//}
-	//
-	//pathTable := generatePathTable(littleEndian) // Implement the path table generator
-	//data := pathTable.Marshal()
-	//
-	//_, err := writer.Write(data)
-	//return err
-	return errors.New("not implemented")
-}
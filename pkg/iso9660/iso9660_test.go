@@ -0,0 +1,277 @@
+package iso9660
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// damagedReader fails every read touching one of its bad sectors, like a scratched disc.
+type damagedReader struct {
+	data []byte
+	bad  map[int64]bool
+}
+
+func (r *damagedReader) ReadAt(p []byte, off int64) (int, error) {
+	for sector := off / consts.ISO9660_SECTOR_SIZE; sector*consts.ISO9660_SECTOR_SIZE < off+int64(len(p)); sector++ {
+		if r.bad[sector] {
+			return 0, errors.New("input/output error")
+		}
+	}
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func TestTolerantParsing(t *testing.T) {
+	large := make([]byte, 3*consts.ISO9660_SECTOR_SIZE)
+	for i := range large {
+		large[i] = byte(i/consts.ISO9660_SECTOR_SIZE + 1)
+	}
+	img, err := Create("DAMAGED")
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/DOCS/A.TXT", []byte("alpha")))
+	require.NoError(t, img.AddFile("/DOCS/B.BIN", large))
+	require.NoError(t, img.AddFile("/DOCS/C.TXT", []byte("gamma")))
+	require.NoError(t, img.AddFile("/LOST/D.TXT", []byte("delta")))
+	data := isotest.Bytes(t, img)
+
+	opened, err := Open(bytes.NewReader(data), option.WithStripVersionInfo(false))
+	require.NoError(t, err)
+	entries := make(map[string]*filesystem.FileSystemEntry)
+	all, err := opened.entries()
+	require.NoError(t, err)
+	for _, entry := range all {
+		entries[entry.Name] = entry
+	}
+
+	// The big-endian copy of the extent location of C.TXT no longer matches, the directory of D.TXT and the second
+	// sector of B.BIN cannot be read
+	record := entries["C.TXT;1"].DirectoryRecord().ObjectLocation
+	data[record+6]++
+	r := &damagedReader{data: data, bad: map[int64]bool{
+		int64(entries["LOST"].Location):        true,
+		int64(entries["B.BIN;1"].Location) + 1: true,
+	}}
+
+	_, err = Open(r)
+	require.ErrorContains(t, err, "failed to parse directory record")
+
+	damaged, err := Open(r, option.WithTolerantParsing(true))
+	require.NoError(t, err)
+	files, err := damaged.ListFiles()
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.FullPath)
+	}
+	require.ElementsMatch(t, []string{"/DOCS/A.TXT;1", "/DOCS/B.BIN;1"}, names)
+
+	dir := t.TempDir()
+	require.NoError(t, damaged.Extract(dir))
+	content, err := os.ReadFile(filepath.Join(dir, "DOCS", "A.TXT;1"))
+	require.NoError(t, err)
+	require.Equal(t, "alpha", string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "DOCS", "B.BIN;1"))
+	require.NoError(t, err)
+	salvaged := bytes.Clone(large)
+	clear(salvaged[consts.ISO9660_SECTOR_SIZE : 2*consts.ISO9660_SECTOR_SIZE])
+	require.Equal(t, salvaged, content)
+
+	problems := damaged.Problems()
+	require.Len(t, problems, 3)
+	require.Equal(t, "directory record", problems[0].Structure)
+	require.Equal(t, record, problems[0].Offset)
+	require.Equal(t, entries["DOCS"].Location, problems[0].LBA)
+	require.Equal(t, "directory sector", problems[1].Structure)
+	require.Equal(t, entries["LOST"].Location, problems[1].LBA)
+	require.Equal(t, "file data", problems[2].Structure)
+	require.Equal(t, "/DOCS/B.BIN;1", problems[2].Path)
+	require.Equal(t, entries["B.BIN;1"].Location+1, problems[2].LBA)
+	require.Equal(t, int64(problems[2].LBA)*consts.ISO9660_SECTOR_SIZE, problems[2].Offset)
+}
+
+func TestHostileNames(t *testing.T) {
+	img, err := Create("HOSTILE", option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/evil.txt", []byte("evil")))
+	require.NoError(t, img.AddFile("/updir/f.txt", []byte("up")))
+	data := isotest.Bytes(t, img)
+
+	// Rename evil.txt to ../x.txt and flag the NM entry of updir as naming the parent directory
+	nm := func(name string) int {
+		i := bytes.Index(data, append([]byte{'N', 'M', byte(5 + len(name)), 1, 0}, name...))
+		require.NotEqual(t, -1, i, name)
+		return i
+	}
+	copy(data[nm("evil.txt")+5:], "../x.txt")
+	data[nm("updir")+4] = 0x04
+
+	opened, err := Open(bytes.NewReader(data), option.WithRockRidgeEnabled(true))
+	require.NoError(t, err)
+	files, err := opened.ListFiles()
+	require.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.FullPath)
+	}
+	require.ElementsMatch(t, []string{"/EVIL.TXT;1", "/UPDIR/f.txt"}, names)
+
+	parent := t.TempDir()
+	require.NoError(t, opened.Extract(filepath.Join(parent, "out")))
+	extracted, err := os.ReadDir(parent)
+	require.NoError(t, err)
+	require.Len(t, extracted, 1)
+
+	_, err = extractPath(parent, "/../x.txt")
+	require.ErrorContains(t, err, "outside of the output directory")
+}
+
+func TestChainedSymlinks(t *testing.T) {
+	img, err := Create("CHAINED", option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddSymlink("/b", "."))
+	require.NoError(t, img.AddSymlink("/e", "b/.."))
+	require.NoError(t, img.AddFile("/x/pwned.txt", []byte("pwned")))
+	data := isotest.Bytes(t, img)
+
+	// Rename the directory x to e so its file is written through the e link
+	i := bytes.Index(data, []byte{'N', 'M', 6, 1, 0, 'x'})
+	require.NotEqual(t, -1, i)
+	data[i+5] = 'e'
+
+	opened, err := Open(bytes.NewReader(data), option.WithRockRidgeEnabled(true))
+	require.NoError(t, err)
+	parent := t.TempDir()
+	out := filepath.Join(parent, "out")
+	require.NoError(t, opened.Extract(out))
+
+	// b stays inside on its own, e only escapes through b and is skipped
+	target, err := os.Readlink(filepath.Join(out, "b"))
+	require.NoError(t, err)
+	require.Equal(t, ".", target)
+	info, err := os.Lstat(filepath.Join(out, "e"))
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	_, err = os.Stat(filepath.Join(parent, "pwned.txt"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Links on disk are followed when checking where files are written
+	require.NoError(t, os.RemoveAll(filepath.Join(out, "e")))
+	require.NoError(t, os.Symlink("b/..", filepath.Join(out, "e")))
+	_, err = extractPath(out, "/e/pwned.txt")
+	require.ErrorContains(t, err, "outside of the output directory")
+	_, err = extractPath(out, "/b/b/x/pwned.txt")
+	require.NoError(t, err)
+
+	opened, err = Open(bytes.NewReader(data), option.WithRockRidgeEnabled(true),
+		option.WithSymlinkPolicy(filesystem.SYMLINK_POLICY_ERROR))
+	require.NoError(t, err)
+	require.ErrorIs(t, opened.Extract(filepath.Join(parent, "strict")), filesystem.ErrUnsafeSymlink)
+}
+
+func TestSpecialFilePolicy(t *testing.T) {
+	img, err := Create("SPECIAL", option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/dev/console", nil))
+	require.NoError(t, img.SetFileOptions("/dev/console", option.WithFileMode(0o600)))
+	require.NoError(t, img.AddFile("/run/initctl", nil))
+	require.NoError(t, img.AddFile("/readme.txt", []byte("hello")))
+	data := isotest.Bytes(t, img)
+
+	// Images are only authored with regular files, turn the two empty files into a character device and a FIFO by
+	// rewriting the file type in their PX entries
+	opened, err := Open(bytes.NewReader(data))
+	require.NoError(t, err)
+	for name, mode := range map[string]uint32{"/dev/console": 0x2000 | 0o600, "/run/initctl": 0x1000 | 0o644} {
+		entry, err := opened.findEntry(name)
+		require.NoError(t, err)
+		record := data[entry.DirectoryRecord().ObjectLocation:]
+		px := bytes.Index(record[:record[0]], []byte{'P', 'X'})
+		require.NotEqual(t, -1, px, name)
+		binary.LittleEndian.PutUint32(record[px+4:], mode)
+		binary.BigEndian.PutUint32(record[px+8:], mode)
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		out, manifest := t.TempDir(), filepath.Join(t.TempDir(), "manifest.txt")
+		opened, err := Open(bytes.NewReader(data), option.WithSpecialFileManifest(manifest))
+		require.NoError(t, err)
+		require.NoError(t, opened.Extract(out))
+
+		// Special files are listed in the manifest rather than written as empty regular files
+		content, err := os.ReadFile(manifest)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"nod /dev/console 0600 0 0 c 0 0", "pipe /run/initctl 0644 0 0"},
+			strings.Split(strings.TrimSpace(string(content)), "\n"))
+		for _, name := range []string{"dev/console", "run/initctl"} {
+			_, err := os.Lstat(filepath.Join(out, name))
+			require.ErrorIs(t, err, fs.ErrNotExist, name)
+		}
+		readme, err := os.ReadFile(filepath.Join(out, "readme.txt"))
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(readme))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		opened, err := Open(bytes.NewReader(data), option.WithSpecialFilePolicy(filesystem.SPECIAL_FILE_POLICY_ERROR))
+		require.NoError(t, err)
+		require.ErrorIs(t, opened.Extract(t.TempDir()), filesystem.ErrSpecialFile)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Geteuid() != 0 {
+			t.Skip("device nodes can only be created by root")
+		}
+		out := t.TempDir()
+		opened, err := Open(bytes.NewReader(data), option.WithSpecialFilePolicy(filesystem.SPECIAL_FILE_POLICY_CREATE))
+		require.NoError(t, err)
+		require.NoError(t, opened.Extract(out))
+		info, err := os.Lstat(filepath.Join(out, "run", "initctl"))
+		require.NoError(t, err)
+		require.NotZero(t, info.Mode()&fs.ModeNamedPipe)
+		info, err = os.Lstat(filepath.Join(out, "dev", "console"))
+		require.NoError(t, err)
+		require.NotZero(t, info.Mode()&fs.ModeCharDevice)
+	})
+}
+
+func TestTolerantDirectoryLength(t *testing.T) {
+	img, err := Create("DAMAGED")
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/DOCS/A.TXT", []byte("alpha")))
+	data := isotest.Bytes(t, img)
+
+	opened, err := Open(bytes.NewReader(data))
+	require.NoError(t, err)
+	dirs, err := opened.ListDirectories()
+	require.NoError(t, err)
+	require.Len(t, dirs, 1)
+
+	// The data length of DOCS claims close to 4 GiB
+	record := dirs[0].DirectoryRecord().ObjectLocation
+	binary.LittleEndian.PutUint32(data[record+10:], 0xFFFFF800)
+	binary.BigEndian.PutUint32(data[record+14:], 0xFFFFF800)
+
+	_, err = Open(bytes.NewReader(data))
+	require.ErrorContains(t, err, "exceeds the maximum")
+
+	damaged, err := Open(bytes.NewReader(data), option.WithTolerantParsing(true))
+	require.NoError(t, err)
+	content, err := damaged.ReadFile("DOCS/A.TXT;1")
+	require.NoError(t, err)
+	require.Equal(t, "alpha", string(content))
+	problems := damaged.Problems()
+	require.Len(t, problems, 1)
+	require.Equal(t, "directory extent", problems[0].Structure)
+	require.Equal(t, dirs[0].Location, problems[0].LBA)
+}
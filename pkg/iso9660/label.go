@@ -0,0 +1,190 @@
+package iso9660
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/descriptor"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// VolumeLabel holds the volume descriptor fields that can be changed in place. Nil fields are left unchanged.
+type VolumeLabel struct {
+	VolumeID         *string
+	SystemID         *string
+	VolumeSetID      *string
+	PublisherID      *string
+	PreparerID       *string
+	ApplicationID    *string
+	CreationTime     *time.Time
+	ModificationTime *time.Time
+	ExpirationTime   *time.Time
+	EffectiveTime    *time.Time
+}
+
+// labelField describes how an identifier is recorded in the primary and supplementary descriptors.
+type labelField struct {
+	name          string
+	value         *string
+	size          int  // bytes in the descriptor, half as many UCS-2 characters in a Joliet descriptor
+	dChars        bool // the field only allows d-characters rather than a-characters
+	primary       *string
+	supplementary func(svd *descriptor.SupplementaryVolumeDescriptor) *string
+	required      bool
+}
+
+// Relabel rewrites the identifiers and dates of the primary volume descriptor and every supplementary volume
+// descriptor in place. Only the descriptor sectors are written, through their Marshal methods, so w must write to
+// the image the ISO9660 was opened from. Like PatchFile, images read through a sector translation, a decompressor or
+// over HTTP cannot be relabeled.
+//
+// The primary descriptor and non-Joliet supplementary descriptors, such as the ISO 9660:1999 enhanced descriptor,
+// record identifiers in upper case with characters outside the a-character (or d-character) set replaced by '_'.
+// Joliet descriptors record the identifiers unchanged in UCS-2. Identifiers that do not fit the primary field, or
+// the Joliet field, are rejected before anything is written.
+func (iso *ISO9660) Relabel(w io.WriterAt, label VolumeLabel) error {
+	if iso.isoReader == nil {
+		return errors.New("only images opened from a reader can be relabeled")
+	}
+	if !untranslated(iso.isoReader) {
+		return errors.New("cannot relabel an image read through a sector translation, a decompressor or over HTTP")
+	}
+	pvd := iso.volumeDescriptorSet.Primary
+
+	supplementary := iso.volumeDescriptorSet.Supplementary
+	hasJoliet := false
+	for _, svd := range supplementary {
+		hasJoliet = hasJoliet || svd.IsJoliet()
+	}
+
+	body := &pvd.PrimaryVolumeDescriptorBody
+	fields := []labelField{
+		{name: "volume identifier", value: label.VolumeID, size: 32, dChars: true, primary: &body.VolumeIdentifier, required: true,
+			supplementary: func(svd *descriptor.SupplementaryVolumeDescriptor) *string {
+				return &svd.SupplementaryVolumeDescriptorBody.VolumeIdentifier
+			}},
+		{name: "system identifier", value: label.SystemID, size: 32, primary: &body.SystemIdentifier,
+			supplementary: func(svd *descriptor.SupplementaryVolumeDescriptor) *string {
+				return &svd.SupplementaryVolumeDescriptorBody.SystemIdentifier
+			}},
+		{name: "volume set identifier", value: label.VolumeSetID, size: 128, dChars: true, primary: &body.VolumeSetIdentifier,
+			supplementary: func(svd *descriptor.SupplementaryVolumeDescriptor) *string {
+				return &svd.SupplementaryVolumeDescriptorBody.VolumeSetIdentifier
+			}},
+		{name: "publisher identifier", value: label.PublisherID, size: 128, primary: &body.PublisherIdentifier,
+			supplementary: func(svd *descriptor.SupplementaryVolumeDescriptor) *string {
+				return &svd.SupplementaryVolumeDescriptorBody.PublisherIdentifier
+			}},
+		{name: "data preparer identifier", value: label.PreparerID, size: 128, primary: &body.DataPreparerIdentifier,
+			supplementary: func(svd *descriptor.SupplementaryVolumeDescriptor) *string {
+				return &svd.SupplementaryVolumeDescriptorBody.DataPreparerIdentifier
+			}},
+		{name: "application identifier", value: label.ApplicationID, size: 128, primary: &body.ApplicationIdentifier,
+			supplementary: func(svd *descriptor.SupplementaryVolumeDescriptor) *string {
+				return &svd.SupplementaryVolumeDescriptorBody.ApplicationIdentifier
+			}},
+	}
+
+	// Validate everything before changing any descriptor
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		if f.required && *f.value == "" {
+			return fmt.Errorf("%s cannot be empty", f.name)
+		}
+		if len(primaryCharacters(*f.value, f.dChars)) > f.size {
+			return fmt.Errorf("%s %q exceeds %d characters", f.name, *f.value, f.size)
+		}
+		if hasJoliet && len(utf16.Encode([]rune(*f.value))) > f.size/2 {
+			return fmt.Errorf("%s %q exceeds %d Joliet characters", f.name, *f.value, f.size/2)
+		}
+	}
+
+	for _, f := range fields {
+		if f.value == nil {
+			continue
+		}
+		*f.primary = primaryCharacters(*f.value, f.dChars)
+		for _, svd := range supplementary {
+			if svd.IsJoliet() {
+				*f.supplementary(svd) = *f.value
+			} else {
+				*f.supplementary(svd) = primaryCharacters(*f.value, f.dChars)
+			}
+		}
+	}
+
+	for _, d := range []struct {
+		value         *time.Time
+		primary       *time.Time
+		supplementary func(svd *descriptor.SupplementaryVolumeDescriptor) *time.Time
+	}{
+		{label.CreationTime, &body.VolumeCreationDateAndTime, func(svd *descriptor.SupplementaryVolumeDescriptor) *time.Time {
+			return &svd.VolumeCreationDateAndTime
+		}},
+		{label.ModificationTime, &body.VolumeModificationDateAndTime, func(svd *descriptor.SupplementaryVolumeDescriptor) *time.Time {
+			return &svd.VolumeModificationDateAndTime
+		}},
+		{label.ExpirationTime, &body.VolumeExpirationDateAndTime, func(svd *descriptor.SupplementaryVolumeDescriptor) *time.Time {
+			return &svd.VolumeExpirationDateAndTime
+		}},
+		{label.EffectiveTime, &body.VolumeEffectiveDateAndTime, func(svd *descriptor.SupplementaryVolumeDescriptor) *time.Time {
+			return &svd.VolumeEffectiveDateAndTime
+		}},
+	} {
+		if d.value == nil {
+			continue
+		}
+		*d.primary = *d.value
+		for _, svd := range supplementary {
+			*d.supplementary(svd) = *d.value
+		}
+	}
+
+	// Marshal every descriptor first so a failure does not leave the image half updated
+	type sector struct {
+		name   string
+		data   []byte
+		offset int64
+	}
+	data, err := pvd.Marshal()
+	if err != nil {
+		return fmt.Errorf("failed to marshal primary volume descriptor: %w", err)
+	}
+	writes := []sector{{"primary volume descriptor", data, pvd.ObjectLocation}}
+	for _, svd := range supplementary {
+		data, err := svd.Marshal()
+		if err != nil {
+			return fmt.Errorf("failed to marshal supplementary volume descriptor: %w", err)
+		}
+		writes = append(writes, sector{"supplementary volume descriptor", data, svd.ObjectLocation})
+	}
+
+	for _, s := range writes {
+		if _, err := w.WriteAt(s.data, s.offset); err != nil {
+			return fmt.Errorf("failed to write %s: %w", s.name, err)
+		}
+	}
+
+	iso.logger.Debug("Relabeled image", "volume", pvd.VolumeIdentifier(), "descriptors", len(writes))
+	return nil
+}
+
+// primaryCharacters converts s to the character set allowed in a primary volume descriptor field.
+func primaryCharacters(s string, dChars bool) string {
+	const aSymbols = " !\"%&'()*+,-./:;<=>?"
+	return strings.Map(func(r rune) rune {
+		r = []rune(strings.ToUpper(string(r)))[0]
+		switch {
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_':
+			return r
+		case !dChars && strings.ContainsRune(aSymbols, r):
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
@@ -0,0 +1,66 @@
+package iso9660
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRelabel(t *testing.T) {
+	img, err := Create("OLD_LABEL", option.WithJolietEnabled(true), option.WithEnhancedEnabled(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/readme.txt", []byte("hello")))
+	out := isotest.File(t, img)
+
+	opened, err := Open(out)
+	require.NoError(t, err)
+	require.Len(t, opened.volumeDescriptorSet.Supplementary, 2)
+
+	volumeID, publisher := "New Label", "ACME Corp."
+	created := time.Date(2020, 2, 3, 4, 5, 6, 0, time.UTC)
+	require.NoError(t, opened.Relabel(out, VolumeLabel{VolumeID: &volumeID, PublisherID: &publisher, CreationTime: &created}))
+
+	reopened, err := Open(out)
+	require.NoError(t, err)
+	require.Equal(t, "NEW_LABEL", reopened.GetVolumeID())
+	require.Equal(t, "ACME CORP.", reopened.GetPublisherID())
+	require.True(t, created.Equal(reopened.GetCreationDateTime()))
+
+	// Joliet descriptors keep the identifiers unchanged, the enhanced descriptor uses the primary character set
+	var joliet, enhanced int
+	for _, svd := range reopened.volumeDescriptorSet.Supplementary {
+		body := svd.SupplementaryVolumeDescriptorBody
+		switch {
+		case svd.IsJoliet():
+			joliet++
+			require.Equal(t, volumeID, strings.TrimRight(body.VolumeIdentifier, " \x00"))
+			require.Equal(t, publisher, strings.TrimRight(body.PublisherIdentifier, " \x00"))
+		default:
+			enhanced++
+			require.Equal(t, "NEW_LABEL", strings.TrimRight(body.VolumeIdentifier, " \x00"))
+			require.Equal(t, "ACME CORP.", strings.TrimRight(body.PublisherIdentifier, " \x00"))
+		}
+		require.True(t, created.Equal(body.VolumeCreationDateAndTime))
+	}
+	require.Equal(t, 1, joliet)
+	require.Equal(t, 1, enhanced)
+
+	// Identifiers that do not fit are rejected without writing anything
+	before, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	tooLong := strings.Repeat("X", 33)
+	require.ErrorContains(t, reopened.Relabel(out, VolumeLabel{VolumeID: &tooLong}), "exceeds")
+	empty := ""
+	require.ErrorContains(t, reopened.Relabel(out, VolumeLabel{VolumeID: &empty}), "cannot be empty")
+	translated, err := Open(io.NewSectionReader(out, 0, int64(len(before))))
+	require.NoError(t, err)
+	require.ErrorContains(t, translated.Relabel(out, VolumeLabel{VolumeID: &volumeID}), "sector translation")
+	after, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+}
@@ -0,0 +1,242 @@
+package iso9660
+
+import (
+	"container/list"
+	"errors"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/directory"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/encoding"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/pathtable"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	// Default number of directory sectors kept in memory when directories are loaded lazily (8 MiB)
+	DEFAULT_DIRECTORY_CACHE_SECTORS = 4096
+)
+
+// lazyLookup resolves fs.FS names of an image opened without parsing on open. The path table of the hierarchy gives
+// the location of every directory, so only the directories named by a path are read.
+type lazyLookup struct {
+	root   *directory.DirectoryRecord
+	joliet bool
+	// rockRidge is set when names come from Rock Ridge NM entries, which path tables do not record
+	rockRidge bool
+	// Path table directory numbers by location, and child directory numbers by parent number and identifier
+	numbers   map[uint32]uint16
+	locations []uint32
+	children  map[uint16]map[string]uint16
+}
+
+// enableLazyLoading prepares the image to look up entries through the path table of the selected hierarchy, reading
+// directory sectors through a bounded cache.
+func (iso *ISO9660) enableLazyLoading() {
+	size := iso.openOptions.DirectoryCacheSize
+	if size <= 0 {
+		size = DEFAULT_DIRECTORY_CACHE_SECTORS
+	}
+	iso.parser.SetDirectoryReader(newSectorCache(iso.isoReader, size))
+
+	// The merged view needs both hierarchies in full, so its entries are read on first use instead
+	if iso.openOptions.MergedView {
+		return
+	}
+
+	lookup := &lazyLookup{root: iso.volumeDescriptorSet.Primary.RootDirectoryRecord}
+	table := iso.pathTables[0]
+	if svd := iso.preferredSupplementary(); svd != nil {
+		lookup.root = svd.RootDirectoryRecord
+		lookup.joliet = svd.IsJoliet()
+		table = iso.pathTables[2*(slices.Index(iso.volumeDescriptorSet.Supplementary, svd)+1)]
+	}
+	if iso.openOptions.RockRidgeEnabled && !lookup.joliet {
+		lookup.rockRidge = iso.hasRockRidgeRoot(lookup.root)
+	}
+	lookup.index(table)
+	iso.lazy = lookup
+}
+
+// hasRockRidgeRoot returns true if the "." record of the root directory carries Rock Ridge entries. Without being able
+// to read it names are assumed to come from Rock Ridge, which only means path table identifiers are not trusted.
+func (iso *ISO9660) hasRockRidgeRoot(root *directory.DirectoryRecord) bool {
+	records, err := iso.parser.ReadDirectory(root.LocationOfExtent, false)
+	if err != nil || len(records) == 0 {
+		return true
+	}
+	return records[0].RockRidge != nil && records[0].RockRidge.HasRockRidge()
+}
+
+// index builds the directory number maps from a path table. Directory numbers start at 1 for the root.
+func (l *lazyLookup) index(table *pathtable.PathTable) {
+	l.numbers = make(map[uint32]uint16)
+	l.children = make(map[uint16]map[string]uint16)
+	l.locations = []uint32{0}
+	for i, record := range table.Records {
+		number := uint16(i + 1)
+		l.numbers[record.LocationOfExtent] = number
+		l.locations = append(l.locations, record.LocationOfExtent)
+		if number == 1 {
+			continue
+		}
+		identifier := record.DirectoryIdentifier
+		if l.joliet {
+			identifier = encoding.DecodeUCS2BigEndian([]byte(identifier))
+		}
+		if l.children[record.ParentDirectoryNumber] == nil {
+			l.children[record.ParentDirectoryNumber] = make(map[string]uint16)
+		}
+		l.children[record.ParentDirectoryNumber][identifier] = number
+	}
+}
+
+// resolveDirectory returns the extent location of the directory with the given fs.FS name. Path components found in
+// the path table are resolved without any reads, the others by reading their parent directory.
+func (iso *ISO9660) resolveDirectory(name string) (uint32, error) {
+	l := iso.lazy
+	location := l.root.LocationOfExtent
+	if name == "." {
+		return location, nil
+	}
+	number := uint16(1)
+	for _, component := range strings.Split(name, "/") {
+		if child, ok := l.children[number][component]; ok && !l.rockRidge && int(child) < len(l.locations) {
+			number, location = child, l.locations[child]
+			continue
+		}
+
+		records, err := iso.parser.ReadDirectory(location, l.joliet)
+		if err != nil {
+			return 0, err
+		}
+		found := false
+		for _, record := range records {
+			if !record.IsSpecial() && record.GetBestName(l.rockRidge) == component {
+				if !record.IsDirectory() {
+					return 0, errors.New("not a directory")
+				}
+				location, number, found = record.LocationOfExtent, l.numbers[record.LocationOfExtent], true
+				break
+			}
+		}
+		if !found {
+			return 0, fs.ErrNotExist
+		}
+	}
+	return location, nil
+}
+
+// readDirectoryEntries returns the entries of the directory with the given fs.FS name sorted by name.
+func (iso *ISO9660) readDirectoryEntries(name string) ([]*filesystem.FileSystemEntry, error) {
+	location, err := iso.resolveDirectory(name)
+	if err != nil {
+		return nil, err
+	}
+	records, err := iso.parser.ReadDirectory(location, iso.lazy.joliet)
+	if err != nil {
+		return nil, err
+	}
+	parentPath := ""
+	if name != "." {
+		parentPath = "/" + name
+	}
+	var entries []*filesystem.FileSystemEntry
+	for _, record := range records {
+		if record.IsSpecial() {
+			continue
+		}
+		entries = append(entries, iso.parser.BuildFileSystemEntry(record, parentPath, iso.lazy.rockRidge))
+	}
+	slices.SortFunc(entries, func(a, b *filesystem.FileSystemEntry) int {
+		return strings.Compare(fsName(a), fsName(b))
+	})
+	return entries, nil
+}
+
+// lookupLazy returns the entry with the given fs.FS name, reading only the directories on its path.
+func (iso *ISO9660) lookupLazy(name string) (*filesystem.FileSystemEntry, error) {
+	entries, err := iso.readDirectoryEntries(path.Dir(name))
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if fsName(entry) == name {
+			return entry, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// sectorCache is an io.ReaderAt that keeps the most recently read sectors of an image in memory.
+type sectorCache struct {
+	reader  io.ReaderAt
+	size    int
+	mu      sync.Mutex
+	lru     *list.List
+	sectors map[int64]*list.Element
+}
+
+// cachedSector is a sector held by a sectorCache. Data is shorter than a sector at the end of the image.
+type cachedSector struct {
+	number int64
+	data   []byte
+}
+
+// newSectorCache creates a cache holding at most size sectors read from reader.
+func newSectorCache(reader io.ReaderAt, size int) *sectorCache {
+	return &sectorCache{
+		reader:  reader,
+		size:    size,
+		lru:     list.New(),
+		sectors: make(map[int64]*list.Element),
+	}
+}
+
+// ReadAt implements io.ReaderAt, reading whole sectors from the underlying reader when they are not cached.
+func (c *sectorCache) ReadAt(p []byte, off int64) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		data, err := c.sector(pos / consts.ISO9660_SECTOR_SIZE)
+		if err != nil {
+			return n, err
+		}
+		start := int(pos % consts.ISO9660_SECTOR_SIZE)
+		if start >= len(data) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], data[start:])
+	}
+	return n, nil
+}
+
+// sector returns the content of a sector, reading it and evicting the least recently used one if it is not cached.
+func (c *sectorCache) sector(number int64) ([]byte, error) {
+	if elem, ok := c.sectors[number]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*cachedSector).data, nil
+	}
+
+	data := make([]byte, consts.ISO9660_SECTOR_SIZE)
+	read, err := c.reader.ReadAt(data, number*consts.ISO9660_SECTOR_SIZE)
+	if err != nil && !(errors.Is(err, io.EOF) && read > 0) {
+		return nil, err
+	}
+	data = data[:read]
+
+	c.sectors[number] = c.lru.PushFront(&cachedSector{number: number, data: data})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.sectors, oldest.Value.(*cachedSector).number)
+	}
+	return data, nil
+}
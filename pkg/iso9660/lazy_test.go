@@ -0,0 +1,121 @@
+package iso9660
+
+import (
+	"bytes"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLazyLoading(t *testing.T) {
+	img, err := Create("LAZY_TEST", option.WithJolietEnabled(true), option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/etc/hosts", []byte("127.0.0.1 localhost\n")))
+	require.NoError(t, img.AddFile("/usr/Share/Doc/readme.txt", []byte("hello")))
+
+	out := isotest.File(t, img)
+
+	for _, opts := range [][]option.OpenOption{
+		{option.WithParseOnOpen(false)},
+		{option.WithParseOnOpen(false), option.WithPreferJoliet(true), option.WithDirectoryCacheSize(1)},
+	} {
+		opened, err := Open(out, opts...)
+		require.NoError(t, err)
+		require.Nil(t, opened.filesystemEntries)
+
+		// Every directory is recorded in the type L and type M Joliet path tables
+		tableL, tableM := opened.pathTables[2], opened.pathTables[3]
+		require.Len(t, tableL.Records, len(tableM.Records))
+		for i, record := range tableL.Records {
+			require.NotZero(t, record.LocationOfExtent)
+			require.Equal(t, tableM.Records[i].LocationOfExtent, record.LocationOfExtent)
+		}
+
+		data, err := fs.ReadFile(opened, "usr/Share/Doc/readme.txt")
+		require.NoError(t, err)
+		require.Equal(t, "hello", string(data))
+		_, err = fs.Stat(opened, "usr/missing/readme.txt")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		require.NoError(t, fstest.TestFS(opened, "etc/hosts", "usr/Share/Doc/readme.txt"))
+
+		files, err := opened.ListFiles()
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+	}
+}
+
+func TestLazyLookupReads(t *testing.T) {
+	img, err := Create("LAZY_READS")
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/a/b/c/d/file.txt", []byte("deep")))
+	for _, dir := range []string{"e", "f", "g", "a/h", "a/b/i"} {
+		require.NoError(t, img.AddFile("/"+dir+"/other.txt", []byte(dir)))
+	}
+	data := isotest.Bytes(t, img)
+
+	// The location of the directory holding the file
+	parsed, err := Open(bytes.NewReader(data), option.WithRockRidgeEnabled(false))
+	require.NoError(t, err)
+	parent, err := parsed.findEntry("/A/B/C/D")
+	require.NoError(t, err)
+
+	// The path table locates every directory, so looking up the file only reads the directory holding it
+	counter := &countingReader{ReaderAt: bytes.NewReader(data)}
+	opened, err := Open(counter, option.WithParseOnOpen(false), option.WithRockRidgeEnabled(false))
+	require.NoError(t, err)
+	counter.reads, counter.offsets = 0, nil
+	info, err := fs.Stat(opened, "A/B/C/D/FILE.TXT;1")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), info.Size())
+	require.Equal(t, []int64{int64(parent.Location) * consts.ISO9660_SECTOR_SIZE}, counter.offsets)
+
+	// A sibling directory needs one more read, the same one again is served from the sector cache
+	_, err = fs.Stat(opened, "A/B/I/OTHER.TXT;1")
+	require.NoError(t, err)
+	require.Len(t, counter.offsets, 2)
+	// Looking it up again is served from the sector cache
+	_, err = fs.Stat(opened, "A/B/C/D/FILE.TXT;1")
+	require.NoError(t, err)
+	require.Len(t, counter.offsets, 2)
+}
+
+func TestSectorCache(t *testing.T) {
+	data := make([]byte, 4*consts.ISO9660_SECTOR_SIZE)
+	for i := range data {
+		data[i] = byte(i / consts.ISO9660_SECTOR_SIZE)
+	}
+	counter := &countingReader{ReaderAt: bytes.NewReader(data)}
+	cache := newSectorCache(counter, 2)
+
+	buf := make([]byte, 1)
+	for _, sector := range []int64{0, 1, 2} {
+		_, err := cache.ReadAt(buf, sector*consts.ISO9660_SECTOR_SIZE)
+		require.NoError(t, err)
+		require.Equal(t, byte(sector), buf[0])
+	}
+
+	// The least recently used sector is evicted once the cache holds its bound
+	require.Equal(t, 2, cache.lru.Len())
+	require.Len(t, cache.sectors, 2)
+	require.NotContains(t, cache.sectors, int64(0))
+	_, err := cache.ReadAt(buf, 2*consts.ISO9660_SECTOR_SIZE)
+	require.NoError(t, err)
+	require.Equal(t, 3, counter.reads)
+	_, err = cache.ReadAt(buf, 0)
+	require.NoError(t, err)
+	require.Equal(t, 4, counter.reads)
+	require.NotContains(t, cache.sectors, int64(1))
+
+	// Reads spanning sectors and the end of the image
+	span := make([]byte, consts.ISO9660_SECTOR_SIZE+10)
+	n, err := cache.ReadAt(span, 3*consts.ISO9660_SECTOR_SIZE-5)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, consts.ISO9660_SECTOR_SIZE+5, n)
+	require.Equal(t, byte(2), span[0])
+	require.Equal(t, byte(3), span[5])
+}
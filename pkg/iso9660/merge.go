@@ -0,0 +1,204 @@
+package iso9660
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"path"
+	"strings"
+)
+
+// MergeReport lists the entries of a merged view that were only found in one of the two hierarchies. Paths are the
+// paths of the entries in the merged view.
+type MergeReport struct {
+	// Entries recorded in the primary hierarchy but not in the supplementary one, such as Rock Ridge symbolic links
+	PrimaryOnly []string
+	// Entries recorded in the supplementary (Joliet or enhanced) hierarchy but not in the primary one
+	SupplementaryOnly []string
+}
+
+// mergeTree indexes the entries of one hierarchy by their parent directory.
+type mergeTree struct {
+	children map[string][]*filesystem.FileSystemEntry
+	// signatures holds the lowest file extent below each directory, which identifies the directory in both trees
+	signatures map[string]uint32
+}
+
+// newMergeTree indexes the entries of a hierarchy.
+func newMergeTree(entries []*filesystem.FileSystemEntry) *mergeTree {
+	t := &mergeTree{
+		children:   make(map[string][]*filesystem.FileSystemEntry),
+		signatures: make(map[string]uint32),
+	}
+	for _, entry := range entries {
+		parent := path.Dir(entry.FullPath)
+		t.children[parent] = append(t.children[parent], entry)
+	}
+	t.signature("/")
+	return t
+}
+
+// signature returns the lowest extent location of the non-empty files below the directory, or 0 if it has none.
+func (t *mergeTree) signature(dir string) uint32 {
+	if sig, ok := t.signatures[dir]; ok {
+		return sig
+	}
+	var sig uint32
+	for _, child := range t.children[dir] {
+		location := child.Location
+		if child.IsDir {
+			location = t.signature(child.FullPath)
+		} else if child.Size == 0 {
+			location = 0
+		}
+		if location != 0 && (sig == 0 || location < sig) {
+			sig = location
+		}
+	}
+	t.signatures[dir] = sig
+	return sig
+}
+
+// mergeHierarchies combines the entries of the primary hierarchy with those of a supplementary hierarchy. Entries are
+// paired by the extent of their data, directories by the extents of the files they contain, and by name when neither
+// identifies them. Paired entries keep the metadata of the primary record, including any Rock Ridge attributes, and
+// take the Rock Ridge name when there is one or the supplementary name otherwise.
+func mergeHierarchies(primary, supplementary []*filesystem.FileSystemEntry, rockRidge bool) ([]*filesystem.FileSystemEntry, *MergeReport) {
+	pt, st := newMergeTree(primary), newMergeTree(supplementary)
+	report := &MergeReport{}
+	var merged []*filesystem.FileSystemEntry
+
+	// add appends an entry found in only one hierarchy, together with everything below it
+	var add func(t *mergeTree, entry *filesystem.FileSystemEntry, parent string, only *[]string)
+	add = func(t *mergeTree, entry *filesystem.FileSystemEntry, parent string, only *[]string) {
+		e := *entry
+		e.FullPath = path.Join(parent, e.Name)
+		merged = append(merged, &e)
+		*only = append(*only, e.FullPath)
+		if e.IsDir {
+			for _, child := range t.children[entry.FullPath] {
+				add(t, child, e.FullPath, only)
+			}
+		}
+	}
+
+	var walk func(pDir, sDir, parent string)
+	walk = func(pDir, sDir, parent string) {
+		pChildren, sChildren := pt.children[pDir], st.children[sDir]
+		matched := make(map[*filesystem.FileSystemEntry]bool)
+
+		for _, p := range pChildren {
+			s := findCounterpart(p, sChildren, matched, pt, st)
+			if s == nil {
+				add(pt, p, parent, &report.PrimaryOnly)
+				continue
+			}
+			matched[s] = true
+
+			e := *p
+			if !rockRidge || !hasAlternateName(p) {
+				e.Name = s.Name
+			}
+			e.FullPath = path.Join(parent, e.Name)
+			merged = append(merged, &e)
+			if e.IsDir {
+				walk(p.FullPath, s.FullPath, e.FullPath)
+			}
+		}
+
+		for _, s := range sChildren {
+			if !matched[s] {
+				add(st, s, parent, &report.SupplementaryOnly)
+			}
+		}
+	}
+	walk("/", "/", "/")
+
+	return merged, report
+}
+
+// findCounterpart returns the unmatched supplementary entry describing the same file or directory as the primary
+// entry, or nil if there is none.
+func findCounterpart(p *filesystem.FileSystemEntry, candidates []*filesystem.FileSystemEntry, matched map[*filesystem.FileSystemEntry]bool, pt, st *mergeTree) *filesystem.FileSystemEntry {
+	// Extents identify files with data and directories containing such files. Hard links share an extent, so the
+	// name decides between several entries with the same one
+	var sameExtent []*filesystem.FileSystemEntry
+	for _, s := range candidates {
+		if matched[s] || s.IsDir != p.IsDir {
+			continue
+		}
+		if p.IsDir {
+			if sig := pt.signature(p.FullPath); sig != 0 && sig == st.signature(s.FullPath) {
+				sameExtent = append(sameExtent, s)
+			}
+		} else if p.Size > 0 && p.Location == s.Location && p.Size == s.Size {
+			sameExtent = append(sameExtent, s)
+		}
+	}
+	if len(sameExtent) > 0 {
+		if s := matchName(p, sameExtent); s != nil {
+			return s
+		}
+		return sameExtent[0]
+	}
+
+	// Empty files and directories are compared by name alone
+	var unidentified []*filesystem.FileSystemEntry
+	for _, s := range candidates {
+		if matched[s] || s.IsDir != p.IsDir {
+			continue
+		}
+		if p.IsDir && pt.signature(p.FullPath) != 0 && st.signature(s.FullPath) != 0 {
+			continue
+		}
+		if !p.IsDir && p.Size > 0 && s.Size > 0 {
+			continue
+		}
+		unidentified = append(unidentified, s)
+	}
+	return matchName(p, unidentified)
+}
+
+// matchName returns the candidate with the same name as the primary entry, ignoring case and version numbers, and
+// otherwise the first one whose name would have been given that identifier in the primary hierarchy.
+func matchName(p *filesystem.FileSystemEntry, candidates []*filesystem.FileSystemEntry) *filesystem.FileSystemEntry {
+	for _, s := range candidates {
+		if strings.EqualFold(trimVersion(p.Name), trimVersion(s.Name)) {
+			return s
+		}
+	}
+	for _, s := range candidates {
+		if mangledMatch(trimVersion(p.Name), trimVersion(s.Name), p.IsDir) {
+			return s
+		}
+	}
+	return nil
+}
+
+// mangledMatch returns true if the primary identifier could have been derived from the supplementary name by
+// upper casing it, replacing characters outside the d-character set and truncating the name and extension.
+func mangledMatch(primary, supplementary string, isDir bool) bool {
+	if isDir {
+		return primary != "" && strings.HasPrefix(isoCharacters(supplementary), isoCharacters(primary))
+	}
+	pBase, pExt, _ := strings.Cut(primary, ".")
+	sBase, sExt := supplementary, ""
+	if i := strings.LastIndex(supplementary, "."); i >= 0 {
+		sBase, sExt = supplementary[:i], supplementary[i+1:]
+	}
+	return pBase != "" && strings.HasPrefix(isoCharacters(sBase), isoCharacters(pBase)) &&
+		strings.HasPrefix(isoCharacters(sExt), isoCharacters(pExt))
+}
+
+// hasAlternateName returns true if the entry name comes from a Rock Ridge NM entry.
+func hasAlternateName(entry *filesystem.FileSystemEntry) bool {
+	dr := entry.DirectoryRecord()
+	return dr != nil && dr.RockRidge != nil && dr.RockRidge.AlternateName != nil && *dr.RockRidge.AlternateName != ""
+}
+
+// trimVersion removes the ";n" version number, and the '.' left by names without an extension, from an ISO9660
+// identifier.
+func trimVersion(name string) string {
+	if i := strings.LastIndex(name, ";"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, ".")
+}
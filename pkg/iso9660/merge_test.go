@@ -0,0 +1,57 @@
+package iso9660
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"testing"
+)
+
+func TestMergedView(t *testing.T) {
+	img, err := Create("MERGE_TEST", option.WithJolietEnabled(true), option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/Docs/Read Me.txt", []byte("hello")))
+	require.NoError(t, img.SetFileOptions("/Docs/Read Me.txt", option.WithFileMode(0o640)))
+	require.NoError(t, img.AddDirectory("/Empty Dir"))
+	require.NoError(t, img.AddSymlink("/link", "Docs/Read Me.txt"))
+
+	out := isotest.File(t, img)
+
+	opened, err := Open(out, option.WithMergedView(true))
+	require.NoError(t, err)
+	info, err := fs.Stat(opened, "Docs/Read Me.txt")
+	require.NoError(t, err)
+	require.Equal(t, fs.FileMode(0o640), info.Mode())
+	require.Equal(t, []string{"/link"}, opened.MergeReport().PrimaryOnly)
+	require.Empty(t, opened.MergeReport().SupplementaryOnly)
+
+	// Without Rock Ridge names the Joliet names are used
+	opened, err = Open(out, option.WithMergedView(true), option.WithRockRidgeEnabled(false))
+	require.NoError(t, err)
+	_, err = fs.Stat(opened, "Empty Dir")
+	require.NoError(t, err)
+	data, err := fs.ReadFile(opened, "Docs/Read Me.txt")
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+	require.Len(t, opened.MergeReport().PrimaryOnly, 1)
+}
+
+func TestMergedViewSharedExtent(t *testing.T) {
+	entry := func(name string, location uint32) *filesystem.FileSystemEntry {
+		return &filesystem.FileSystemEntry{Name: name, FullPath: "/" + name, Location: location, Size: 5}
+	}
+
+	// Hard links share an extent, so the names pair them whatever order the records are in
+	primary := []*filesystem.FileSystemEntry{entry("FIRST.TXT;1", 30), entry("SECOND_L.TXT;1", 30), entry("OTHER.TXT;1", 31)}
+	supplementary := []*filesystem.FileSystemEntry{entry("second link.txt", 30), entry("first.txt", 30), entry("other.txt", 31)}
+	merged, report := mergeHierarchies(primary, supplementary, false)
+	require.Empty(t, report.PrimaryOnly)
+	require.Empty(t, report.SupplementaryOnly)
+	var paths []string
+	for _, e := range merged {
+		paths = append(paths, e.FullPath)
+	}
+	require.Equal(t, []string{"/first.txt", "/second link.txt", "/other.txt"}, paths)
+}
@@ -0,0 +1,1099 @@
+package iso9660
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/boot"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/descriptor"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/directory"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/encoding"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extensions"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extent"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/pathtable"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// Maximum length of a Joliet file identifier in UCS-2 characters
+	JOLIET_MAX_NAME_LENGTH = 64
+	// Default load segment used by El Torito when none is given
+	EL_TORITO_DEFAULT_LOAD_SEGMENT = 0x07C0
+	// Hybrid images are padded to a multiple of this many sectors (1 MiB) so the partition ends on a cylinder boundary
+	HYBRID_ALIGNMENT_SECTORS = 512
+	// Default MBR partition type for the partition covering a hybrid image
+	HYBRID_DEFAULT_PARTITION_TYPE = 0x17
+)
+
+// hierarchy identifies one of the directory hierarchies recorded in a created image.
+type hierarchy int
+
+const (
+	// The primary hierarchy, which owns the file data and carries the Rock Ridge entries
+	hierarchyPrimary hierarchy = iota
+	// The Joliet hierarchy described by the Joliet supplementary volume descriptor
+	hierarchyJoliet
+	// The ISO 9660:1999 hierarchy described by the enhanced volume descriptor
+	hierarchyEnhanced
+)
+
+// plannedRecord is a directory record that has been sized but not yet assigned its final locations.
+type plannedRecord struct {
+	record       *directory.DirectoryRecord
+	target       *node
+	continuation *extensions.ContinuationArea
+	ceOffset     int
+}
+
+// plannedDirectory is the list of records making up a single directory extent.
+type plannedDirectory struct {
+	dir     *node
+	parent  *plannedDirectory
+	number  uint16
+	records []*plannedRecord
+	size    uint32
+}
+
+// pack lays out a newly created image. It assigns names and logical blocks to every node of the staging tree and
+// builds the volume descriptors, path tables, directory records and boot catalog that Save writes out.
+func (iso *ISO9660) pack() error {
+	opts := iso.createOptions
+	iso.logger.Debug("Packing image", "volume", iso.volumeDescriptorSet.Primary.VolumeIdentifier())
+
+	if err := iso.convertIdentifiers(); err != nil {
+		return err
+	}
+
+	// Apply per-path overrides
+	iso.root.walk(func(n *node) {
+		for _, override := range iso.overrides {
+			if matched, _ := path.Match(override.pattern, n.path()); matched {
+				n.apply(override.opts...)
+			}
+		}
+	})
+
+	// Resolve the boot images and add the boot catalog to the tree
+	bootNodes, catalog, err := iso.prepareBoot()
+	if err != nil {
+		return err
+	}
+
+	// Assign identifiers and build the directory hierarchies
+	primaryDirs, err := iso.planDirectories(hierarchyPrimary)
+	if err != nil {
+		return err
+	}
+	var jolietDirs, enhancedDirs []*plannedDirectory
+	if opts.JolietEnabled {
+		if jolietDirs, err = iso.planDirectories(hierarchyJoliet); err != nil {
+			return err
+		}
+	}
+	if opts.EnhancedEnabled {
+		if enhancedDirs, err = iso.planDirectories(hierarchyEnhanced); err != nil {
+			return err
+		}
+	}
+
+	// Volume descriptor set
+	lba := uint32(consts.ISO9660_SYSTEM_AREA_SECTORS)
+	pvdLBA := lba
+	lba++
+	var bootRecordLBA, svdLBA, evdLBA uint32
+	if len(bootNodes) > 0 {
+		bootRecordLBA = lba
+		lba++
+	}
+	if opts.JolietEnabled {
+		svdLBA = lba
+		lba++
+	}
+	if opts.EnhancedEnabled {
+		evdLBA = lba
+		lba++
+	}
+	terminatorLBA := lba
+	lba++
+
+	// Boot catalog
+	var catalogLBA uint32
+	if len(bootNodes) > 0 {
+		catalogLBA = lba
+		lba++
+	}
+
+	// Path tables, sized now and filled in once the directories have been placed
+	primaryTableLBA := lba
+	primaryTableL, primaryTableM := newPathTables(primaryDirs, hierarchyPrimary, lba, "Primary")
+	lba += sectors(int64(primaryTableL.ObjectSize)) + sectors(int64(primaryTableM.ObjectSize))
+	var jolietTableLBA, enhancedTableLBA uint32
+	var jolietTableL, jolietTableM, enhancedTableL, enhancedTableM *pathtable.PathTable
+	if opts.JolietEnabled {
+		jolietTableLBA = lba
+		jolietTableL, jolietTableM = newPathTables(jolietDirs, hierarchyJoliet, lba, "Supplementary")
+		lba += sectors(int64(jolietTableL.ObjectSize)) + sectors(int64(jolietTableM.ObjectSize))
+	}
+	if opts.EnhancedEnabled {
+		enhancedTableLBA = lba
+		enhancedTableL, enhancedTableM = newPathTables(enhancedDirs, hierarchyEnhanced, lba, "Enhanced")
+		lba += sectors(int64(enhancedTableL.ObjectSize)) + sectors(int64(enhancedTableM.ObjectSize))
+	}
+
+	// Directory extents. Continuation areas are recorded in the blocks directly following the directory that
+	// references them, which is where readers such as libarchive expect them.
+	var areas []*extensions.ContinuationArea
+	for _, pd := range primaryDirs {
+		pd.dir.location = lba
+		pd.dir.dirSize = pd.size
+		lba += sectors(int64(pd.size))
+
+		var blockOffset uint32
+		for _, pr := range pd.records {
+			if pr.continuation == nil {
+				continue
+			}
+			size := uint32(len(pr.continuation.Data))
+			if blockOffset+size > consts.ISO9660_SECTOR_SIZE {
+				lba++
+				blockOffset = 0
+			}
+			pr.continuation.Block = lba
+			pr.continuation.BlockOffset = blockOffset
+			blockOffset += size
+			areas = append(areas, pr.continuation)
+		}
+		if blockOffset > 0 {
+			lba++
+		}
+	}
+	for _, jd := range jolietDirs {
+		jd.dir.jolietLocation = lba
+		jd.dir.jolietDirSize = jd.size
+		lba += sectors(int64(jd.size))
+	}
+	for _, ed := range enhancedDirs {
+		ed.dir.enhancedLocation = lba
+		ed.dir.enhancedDirSize = ed.size
+		lba += sectors(int64(ed.size))
+	}
+
+	// Record the directory locations in the path tables
+	primaryTableL, primaryTableM = newPathTables(primaryDirs, hierarchyPrimary, primaryTableLBA, "Primary")
+	tables := []*pathtable.PathTable{primaryTableL, primaryTableM}
+	if opts.JolietEnabled {
+		jolietTableL, jolietTableM = newPathTables(jolietDirs, hierarchyJoliet, jolietTableLBA, "Supplementary")
+		tables = append(tables, jolietTableL, jolietTableM)
+	}
+	if opts.EnhancedEnabled {
+		enhancedTableL, enhancedTableM = newPathTables(enhancedDirs, hierarchyEnhanced, enhancedTableLBA, "Enhanced")
+		tables = append(tables, enhancedTableL, enhancedTableM)
+	}
+
+	// File data
+	if catalog != nil {
+		catalog.location = catalogLBA
+	}
+	iso.root.walk(func(n *node) {
+		if n.isDir || n.isCatalog || n.symlink != "" || n.size == 0 {
+			return
+		}
+		n.location = lba
+		lba += sectors(n.size)
+	})
+
+	// Hybrid images are padded so the partition ends on a 1 MiB boundary
+	if opts.Hybrid != nil && lba%HYBRID_ALIGNMENT_SECTORS != 0 {
+		lba += HYBRID_ALIGNMENT_SECTORS - lba%HYBRID_ALIGNMENT_SECTORS
+	}
+	volumeSize := lba
+
+	// Patch boot info tables now that the locations are known
+	for i, entry := range opts.BootEntries {
+		if entry.BootInfoTable {
+			if err := patchBootInfoTable(bootNodes[i], pvdLBA); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Fill in the record locations
+	primaryRecordList := finalizeDirectories(primaryDirs, hierarchyPrimary)
+	var jolietRecordList, enhancedRecordList []*directory.DirectoryRecord
+	if opts.JolietEnabled {
+		jolietRecordList = finalizeDirectories(jolietDirs, hierarchyJoliet)
+	}
+	if opts.EnhancedEnabled {
+		enhancedRecordList = finalizeDirectories(enhancedDirs, hierarchyEnhanced)
+	}
+
+	// Primary volume descriptor
+	pvd := iso.volumeDescriptorSet.Primary
+	pvd.ObjectLocation = int64(pvdLBA) * consts.ISO9660_SECTOR_SIZE
+	pvd.ObjectSize = consts.ISO9660_SECTOR_SIZE
+	pvd.VolumeSpaceSize = volumeSize
+	pvd.PrimaryVolumeDescriptorBody.PathTableSize = primaryTableL.ObjectSize
+	pvd.LocationOfTypeLPathTable = uint32(primaryTableL.ObjectLocation)
+	pvd.LocationOfTypeMPathTable = uint32(primaryTableM.ObjectLocation)
+	pvd.RootDirectoryRecord = rootRecord(iso.root, hierarchyPrimary)
+	pvd.DirectoryRecords = primaryRecordList
+
+	// Supplementary (Joliet) volume descriptor
+	iso.volumeDescriptorSet.Supplementary = nil
+	if opts.JolietEnabled {
+		svd := iso.newJolietDescriptor()
+		svd.ObjectLocation = int64(svdLBA) * consts.ISO9660_SECTOR_SIZE
+		svd.ObjectSize = consts.ISO9660_SECTOR_SIZE
+		svd.VolumeSpaceSize = encoding.MarshalBothByteOrders32(volumeSize)
+		svd.SupplementaryVolumeDescriptorBody.PathTableSize = jolietTableL.ObjectSize
+		svd.LocationOfTypeLPathTable = uint32(jolietTableL.ObjectLocation)
+		svd.LocationOfTypeMPathTable = uint32(jolietTableM.ObjectLocation)
+		svd.RootDirectoryRecord = rootRecord(iso.root, hierarchyJoliet)
+		svd.DirectoryRecords = jolietRecordList
+		iso.volumeDescriptorSet.Supplementary = append(iso.volumeDescriptorSet.Supplementary, svd)
+	}
+
+	// Enhanced volume descriptor
+	if opts.EnhancedEnabled {
+		evd := iso.newEnhancedDescriptor()
+		evd.ObjectLocation = int64(evdLBA) * consts.ISO9660_SECTOR_SIZE
+		evd.ObjectSize = consts.ISO9660_SECTOR_SIZE
+		evd.VolumeSpaceSize = encoding.MarshalBothByteOrders32(volumeSize)
+		evd.SupplementaryVolumeDescriptorBody.PathTableSize = enhancedTableL.ObjectSize
+		evd.LocationOfTypeLPathTable = uint32(enhancedTableL.ObjectLocation)
+		evd.LocationOfTypeMPathTable = uint32(enhancedTableM.ObjectLocation)
+		evd.RootDirectoryRecord = rootRecord(iso.root, hierarchyEnhanced)
+		evd.DirectoryRecords = enhancedRecordList
+		iso.volumeDescriptorSet.Supplementary = append(iso.volumeDescriptorSet.Supplementary, evd)
+	}
+
+	// Boot record and catalog
+	iso.volumeDescriptorSet.Boot = nil
+	iso.elTorito = nil
+	if len(bootNodes) > 0 {
+		iso.volumeDescriptorSet.Boot = newBootRecord(bootRecordLBA, catalogLBA)
+		iso.elTorito = iso.newElTorito(bootNodes, catalogLBA)
+	}
+
+	// Terminator
+	terminator := descriptor.NewVolumeDescriptorSetTerminator()
+	terminator.ObjectLocation = int64(terminatorLBA) * consts.ISO9660_SECTOR_SIZE
+	terminator.ObjectSize = consts.ISO9660_SECTOR_SIZE
+	iso.volumeDescriptorSet.Terminator = terminator
+
+	// System area
+	iso.systemArea.Contents = [consts.ISO9660_SECTOR_SIZE * consts.ISO9660_SYSTEM_AREA_SECTORS]byte{}
+	iso.systemArea.ObjectSize = consts.ISO9660_SECTOR_SIZE * consts.ISO9660_SYSTEM_AREA_SECTORS
+	if opts.Hybrid != nil {
+		if err := iso.writeHybridMBR(bootNodes, volumeSize); err != nil {
+			return err
+		}
+	}
+
+	iso.pathTables = tables
+	iso.continuationAreas = areas
+	iso.isPacked = true
+	iso.logger.Debug("Packed image", "sectors", volumeSize)
+
+	return nil
+}
+
+// convertIdentifiers records the identifiers given to Create in the primary volume descriptor using the characters
+// that descriptor allows: upper case, with characters outside the a-character (or d-character) set replaced by '_'.
+// The Joliet descriptor records the identifiers as they were given.
+func (iso *ISO9660) convertIdentifiers() error {
+	opts := iso.createOptions
+	body := &iso.volumeDescriptorSet.Primary.PrimaryVolumeDescriptorBody
+	for _, field := range []struct {
+		name    string
+		value   string
+		size    int
+		convert func(string) string
+		primary *string
+	}{
+		{"system identifier", opts.SystemID, 32, aCharacters, &body.SystemIdentifier},
+		{"volume identifier", opts.VolumeID, 32, dCharacters, &body.VolumeIdentifier},
+		{"volume set identifier", opts.VolumeSetID, 128, dCharacters, &body.VolumeSetIdentifier},
+		{"publisher identifier", opts.PublisherID, 128, aCharacters, &body.PublisherIdentifier},
+		{"data preparer identifier", opts.Preparer, 128, aCharacters, &body.DataPreparerIdentifier},
+		{"application identifier", opts.ApplicationID, 128, aCharacters, &body.ApplicationIdentifier},
+		{"copyright file identifier", opts.CopyrightFileID, 37, fileIdentifierCharacters, &body.CopyrightFileIdentifier},
+		{"abstract file identifier", opts.AbstractFileID, 37, fileIdentifierCharacters, &body.AbstractFileIdentifier},
+		{"bibliographic file identifier", opts.BibliographicID, 37, fileIdentifierCharacters, &body.BibliographicFileIdentifier},
+	} {
+		converted := field.convert(field.value)
+		if len(converted) > field.size {
+			return fmt.Errorf("%s %q exceeds %d characters", field.name, field.value, field.size)
+		}
+		*field.primary = converted
+	}
+	return nil
+}
+
+// aCharacters converts s to the a-characters allowed in a primary volume descriptor identifier.
+func aCharacters(s string) string {
+	return primaryCharacters(s, false)
+}
+
+// dCharacters converts s to the d-characters allowed in a primary volume descriptor identifier.
+func dCharacters(s string) string {
+	return primaryCharacters(s, true)
+}
+
+// fileIdentifierCharacters converts s to the d-characters and separators allowed in a file identifier.
+func fileIdentifierCharacters(s string) string {
+	return strings.Map(func(r rune) rune {
+		if string(r) == consts.ISO9660_SEPARATOR_1 || string(r) == consts.ISO9660_SEPARATOR_2 {
+			return r
+		}
+		return []rune(primaryCharacters(string(r), true))[0]
+	}, s)
+}
+
+// prepareBoot resolves the nodes of the boot images and, unless it is hidden, adds the boot catalog to the tree.
+func (iso *ISO9660) prepareBoot() ([]*node, *node, error) {
+	opts := iso.createOptions
+
+	// Drop the catalog from a previous pack
+	iso.root.walk(func(n *node) {
+		if n.isCatalog {
+			n.remove()
+		}
+	})
+
+	if len(opts.BootEntries) == 0 {
+		return nil, nil, nil
+	}
+
+	var bootNodes []*node
+	for _, entry := range opts.BootEntries {
+		n := iso.lookup(entry.ImagePath)
+		if n == nil || n.isDir || n.symlink != "" {
+			return nil, nil, fmt.Errorf("boot image %s does not exist in the image", entry.ImagePath)
+		}
+		if n.size == 0 {
+			return nil, nil, fmt.Errorf("boot image %s is empty", entry.ImagePath)
+		}
+		bootNodes = append(bootNodes, n)
+	}
+
+	catalogPath := opts.BootCatalog
+	if catalogPath == "" {
+		catalogPath = boot.EL_TORITO_DEFAULT_CATALOG
+		if opts.RockRidgeEnabled {
+			catalogPath = boot.EL_TORITO_DEFAULT_CATALOG_RR
+		}
+	}
+	catalog := iso.newNode("", false)
+	catalog.isCatalog = true
+	catalog.size = consts.ISO9660_SECTOR_SIZE
+	catalog.mode = 0o444
+	if opts.HideBootCatalog {
+		return bootNodes, catalog, nil
+	}
+	if iso.lookup(catalogPath) != nil {
+		return nil, nil, fmt.Errorf("boot catalog path %s is already in use", catalogPath)
+	}
+	if _, err := iso.insert(catalogPath, catalog); err != nil {
+		return nil, nil, err
+	}
+
+	return bootNodes, catalog, nil
+}
+
+// planDirectories assigns identifiers to every node and builds the sized directory records for the primary, Joliet or
+// enhanced hierarchy. Directories are returned in path table order.
+func (iso *ISO9660) planDirectories(h hierarchy) ([]*plannedDirectory, error) {
+	rockRidge := iso.createOptions.RockRidgeEnabled && h == hierarchyPrimary
+
+	// Breadth first walk so the order matches the path table
+	root := &plannedDirectory{dir: iso.root, number: 1}
+	root.parent = root
+	dirs := []*plannedDirectory{root}
+	for i := 0; i < len(dirs); i++ {
+		pd := dirs[i]
+		children := iso.recordedChildren(pd.dir, h)
+		switch h {
+		case hierarchyJoliet:
+			assignJolietNames(children)
+		case hierarchyEnhanced:
+			assignEnhancedNames(children)
+		default:
+			assignISONames(children, iso.createOptions.InterchangeLevel)
+		}
+		sortChildren(children, h)
+
+		// "." and ".." records
+		self := &plannedRecord{record: newRecord("\x00", pd.dir, h), target: pd.dir}
+		parent := &plannedRecord{record: newRecord("\x01", pd.parent.dir, h), target: pd.parent.dir}
+		pd.records = append(pd.records, self, parent)
+
+		for _, child := range children {
+			pd.records = append(pd.records, &plannedRecord{record: newRecord(child.identifier(h), child, h), target: child})
+			if child.isDir {
+				dirs = append(dirs, &plannedDirectory{dir: child, parent: pd, number: uint16(len(dirs) + 1)})
+			}
+		}
+		if len(dirs) > 0xFFFF {
+			return nil, fmt.Errorf("image has more than %d directories", 0xFFFF)
+		}
+
+		// System Use entries
+		if rockRidge {
+			for idx, pr := range pd.records {
+				if err := iso.planSystemUse(pr, pd, idx); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// Size the directory extent, records may not cross a sector boundary
+		var offset uint32
+		for _, pr := range pd.records {
+			data, err := pr.record.Marshal()
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal directory record for %s: %w", pr.target.path(), err)
+			}
+			length := uint32(len(data))
+			if offset%consts.ISO9660_SECTOR_SIZE+length > consts.ISO9660_SECTOR_SIZE {
+				offset += consts.ISO9660_SECTOR_SIZE - offset%consts.ISO9660_SECTOR_SIZE
+			}
+			pr.record.ObjectLocation = int64(offset)
+			pr.record.ObjectSize = length
+			offset += length
+		}
+		pd.size = sectors(int64(offset)) * consts.ISO9660_SECTOR_SIZE
+	}
+
+	return dirs, nil
+}
+
+// recordedChildren returns the children of a directory that are recorded in the given hierarchy. Symbolic links only
+// exist in the primary hierarchy, as Rock Ridge entries.
+func (iso *ISO9660) recordedChildren(dir *node, h hierarchy) []*node {
+	var children []*node
+	for _, c := range dir.children {
+		if c.symlink != "" && (h != hierarchyPrimary || !iso.createOptions.RockRidgeEnabled) {
+			if h == hierarchyPrimary {
+				iso.logger.Info("Skipping symbolic link, Rock Ridge is not enabled", "path", c.path())
+			}
+			continue
+		}
+		children = append(children, c)
+	}
+	return children
+}
+
+// planSystemUse builds the Rock Ridge entries of a record and moves any that do not fit to a continuation area.
+func (iso *ISO9660) planSystemUse(pr *plannedRecord, pd *plannedDirectory, index int) error {
+	n := pr.target
+	rr := &extensions.RockRidgeExtensions{}
+
+	mode := n.mode
+	links := uint32(1)
+	switch {
+	case n.isDir:
+		mode |= fs.ModeDir
+		links = 2
+		for _, c := range n.children {
+			if c.isDir {
+				links++
+			}
+		}
+	case n.symlink != "":
+		mode |= fs.ModeSymlink
+		target := n.symlink
+		rr.SymlinkTarget = &target
+	}
+	uid, gid, modTime := n.uid, n.gid, n.modTime
+	rr.Permissions = &mode
+	rr.LinkCount = &links
+	rr.UID = &uid
+	rr.GID = &gid
+	if !modTime.IsZero() {
+		rr.ModificationTime = &modTime
+		rr.AccessTime = &modTime
+	}
+	if index > 1 {
+		name := n.name
+		rr.AlternateName = &name
+	}
+
+	rrEntries, err := extensions.MarshalRockRidgeEntries(rr)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Rock Ridge entries for %s: %w", n.path(), err)
+	}
+
+	var entries [][]byte
+	isRootSelf := index == 0 && pd.dir == iso.root
+	if isRootSelf {
+		entries = append(entries, extensions.MarshalSharingProtocolEntry())
+	}
+	entries = append(entries, rrEntries...)
+	if isRootSelf {
+		entries = append(entries, extensions.MarshalExtensionsReferenceEntry())
+	}
+
+	// Room left in the record for System Use data (the record length must stay even)
+	base, err := pr.record.Marshal()
+	if err != nil {
+		return err
+	}
+	available := 254 - len(base)
+
+	total := 0
+	for _, e := range entries {
+		total += len(e)
+	}
+
+	var inline, continued []byte
+	if total <= available {
+		for _, e := range entries {
+			inline = append(inline, e...)
+		}
+	} else {
+		split := false
+		for _, e := range entries {
+			if !split && len(inline)+len(e)+extensions.CONTINUATION_ENTRY_LENGTH <= available {
+				inline = append(inline, e...)
+				continue
+			}
+			split = true
+			continued = append(continued, e...)
+		}
+		if len(continued) > consts.ISO9660_SECTOR_SIZE {
+			return fmt.Errorf("system use entries for %s exceed a single continuation area", n.path())
+		}
+		pr.ceOffset = len(inline)
+		inline = append(inline, extensions.MarshalContinuationEntry(0, 0, uint32(len(continued)))...)
+		pr.continuation = &extensions.ContinuationArea{Data: continued, Owner: n.path()}
+	}
+	if (len(base)+len(inline))%2 != 0 {
+		inline = append(inline, 0x00)
+	}
+	pr.record.SystemUse = inline
+
+	return nil
+}
+
+// finalizeDirectories fills in the extent locations of every planned record and returns the records with their
+// absolute object locations set.
+func finalizeDirectories(dirs []*plannedDirectory, h hierarchy) []*directory.DirectoryRecord {
+	var records []*directory.DirectoryRecord
+	for _, pd := range dirs {
+		dirLocation, _ := pd.dir.dirExtent(h)
+		for _, pr := range pd.records {
+			dr := pr.record
+			n := pr.target
+			dr.ObjectLocation += int64(dirLocation) * consts.ISO9660_SECTOR_SIZE
+
+			switch {
+			case n.isDir:
+				dr.LocationOfExtent, dr.DataLength = n.dirExtent(h)
+			case n.symlink != "":
+				dr.LocationOfExtent = 0
+				dr.DataLength = 0
+			default:
+				dr.LocationOfExtent = n.location
+				dr.DataLength = uint32(n.size)
+				// The primary hierarchy owns the file data, the Joliet and enhanced records share the same extents
+				if h == hierarchyPrimary && !n.isCatalog && n.size > 0 {
+					dr.FileExtent = &extent.FileExtent{
+						FileIdentifier: n.path(),
+						LocationOfFile: n.location,
+						SizeOfFile:     uint32(n.size),
+						Source:         n.source,
+					}
+				}
+			}
+
+			if pr.continuation != nil {
+				ce := extensions.MarshalContinuationEntry(pr.continuation.Block, pr.continuation.BlockOffset,
+					uint32(len(pr.continuation.Data)))
+				copy(dr.SystemUse[pr.ceOffset:], ce)
+			}
+
+			records = append(records, dr)
+		}
+	}
+	return records
+}
+
+// newPathTables builds the type L path table of a hierarchy at lba, followed by the type M path table.
+func newPathTables(dirs []*plannedDirectory, h hierarchy, lba uint32, source string) (*pathtable.PathTable, *pathtable.PathTable) {
+	records := pathTableRecords(dirs, h)
+	tableL := pathtable.NewPathTableFromRecords(records, lba, source, true)
+	tableM := pathtable.NewPathTableFromRecords(records, lba+sectors(int64(tableL.ObjectSize)), source, false)
+	return tableL, tableM
+}
+
+// pathTableRecords builds the path table records for the planned directories.
+func pathTableRecords(dirs []*plannedDirectory, h hierarchy) []*pathtable.PathTableRecord {
+	var records []*pathtable.PathTableRecord
+	for _, pd := range dirs {
+		identifier := "\x00"
+		location, _ := pd.dir.dirExtent(h)
+		if pd.dir != pd.parent.dir {
+			identifier = pd.dir.identifier(h)
+			if h == hierarchyJoliet {
+				identifier = string(encoding.EncodeUCS2BigEndian(identifier))
+			}
+		}
+		records = append(records, &pathtable.PathTableRecord{
+			LocationOfExtent:      location,
+			ParentDirectoryNumber: pd.parent.number,
+			DirectoryIdentifier:   identifier,
+		})
+	}
+	return records
+}
+
+// newRecord creates a directory record for the node without any location information.
+func newRecord(identifier string, n *node, h hierarchy) *directory.DirectoryRecord {
+	return &directory.DirectoryRecord{
+		RecordingDateAndTime: n.modTime,
+		FileFlags: directory.FileFlags{
+			Hidden:    n.hidden && identifier != "\x00" && identifier != "\x01",
+			Directory: n.isDir,
+		},
+		VolumeSequenceNumber: 1,
+		FileIdentifier:       identifier,
+		Joliet:               h == hierarchyJoliet,
+	}
+}
+
+// rootRecord creates the 34-byte root directory record stored in a volume descriptor.
+func rootRecord(root *node, h hierarchy) *directory.DirectoryRecord {
+	dr := newRecord("\x00", root, h)
+	dr.LocationOfExtent, dr.DataLength = root.dirExtent(h)
+	return dr
+}
+
+// identifier returns the name recorded for the node in the given hierarchy.
+func (n *node) identifier(h hierarchy) string {
+	switch h {
+	case hierarchyJoliet:
+		return n.jolietName
+	case hierarchyEnhanced:
+		return n.enhancedName
+	}
+	return n.isoName
+}
+
+// dirExtent returns the location and size of the directory extent recorded for the node in the given hierarchy.
+func (n *node) dirExtent(h hierarchy) (uint32, uint32) {
+	switch h {
+	case hierarchyJoliet:
+		return n.jolietLocation, n.jolietDirSize
+	case hierarchyEnhanced:
+		return n.enhancedLocation, n.enhancedDirSize
+	}
+	return n.location, n.dirSize
+}
+
+// sortChildren orders the children by their recorded identifier.
+func sortChildren(children []*node, h hierarchy) {
+	slices.SortFunc(children, func(a, b *node) int {
+		if h == hierarchyJoliet {
+			return slices.Compare(utf16.Encode([]rune(a.jolietName)), utf16.Encode([]rune(b.jolietName)))
+		}
+		return strings.Compare(a.identifier(h), b.identifier(h))
+	})
+}
+
+// assignISONames assigns unique ISO9660 identifiers to the children of a directory for the interchange level.
+func assignISONames(children []*node, level int) {
+	maxName := 30
+	if level <= 1 {
+		maxName = 8
+	}
+	used := make(map[string]bool)
+
+	for _, n := range children {
+		if n.isDir {
+			base := isoCharacters(strings.ReplaceAll(n.name, ".", "_"))
+			dirMax := maxName
+			if level > 1 {
+				dirMax = 31
+			}
+			n.isoName = uniqueName(base, "", dirMax, used)
+			continue
+		}
+
+		base, ext := n.name, ""
+		if i := strings.LastIndex(n.name, "."); i >= 0 {
+			base, ext = n.name[:i], n.name[i+1:]
+		}
+		base = isoCharacters(strings.ReplaceAll(base, ".", "_"))
+		ext = isoCharacters(ext)
+
+		baseMax := maxName
+		if level <= 1 {
+			if len(ext) > 3 {
+				ext = ext[:3]
+			}
+		} else {
+			// Levels 2 and 3 limit the name and extension together to 30 characters
+			if len(ext) > maxName-2 {
+				ext = ext[:maxName-2]
+			}
+			baseMax = maxName - len(ext) - 1
+		}
+		n.isoName = uniqueName(base, "."+ext, baseMax, used) + ";1"
+	}
+}
+
+// assignJolietNames assigns unique Joliet identifiers to the children of a directory.
+func assignJolietNames(children []*node) {
+	used := make(map[string]bool)
+	for _, n := range children {
+		name := strings.Map(func(r rune) rune {
+			switch r {
+			case '*', '/', ':', ';', '?', '\\':
+				return '_'
+			}
+			return r
+		}, n.name)
+
+		base, ext := name, ""
+		if i := strings.LastIndex(name, "."); i > 0 && !n.isDir {
+			base, ext = name[:i], name[i:]
+		}
+		extLen := len(utf16.Encode([]rune(ext)))
+		if extLen > JOLIET_MAX_NAME_LENGTH/2 {
+			ext, extLen = "", 0
+			base = name
+		}
+
+		// Names are limited by UCS-2 units, truncate by runes to avoid splitting characters
+		limit := JOLIET_MAX_NAME_LENGTH - extLen
+		runes := []rune(base)
+		for len(utf16.Encode(runes)) > limit {
+			runes = runes[:len(runes)-1]
+		}
+		candidate := string(runes) + ext
+		for i := 1; used[candidate]; i++ {
+			suffix := "~" + strconv.Itoa(i)
+			trimmed := []rune(base)
+			for len(utf16.Encode(trimmed))+len(suffix) > limit {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+			candidate = string(trimmed) + suffix + ext
+		}
+		used[candidate] = true
+		n.jolietName = candidate
+	}
+}
+
+// assignEnhancedNames assigns unique ISO 9660:1999 identifiers to the children of a directory. The names are recorded
+// as given, without a version number, and limited to 207 bytes.
+func assignEnhancedNames(children []*node) {
+	used := make(map[string]bool)
+	for _, n := range children {
+		name := strings.Map(func(r rune) rune {
+			if r == 0 || r == '/' {
+				return '_'
+			}
+			return r
+		}, n.name)
+
+		base, ext := name, ""
+		if i := strings.LastIndex(name, "."); i > 0 && !n.isDir && len(name)-i <= consts.ISO9660_ENHANCED_MAX_IDENTIFIER_LENGTH/2 {
+			base, ext = name[:i], name[i:]
+		}
+
+		// Truncate by runes to avoid splitting multi-byte characters
+		limit := consts.ISO9660_ENHANCED_MAX_IDENTIFIER_LENGTH - len(ext)
+		candidate := truncateBytes(base, limit) + ext
+		for i := 1; used[candidate]; i++ {
+			suffix := "~" + strconv.Itoa(i)
+			candidate = truncateBytes(base, limit-len(suffix)) + suffix + ext
+		}
+		used[candidate] = true
+		n.enhancedName = candidate
+	}
+}
+
+// truncateBytes shortens s to at most max bytes without splitting a UTF-8 encoded character.
+func truncateBytes(s string, max int) string {
+	runes := []rune(s)
+	for len(string(runes)) > max {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
+
+// isoCharacters upper cases the name and replaces anything that is not a d-character.
+func isoCharacters(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// uniqueName truncates base to max characters and appends suffix, replacing the end of the base with a number until
+// the name does not collide with one already in use.
+func uniqueName(base, suffix string, max int, used map[string]bool) string {
+	if base == "" {
+		base = "_"
+	}
+	if len(base) > max {
+		base = base[:max]
+	}
+	candidate := base + suffix
+	for i := 1; used[candidate]; i++ {
+		number := strconv.Itoa(i)
+		trimmed := base
+		if len(trimmed)+len(number) > max {
+			trimmed = trimmed[:max-len(number)]
+		}
+		candidate = trimmed + number + suffix
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// newJolietDescriptor creates the Joliet supplementary volume descriptor from the identifiers given to Create.
+func (iso *ISO9660) newJolietDescriptor() *descriptor.SupplementaryVolumeDescriptor {
+	opts := iso.createOptions
+	pvd := iso.volumeDescriptorSet.Primary
+	svd := &descriptor.SupplementaryVolumeDescriptor{
+		VolumeDescriptorHeader: descriptor.VolumeDescriptorHeader{
+			VolumeDescriptorType:    descriptor.TYPE_SUPPLEMENTARY_DESCRIPTOR,
+			StandardIdentifier:      consts.ISO9660_STD_IDENTIFIER,
+			VolumeDescriptorVersion: consts.ISO9660_VOLUME_DESC_VERSION,
+		},
+		SupplementaryVolumeDescriptorBody: descriptor.SupplementaryVolumeDescriptorBody{
+			SystemIdentifier:              truncateUCS2(opts.SystemID, 16),
+			VolumeIdentifier:              truncateUCS2(opts.VolumeID, 16),
+			VolumeSetSize:                 encoding.MarshalBothByteOrders16(1),
+			VolumeSequenceNumber:          encoding.MarshalBothByteOrders16(1),
+			LogicalBlockSize:              encoding.MarshalBothByteOrders16(consts.ISO9660_SECTOR_SIZE),
+			VolumeSetIdentifier:           truncateUCS2(opts.VolumeSetID, 64),
+			PublisherIdentifier:           truncateUCS2(opts.PublisherID, 64),
+			DataPreparerIdentifier:        truncateUCS2(opts.Preparer, 64),
+			ApplicationIdentifier:         truncateUCS2(opts.ApplicationID, 64),
+			CopyrightFileIdentifier:       truncateUCS2(opts.CopyrightFileID, 18),
+			AbstractFileIdentifier:        truncateUCS2(opts.AbstractFileID, 18),
+			BibliographicFileIdentifier:   truncateUCS2(opts.BibliographicID, 18),
+			VolumeCreationDateAndTime:     pvd.VolumeCreationDateTime(),
+			VolumeModificationDateAndTime: pvd.VolumeModificationDateTime(),
+			VolumeExpirationDateAndTime:   pvd.VolumeExpirationDateTime(),
+			VolumeEffectiveDateAndTime:    pvd.VolumeEffectiveDateTime(),
+			FileStructureVersion:          1,
+			Logger:                        iso.logger,
+		},
+	}
+	copy(svd.EscapeSequences[:], consts.JOLIET_LEVEL_3_ESCAPE)
+	return svd
+}
+
+// newEnhancedDescriptor creates the ISO 9660:1999 enhanced volume descriptor from the primary descriptor identifiers.
+// It is a version 2 supplementary descriptor without escape sequences.
+func (iso *ISO9660) newEnhancedDescriptor() *descriptor.SupplementaryVolumeDescriptor {
+	pvd := iso.volumeDescriptorSet.Primary
+	return &descriptor.SupplementaryVolumeDescriptor{
+		VolumeDescriptorHeader: descriptor.VolumeDescriptorHeader{
+			VolumeDescriptorType:    descriptor.TYPE_SUPPLEMENTARY_DESCRIPTOR,
+			StandardIdentifier:      consts.ISO9660_STD_IDENTIFIER,
+			VolumeDescriptorVersion: consts.ISO9660_ENHANCED_VOLUME_DESC_VERSION,
+		},
+		SupplementaryVolumeDescriptorBody: descriptor.SupplementaryVolumeDescriptorBody{
+			SystemIdentifier:              pvd.SystemIdentifier(),
+			VolumeIdentifier:              pvd.VolumeIdentifier(),
+			VolumeSetSize:                 encoding.MarshalBothByteOrders16(1),
+			VolumeSequenceNumber:          encoding.MarshalBothByteOrders16(1),
+			LogicalBlockSize:              encoding.MarshalBothByteOrders16(consts.ISO9660_SECTOR_SIZE),
+			VolumeSetIdentifier:           pvd.VolumeSetIdentifier(),
+			PublisherIdentifier:           pvd.PublisherIdentifier(),
+			DataPreparerIdentifier:        pvd.DataPreparerIdentifier(),
+			ApplicationIdentifier:         pvd.ApplicationIdentifier(),
+			CopyrightFileIdentifier:       pvd.CopyrightFileIdentifier(),
+			AbstractFileIdentifier:        pvd.AbstractFileIdentifier(),
+			BibliographicFileIdentifier:   pvd.BibliographicFileIdentifier(),
+			VolumeCreationDateAndTime:     pvd.VolumeCreationDateTime(),
+			VolumeModificationDateAndTime: pvd.VolumeModificationDateTime(),
+			VolumeExpirationDateAndTime:   pvd.VolumeExpirationDateTime(),
+			VolumeEffectiveDateAndTime:    pvd.VolumeEffectiveDateTime(),
+			FileStructureVersion:          consts.ISO9660_ENHANCED_VOLUME_DESC_VERSION,
+			Logger:                        iso.logger,
+		},
+	}
+}
+
+// truncateUCS2 shortens s so it fits in the given number of UCS-2 characters.
+func truncateUCS2(s string, max int) string {
+	runes := []rune(s)
+	for len(utf16.Encode(runes)) > max {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes)
+}
+
+// newBootRecord creates the El Torito boot record volume descriptor pointing at the boot catalog.
+func newBootRecord(lba, catalogLBA uint32) *descriptor.BootRecordDescriptor {
+	br := &descriptor.BootRecordDescriptor{
+		VolumeDescriptorHeader: descriptor.VolumeDescriptorHeader{
+			VolumeDescriptorType:    descriptor.TYPE_BOOT_RECORD,
+			StandardIdentifier:      consts.ISO9660_STD_IDENTIFIER,
+			VolumeDescriptorVersion: consts.ISO9660_VOLUME_DESC_VERSION,
+		},
+		BootRecordBody: descriptor.BootRecordBody{
+			// El Torito requires the identifiers to be padded with zeros rather than spaces
+			BootSystemIdentifier: consts.EL_TORITO_BOOT_SYSTEM_ID + strings.Repeat("\x00", 32-len(consts.EL_TORITO_BOOT_SYSTEM_ID)),
+			BootIdentifier:       strings.Repeat("\x00", 32),
+			ObjectLocation:       int64(lba) * consts.ISO9660_SECTOR_SIZE,
+			ObjectSize:           consts.ISO9660_SECTOR_SIZE,
+		},
+	}
+	binary.LittleEndian.PutUint32(br.BootSystemUse[0:4], catalogLBA)
+	return br
+}
+
+// newElTorito creates the boot catalog for the boot entries of the image.
+func (iso *ISO9660) newElTorito(bootNodes []*node, catalogLBA uint32) *boot.ElTorito {
+	opts := iso.createOptions
+	et := &boot.ElTorito{
+		BootCatalog:     opts.BootCatalog,
+		HideBootCatalog: opts.HideBootCatalog,
+		Platform:        opts.BootEntries[0].Platform,
+		ObjectLocation:  int64(catalogLBA) * consts.ISO9660_SECTOR_SIZE,
+		ObjectSize:      consts.ISO9660_SECTOR_SIZE,
+		Logger:          iso.logger,
+	}
+
+	for i, entry := range opts.BootEntries {
+		n := bootNodes[i]
+		loadSegment := entry.LoadSegment
+		if loadSegment == 0 && entry.Platform == boot.BIOS {
+			loadSegment = EL_TORITO_DEFAULT_LOAD_SEGMENT
+		}
+		loadSize := entry.LoadSize
+		if loadSize == 0 {
+			switch {
+			case entry.Emulation != boot.NoEmulation:
+				loadSize = 1
+			case entry.Platform == boot.BIOS:
+				loadSize = 4
+			default:
+				// Load the whole image, saturating at the largest count the field can hold
+				loadSize = uint16(min((n.size+511)/512, 0xFFFF))
+			}
+		}
+		e := boot.NewElToritoEntry(entry.Platform, entry.Emulation, loadSegment, loadSize, n.location, !entry.NoBoot)
+		e.BootFile = n.path()
+		et.Entries = append(et.Entries, e)
+	}
+
+	return et
+}
+
+// patchBootInfoTable writes the 56-byte boot information table into a boot image at offset 8.
+func patchBootInfoTable(n *node, pvdLBA uint32) error {
+	if n.size < 64 {
+		return fmt.Errorf("boot image %s is too small for a boot info table", n.path())
+	}
+
+	data := make([]byte, n.size)
+	if _, err := io.ReadFull(io.NewSectionReader(n.source, 0, n.size), data); err != nil {
+		return fmt.Errorf("failed to read boot image %s: %w", n.path(), err)
+	}
+
+	var checksum uint32
+	for i := 64; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		checksum += binary.LittleEndian.Uint32(word[:])
+	}
+
+	clear(data[8:64])
+	binary.LittleEndian.PutUint32(data[8:12], pvdLBA)
+	binary.LittleEndian.PutUint32(data[12:16], n.location)
+	binary.LittleEndian.PutUint32(data[16:20], uint32(n.size))
+	binary.LittleEndian.PutUint32(data[20:24], checksum)
+	n.source = bytes.NewReader(data)
+
+	return nil
+}
+
+// writeHybridMBR writes an isohybrid style master boot record into the system area.
+func (iso *ISO9660) writeHybridMBR(bootNodes []*node, volumeSize uint32) error {
+	hybrid := iso.createOptions.Hybrid
+	mbr := iso.systemArea.Contents[:512]
+
+	if len(hybrid.MBR) > 0 {
+		copy(mbr[:432], hybrid.MBR)
+	}
+
+	// The MBR boot code loads the BIOS boot image using its LBA in 512-byte sectors
+	biosIndex := slices.IndexFunc(iso.createOptions.BootEntries, func(e option.BootEntry) bool {
+		return e.Platform == boot.BIOS
+	})
+	if len(hybrid.MBR) > 0 {
+		if biosIndex < 0 {
+			return fmt.Errorf("hybrid MBR boot code requires a BIOS El Torito boot entry")
+		}
+		binary.LittleEndian.PutUint32(mbr[432:436], bootNodes[biosIndex].location*4)
+	}
+	binary.LittleEndian.PutUint32(mbr[440:444], hybrid.DiskSignature)
+
+	partitionType := hybrid.PartitionType
+	if partitionType == 0 {
+		partitionType = HYBRID_DEFAULT_PARTITION_TYPE
+	}
+	writePartitionEntry(mbr[446:462], 0x80, partitionType, 0, volumeSize*4)
+
+	if hybrid.EFIPartition {
+		efiIndex := slices.IndexFunc(iso.createOptions.BootEntries, func(e option.BootEntry) bool {
+			return e.Platform == boot.EFI
+		})
+		if efiIndex < 0 {
+			return fmt.Errorf("hybrid EFI partition requires an EFI El Torito boot entry")
+		}
+		efi := bootNodes[efiIndex]
+		writePartitionEntry(mbr[462:478], 0x00, byte(boot.EFISystem), efi.location*4, uint32((efi.size+511)/512))
+	}
+
+	mbr[510] = 0x55
+	mbr[511] = 0xAA
+	return nil
+}
+
+// writePartitionEntry writes a 16-byte MBR partition entry using a 64 head, 32 sector geometry for the CHS fields.
+func writePartitionEntry(entry []byte, status, partitionType byte, start, count uint32) {
+	chs := func(lba uint32) [3]byte {
+		cylinder := lba / (64 * 32)
+		head := (lba / 32) % 64
+		sector := lba%32 + 1
+		if cylinder > 1023 {
+			cylinder, head, sector = 1023, 63, 32
+		}
+		return [3]byte{byte(head), byte(sector) | byte((cylinder>>8)<<6), byte(cylinder)}
+	}
+
+	first, last := chs(start), chs(start+count-1)
+	entry[0] = status
+	copy(entry[1:4], first[:])
+	entry[4] = partitionType
+	copy(entry[5:8], last[:])
+	binary.LittleEndian.PutUint32(entry[8:12], start)
+	binary.LittleEndian.PutUint32(entry[12:16], count)
+}
+
+// sectors returns the number of logical blocks needed to hold size bytes.
+func sectors(size int64) uint32 {
+	return uint32((size + consts.ISO9660_SECTOR_SIZE - 1) / consts.ISO9660_SECTOR_SIZE)
+}
@@ -0,0 +1,213 @@
+package iso9660
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/boot"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnhancedVolumeDescriptor(t *testing.T) {
+	long := strings.Repeat("a", 220) + ".txt"
+	img, err := Create("EVD_TEST", option.WithJolietEnabled(true), option.WithEnhancedEnabled(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/Mixed Case/"+long, []byte("long")))
+	require.NoError(t, img.AddFile("/Mixed Case/archive.tar.gz", []byte("archive")))
+
+	out := isotest.File(t, img)
+
+	opened, err := Open(out, option.WithPreferEnhanced(true))
+	require.NoError(t, err)
+	require.True(t, opened.HasEnhanced())
+	require.True(t, opened.HasJoliet())
+
+	data, err := fs.ReadFile(opened, "Mixed Case/archive.tar.gz")
+	require.NoError(t, err)
+	require.Equal(t, "archive", string(data))
+
+	entries, err := fs.ReadDir(opened, "Mixed Case")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, strings.Repeat("a", 203)+".txt", entries[0].Name())
+
+	// Without the preference the Joliet hierarchy is still used
+	opened, err = Open(out, option.WithPreferJoliet(true))
+	require.NoError(t, err)
+	_, err = fs.Stat(opened, "Mixed Case/archive.tar.gz")
+	require.NoError(t, err)
+	_, err = fs.Stat(opened, "Mixed Case/"+strings.Repeat("a", 203)+".txt")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestBootImage(t *testing.T) {
+	bios := make([]byte, 4096)
+	for i := range bios {
+		bios[i] = byte(i)
+	}
+	img, err := Create("BOOT_TEST",
+		option.WithBootEntry(option.BootEntry{ImagePath: "/boot/bios.img", BootInfoTable: true}),
+		option.WithBootEntry(option.BootEntry{ImagePath: "/boot/efi.img", Platform: boot.EFI}),
+		option.WithHybrid(option.HybridOptions{EFIPartition: true, DiskSignature: 0x12345678}))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/boot/bios.img", bios))
+	require.NoError(t, img.AddFile("/boot/efi.img", make([]byte, 3000)))
+	image := isotest.Bytes(t, img)
+
+	opened, err := Open(bytes.NewReader(image))
+	require.NoError(t, err)
+	require.True(t, opened.HasElTorito())
+	files, err := opened.ListFiles()
+	require.NoError(t, err)
+	location := func(name string) uint32 {
+		for _, f := range files {
+			if strings.HasPrefix(f.FullPath, name) {
+				return f.Location
+			}
+		}
+		t.Fatalf("%s not found", name)
+		return 0
+	}
+	biosLBA, efiLBA := location("/BOOT/BIOS.IMG"), location("/BOOT/EFI.IMG")
+
+	t.Run("catalog", func(t *testing.T) {
+		catalog := image[opened.elTorito.ObjectLocation:]
+		require.Equal(t, []byte{0x01, byte(boot.BIOS)}, catalog[:2])
+		require.Equal(t, []byte{0x55, 0xAA}, catalog[0x1E:0x20])
+		var sum uint16
+		for i := 0; i < 32; i += 2 {
+			sum += binary.LittleEndian.Uint16(catalog[i:])
+		}
+		require.Zero(t, sum, "validation entry checksum")
+
+		// The initial entry boots the BIOS image, a final section header holds the EFI entry
+		require.Equal(t, byte(0x88), catalog[32])
+		require.Equal(t, biosLBA, binary.LittleEndian.Uint32(catalog[40:]))
+		require.Equal(t, []byte{0x91, byte(boot.EFI), 1, 0}, catalog[64:68])
+		require.Equal(t, byte(0x88), catalog[96])
+		require.Equal(t, efiLBA, binary.LittleEndian.Uint32(catalog[104:]))
+
+		entries, err := opened.ListBootEntries()
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, biosLBA, entries[0].Location)
+		require.Equal(t, efiLBA, entries[1].Location)
+	})
+
+	t.Run("boot info table", func(t *testing.T) {
+		patched := image[int64(biosLBA)*consts.ISO9660_SECTOR_SIZE:][:len(bios)]
+		var checksum uint32
+		for i := 64; i < len(bios); i += 4 {
+			checksum += binary.LittleEndian.Uint32(bios[i:])
+		}
+		require.Equal(t, uint32(16), binary.LittleEndian.Uint32(patched[8:]), "primary volume descriptor LBA")
+		require.Equal(t, biosLBA, binary.LittleEndian.Uint32(patched[12:]))
+		require.Equal(t, uint32(len(bios)), binary.LittleEndian.Uint32(patched[16:]))
+		require.Equal(t, checksum, binary.LittleEndian.Uint32(patched[20:]))
+		require.Equal(t, make([]byte, 40), patched[24:64])
+		require.Equal(t, bios[:8], patched[:8])
+		require.Equal(t, bios[64:], patched[64:])
+	})
+
+	t.Run("hybrid MBR", func(t *testing.T) {
+		require.Zero(t, len(image)%(HYBRID_ALIGNMENT_SECTORS*consts.ISO9660_SECTOR_SIZE))
+		require.Equal(t, []byte{0x55, 0xAA}, image[510:512])
+		require.Equal(t, uint32(0x12345678), binary.LittleEndian.Uint32(image[440:]))
+
+		first := image[446:462]
+		require.Equal(t, byte(0x80), first[0])
+		require.Equal(t, byte(HYBRID_DEFAULT_PARTITION_TYPE), first[4])
+		require.Zero(t, binary.LittleEndian.Uint32(first[8:]))
+		require.Equal(t, uint32(len(image)/512), binary.LittleEndian.Uint32(first[12:]))
+
+		efi := image[462:478]
+		require.Equal(t, byte(0x00), efi[0])
+		require.Equal(t, byte(boot.EFISystem), efi[4])
+		require.Equal(t, efiLBA*4, binary.LittleEndian.Uint32(efi[8:]))
+		require.Equal(t, uint32(6), binary.LittleEndian.Uint32(efi[12:]))
+	})
+}
+
+func TestContinuationAreaPlacement(t *testing.T) {
+	target := strings.Repeat("long-target/", 30)
+	img, err := Create("CE_TEST", option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/file.txt", []byte("file")))
+	require.NoError(t, img.AddSymlink("/link", target))
+	image := isotest.Bytes(t, img)
+
+	// The symbolic link does not fit its record, so its entries continue in a continuation area. Every area is
+	// recorded where its CE entry points.
+	var start int64 = -1
+	for _, ca := range img.continuationAreas {
+		offset := int64(ca.Block)*consts.ISO9660_SECTOR_SIZE + int64(ca.BlockOffset)
+		require.Equal(t, ca.Data, image[offset:offset+int64(len(ca.Data))], ca.Owner)
+		if ca.Owner == "/link" {
+			start = offset
+		}
+	}
+	require.NotEqual(t, int64(-1), start)
+
+	opened, err := Open(bytes.NewReader(image))
+	require.NoError(t, err)
+	files, err := opened.ListFiles()
+	require.NoError(t, err)
+	var link, file *filesystem.FileSystemEntry
+	for _, f := range files {
+		switch f.Name {
+		case "link":
+			link = f
+		case "file.txt":
+			file = f
+		}
+	}
+	require.NotNil(t, link)
+	require.NotNil(t, file)
+	require.Equal(t, strings.TrimSuffix(target, "/"), link.SymlinkTarget)
+
+	// The area is neither inside the root directory extent nor inside the file data
+	root := int64(opened.RootDirectoryLocation()) * consts.ISO9660_SECTOR_SIZE
+	require.False(t, start >= root && start < root+consts.ISO9660_SECTOR_SIZE)
+	data := int64(file.Location) * consts.ISO9660_SECTOR_SIZE
+	require.False(t, start >= data && start < data+consts.ISO9660_SECTOR_SIZE)
+	require.Less(t, start, int64(len(image)))
+}
+
+func TestConvertIdentifiers(t *testing.T) {
+	img, err := Create("mixed-Case",
+		option.WithJolietEnabled(true),
+		option.WithPublisherID("Publisher é"),
+		option.WithVolumeSetID("set.1"),
+		option.WithCopyrightFileID("copying.txt;1"))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/copying.txt", []byte("copying")))
+	out := isotest.File(t, img)
+
+	opened, err := Open(out)
+	require.NoError(t, err)
+	require.Equal(t, "MIXED_CASE", opened.GetVolumeID())
+	require.Equal(t, "PUBLISHER _", opened.GetPublisherID())
+	require.Equal(t, "SET_1", opened.GetVolumeSetID())
+	require.Equal(t, "COPYING.TXT;1", opened.GetCopyrightID())
+
+	// The Joliet descriptor keeps the identifiers as they were given
+	opened, err = Open(out, option.WithPreferJoliet(true))
+	require.NoError(t, err)
+	require.Equal(t, "mixed-Case", opened.GetVolumeID())
+	require.Equal(t, "Publisher é", opened.GetPublisherID())
+
+	img, err = Create("TOO_LONG", option.WithSystemID(strings.Repeat("S", 33)))
+	require.NoError(t, err)
+	f, err := os.Create(filepath.Join(t.TempDir(), "image.iso"))
+	require.NoError(t, err)
+	defer f.Close()
+	require.ErrorContains(t, img.Save(f), "system identifier")
+}
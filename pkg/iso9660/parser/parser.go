@@ -16,8 +16,15 @@ import (
 	"github.com/bgrewell/iso-kit/pkg/logging"
 	"github.com/bgrewell/iso-kit/pkg/option"
 	"io"
+	"strings"
+	"sync"
+	"time"
 )
 
+// Largest directory extent read. Directories hold a few records per file, so this is far more than any real directory
+// needs, and a corrupt data length does not lead to an allocation of up to 4 GiB.
+const maxDirectorySize = 64 * 1024 * 1024
+
 // NewParser creates a new Parser object with the provided reader and options.
 func NewParser(reader io.ReaderAt, options *option.OpenOptions) *Parser {
 	return &Parser{
@@ -33,13 +40,46 @@ type Parser struct {
 	options *option.OpenOptions
 	logger  *logging.Logger
 	layout  *info.ISOLayout
+	// First logical sector of the session being read, zero for the first session
+	sessionStart uint32
+	// Reader used for directory extents and continuation areas, the image reader when nil
+	directoryReader io.ReaderAt
+	// Damaged structures skipped with tolerant parsing
+	problemsMu sync.Mutex
+	problems   []Problem
+	reported   map[problemKey]bool
+}
+
+// SetSessionStart selects the session to read by the logical sector it starts at. The volume descriptor set of a
+// session is recorded 16 sectors after its start; all other locations in the image are absolute.
+func (p *Parser) SetSessionStart(sector uint32) {
+	p.sessionStart = sector
+}
+
+// SetDirectoryReader makes the parser read directory extents and their continuation areas through r instead of the
+// image reader, for example to cache them. File data is always read through the image reader.
+func (p *Parser) SetDirectoryReader(r io.ReaderAt) {
+	p.directoryReader = r
+}
+
+// dirReader returns the reader used for directory extents and continuation areas.
+func (p *Parser) dirReader() io.ReaderAt {
+	if p.directoryReader != nil {
+		return p.directoryReader
+	}
+	return p.reader
+}
+
+// descriptorSetSector returns the logical sector the volume descriptor set of the selected session starts at.
+func (p *Parser) descriptorSetSector() int64 {
+	return int64(p.sessionStart) + consts.ISO9660_SYSTEM_AREA_SECTORS
 }
 
 // GetBootRecord reads and validates the ISO9660 boot record.
 func (p *Parser) GetBootRecord() (*descriptor.BootRecordDescriptor, error) {
 	const sectorSize = consts.ISO9660_SECTOR_SIZE
-	// The Volume Descriptor Set starts at logical sector 16.
-	sector := int64(consts.ISO9660_SYSTEM_AREA_SECTORS)
+	// The Volume Descriptor Set starts at logical sector 16 of the session.
+	sector := p.descriptorSetSector()
 	p.logger.Trace("Searching for boot record", "sector", sector)
 	var buf [2048]byte
 
@@ -119,7 +159,7 @@ func (p *Parser) GetElTorito(bootRecord *descriptor.BootRecordDescriptor) (*boot
 // GetPrimaryVolumeDescriptor reads and validates the ISO9660 PVD.
 func (p *Parser) GetPrimaryVolumeDescriptor() (*descriptor.PrimaryVolumeDescriptor, error) {
 	var buf [2048]byte
-	offset := int64(consts.ISO9660_SYSTEM_AREA_SECTORS * consts.ISO9660_SECTOR_SIZE)
+	offset := p.descriptorSetSector() * consts.ISO9660_SECTOR_SIZE
 	n, err := p.reader.ReadAt(buf[:], offset)
 	if err != nil {
 		return nil, err
@@ -158,8 +198,8 @@ func (p *Parser) GetPrimaryVolumeDescriptor() (*descriptor.PrimaryVolumeDescript
 // GetSupplementaryVolumeDescriptors reads and validates the ISO9660 SVD.
 func (p *Parser) GetSupplementaryVolumeDescriptors() ([]*descriptor.SupplementaryVolumeDescriptor, error) {
 	const sectorSize = consts.ISO9660_SECTOR_SIZE
-	// The Volume Descriptor Set starts at logical sector 16.
-	sector := int64(consts.ISO9660_SYSTEM_AREA_SECTORS)
+	// The Volume Descriptor Set starts at logical sector 16 of the session.
+	sector := p.descriptorSetSector()
 	var buf [2048]byte
 
 	// Create a slice to hold the SupplementaryVolumeDescriptors
@@ -192,7 +232,7 @@ func (p *Parser) GetSupplementaryVolumeDescriptors() ([]*descriptor.Supplementar
 
 		// If this is a Supplementary Volume Descriptor, unmarshal it and add to the collection.
 		if header.VolumeDescriptorType == descriptor.TYPE_SUPPLEMENTARY_DESCRIPTOR {
-			p.logger.Info("Reading supplementary volume descriptor", "offset", consts.ISO9660_SYSTEM_AREA_SECTORS*consts.ISO9660_SECTOR_SIZE)
+			p.logger.Info("Reading supplementary volume descriptor", "offset", offset)
 			svd := &descriptor.SupplementaryVolumeDescriptor{
 				VolumeDescriptorHeader: header,
 				SupplementaryVolumeDescriptorBody: descriptor.SupplementaryVolumeDescriptorBody{
@@ -217,8 +257,8 @@ func (p *Parser) GetSupplementaryVolumeDescriptors() ([]*descriptor.Supplementar
 // GetVolumePartitionDescriptors reads and validates ISO9660 Volume Partition Descriptors (VPDs).
 func (p *Parser) GetVolumePartitionDescriptors() ([]*descriptor.VolumePartitionDescriptor, error) {
 	const sectorSize = consts.ISO9660_SECTOR_SIZE
-	// The Volume Descriptor Set starts at logical sector 16.
-	sector := int64(consts.ISO9660_SYSTEM_AREA_SECTORS)
+	// The Volume Descriptor Set starts at logical sector 16 of the session.
+	sector := p.descriptorSetSector()
 	var buf [sectorSize]byte
 
 	// Slice to store detected Volume Partition Descriptors
@@ -248,7 +288,7 @@ func (p *Parser) GetVolumePartitionDescriptors() ([]*descriptor.VolumePartitionD
 
 		// If this is a Volume Partition Descriptor, unmarshal it and add it to the collection.
 		if header.VolumeDescriptorType == descriptor.TYPE_PARTITION_DESCRIPTOR {
-			p.logger.Info("Reading volume partition descriptor", "offset", consts.ISO9660_SYSTEM_AREA_SECTORS*consts.ISO9660_SECTOR_SIZE)
+			p.logger.Info("Reading volume partition descriptor", "offset", offset)
 			vpd := &descriptor.VolumePartitionDescriptor{
 				VolumeDescriptorHeader: header,
 				VolumePartitionDescriptorBody: descriptor.VolumePartitionDescriptorBody{
@@ -273,8 +313,8 @@ func (p *Parser) GetVolumePartitionDescriptors() ([]*descriptor.VolumePartitionD
 // GetVolumeDescriptorSetTerminator reads and validates the ISO9660 Volume Descriptor Set Terminator.
 func (p *Parser) GetVolumeDescriptorSetTerminator() (*descriptor.VolumeDescriptorSetTerminator, error) {
 	const sectorSize = consts.ISO9660_SECTOR_SIZE
-	// The Volume Descriptor Set starts at logical sector 16.
-	sector := int64(consts.ISO9660_SYSTEM_AREA_SECTORS)
+	// The Volume Descriptor Set starts at logical sector 16 of the session.
+	sector := p.descriptorSetSector()
 	p.logger.Trace("Searching for volume descriptor set terminator record", "sector", sector)
 	var buf [2048]byte
 
@@ -359,29 +399,8 @@ func (p *Parser) BuildFileSystemEntries(rootDir *directory.DirectoryRecord, Rock
 		}
 
 		for _, record := range dirRecords {
-			// Build full path
-			fullPath := parentPath + "/" + record.GetBestName(RockRidgeEnabled)
-
-			// Retrieve file attributes
-			permissions := record.GetPermissions(RockRidgeEnabled)
-			uid, gid := record.GetOwnership(RockRidgeEnabled)
-			creationTime, modificationTime := record.GetTimestamps(RockRidgeEnabled)
-
-			// Create FileSystemEntry
-			entry := filesystem.NewFileSystemEntry(
-				record.GetBestName(RockRidgeEnabled),
-				fullPath,
-				record.IsDirectory(),
-				record.DataLength,
-				record.LocationOfExtent,
-				uid,
-				gid,
-				permissions,
-				creationTime,
-				modificationTime,
-				record,
-				p.reader,
-			)
+			entry := p.BuildFileSystemEntry(record, parentPath, RockRidgeEnabled)
+			fullPath := entry.FullPath
 			p.logger.Trace("Created FileSystemEntry", "path", fullPath, "location", record.LocationOfExtent)
 
 			// Filter out root and parent entries4
@@ -410,6 +429,92 @@ func (p *Parser) BuildFileSystemEntries(rootDir *directory.DirectoryRecord, Rock
 	return entries, nil
 }
 
+// BuildFileSystemEntry converts a directory record read from the directory at parentPath into a FileSystemEntry.
+func (p *Parser) BuildFileSystemEntry(record *directory.DirectoryRecord, parentPath string, RockRidgeEnabled bool) *filesystem.FileSystemEntry {
+	// Build full path. Names that would step out of the directory fall back to the ISO9660 identifier, and to an
+	// escaped form of it when the identifier is not usable either.
+	name := record.GetBestName(RockRidgeEnabled)
+	if !validName(name) {
+		name = record.FileIdentifier
+	}
+	if !validName(name) {
+		name = strings.ReplaceAll(name, "/", "_")
+		if name == "." || name == ".." || name == "" {
+			name = "_"
+		}
+	}
+	fullPath := parentPath + "/" + name
+
+	// Retrieve file attributes
+	permissions := record.GetPermissions(RockRidgeEnabled)
+	uid, gid := record.GetOwnership(RockRidgeEnabled)
+	creationTime, modificationTime := record.GetTimestamps(RockRidgeEnabled)
+
+	// Create FileSystemEntry
+	entry := filesystem.NewFileSystemEntry(
+		name,
+		fullPath,
+		record.IsDirectory(),
+		record.DataLength,
+		record.LocationOfExtent,
+		uid,
+		gid,
+		permissions,
+		creationTime,
+		modificationTime,
+		record,
+		p.reader,
+	)
+	if record.XA != nil {
+		entry.XA = record.XA
+		// Form 2 files are read with the 2324 bytes of user data of each sector when the image has raw sectors
+		if _, ok := p.reader.(extent.SectorReader); ok && record.XA.IsForm2() && !record.IsDirectory() {
+			entry.Size = extent.Form2Size(record.DataLength)
+		}
+	}
+	if RockRidgeEnabled && record.RockRidge != nil {
+		setRockRidgeTimes(entry, record.RockRidge)
+		if record.RockRidge.SymlinkTarget != nil {
+			entry.SymlinkTarget = *record.RockRidge.SymlinkTarget
+		}
+		if record.RockRidge.Major != nil && record.RockRidge.Minor != nil {
+			entry.DeviceMajor = *record.RockRidge.Major
+			entry.DeviceMinor = *record.RockRidge.Minor
+		}
+		if record.RockRidge.LinkCount != nil {
+			entry.LinkCount = *record.RockRidge.LinkCount
+		}
+		if record.RockRidge.SerialNumber != nil {
+			entry.SerialNumber = *record.RockRidge.SerialNumber
+		}
+	}
+	return entry
+}
+
+// validName returns true if name can be used as the name of a directory entry: it is not empty, "." or ".." and does
+// not contain a path separator.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.Contains(name, "/")
+}
+
+// setRockRidgeTimes copies the Rock Ridge TF time stamps that have no FileSystemEntry constructor argument.
+func setRockRidgeTimes(entry *filesystem.FileSystemEntry, rr *extensions.RockRidgeExtensions) {
+	for _, ts := range []struct {
+		from *time.Time
+		to   *time.Time
+	}{
+		{rr.AccessTime, &entry.AccessTime},
+		{rr.AttributeChangeTime, &entry.AttributeChangeTime},
+		{rr.BackupTime, &entry.BackupTime},
+		{rr.ExpirationTime, &entry.ExpirationTime},
+		{rr.EffectiveTime, &entry.EffectiveTime},
+	} {
+		if ts.from != nil {
+			*ts.to = *ts.from
+		}
+	}
+}
+
 // TODO: Should this not be exported?
 // WalkDirectoryRecords recursively walks the directory tree from a given directory record
 // and returns a slice of fully populated DirectoryRecord pointers.
@@ -447,10 +552,12 @@ func (p *Parser) WalkDirectoryRecords(rootDir *directory.DirectoryRecord) ([]*di
 			} else {
 
 				fe := &extent.FileExtent{
-					FileIdentifier: record.GetBestName(p.options.RockRidgeEnabled),
-					LocationOfFile: record.LocationOfExtent,
-					SizeOfFile:     record.DataLength,
-					Reader:         p.reader,
+					FileIdentifier:    record.GetBestName(p.options.RockRidgeEnabled),
+					LocationOfFile:    record.LocationOfExtent,
+					SizeOfFile:        record.DataLength,
+					FileUnitSize:      record.FileUnitSize,
+					InterleaveGapSize: record.InterleaveGapSize,
+					Reader:            p.reader,
 				}
 				record.FileExtent = fe
 
@@ -468,18 +575,46 @@ func (p *Parser) WalkDirectoryRecords(rootDir *directory.DirectoryRecord) ([]*di
 	return records, nil
 }
 
+// ReadDirectory reads the records of the directory whose extent starts at lba. The length of the extent is taken from
+// the "." record at its start, so a directory can be read knowing only its location, as recorded in a path table.
+func (p *Parser) ReadDirectory(lba uint32, joliet bool) ([]*directory.DirectoryRecord, error) {
+	var self [18]byte
+	if _, err := p.dirReader().ReadAt(self[:], int64(lba)*consts.ISO9660_SECTOR_SIZE); err != nil {
+		return nil, fmt.Errorf("failed to read directory sector at LBA %d: %w", lba, err)
+	}
+	if self[0] < 34 {
+		return nil, fmt.Errorf("no directory found at LBA %d", lba)
+	}
+	return p.ReadDirectoryRecords(lba, binary.LittleEndian.Uint32(self[10:14]), joliet)
+}
+
 // ReadDirectoryRecords reads directory records from a given LBA (logical block address)
 // and processes Rock Ridge extensions if present.
 func (p *Parser) ReadDirectoryRecords(lba uint32, dataLength uint32, joliet bool) ([]*directory.DirectoryRecord, error) {
 
 	sectorSize := consts.ISO9660_SECTOR_SIZE
 	offset := int64(lba) * int64(sectorSize)
+
+	// A corrupt data length is not trusted with an allocation of up to 4 GiB. Tolerant parsing reads the first sector,
+	// the only one known to belong to the directory.
+	if dataLength > maxDirectorySize {
+		err := fmt.Errorf("directory length %d exceeds the maximum of %d bytes", dataLength, maxDirectorySize)
+		if !p.Tolerant() {
+			return nil, fmt.Errorf("failed to read directory at LBA %d: %w", lba, err)
+		}
+		p.Report(Problem{Structure: "directory extent", LBA: lba, Offset: offset, Err: err})
+		dataLength = consts.ISO9660_SECTOR_SIZE
+	}
 	totalBytes := int(dataLength)
 
 	buf := make([]byte, totalBytes)
-	_, err := p.reader.ReadAt(buf, offset)
+	_, err := p.dirReader().ReadAt(buf, offset)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read directory sector at LBA %d: %w", lba, err)
+		if !p.Tolerant() {
+			return nil, fmt.Errorf("failed to read directory sector at LBA %d: %w", lba, err)
+		}
+		buf = p.readDirectorySectors(buf, lba)
+		totalBytes = len(buf)
 	}
 
 	var records []*directory.DirectoryRecord
@@ -520,16 +655,43 @@ func (p *Parser) ReadDirectoryRecords(lba uint32, dataLength uint32, joliet bool
 		}
 		err = dr.Unmarshal(recordData)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse directory record: %w", err)
+			if !p.Tolerant() {
+				return nil, fmt.Errorf("failed to parse directory record: %w", err)
+			}
+			p.Report(Problem{
+				Structure: "directory record",
+				LBA:       lba + uint32(index/sectorSize),
+				Offset:    offset + int64(index),
+				Err:       err,
+			})
+			index += int(length)
+			continue
 		}
 
 		dr.ObjectLocation = int64(index) + offset
 		dr.ObjectSize = dr.DataLength
 
-		// **Parse Rock Ridge extensions if present**
+		// The CD-ROM XA information of Mode 2 discs precedes any System Use entries
+		var entries []byte
+		dr.XA, entries = extensions.UnmarshalXA(dr.SystemUse)
+
+		// **Parse Rock Ridge extensions if present**, including entries moved to continuation areas
 		var rr *extensions.RockRidgeExtensions
-		if len(dr.SystemUse) > 0 {
-			rr, err = extensions.UnmarshalRockRidge(dr.SystemUse)
+		if len(entries) > 0 {
+			systemUse, err := extensions.ReadSystemUse(entries, p.dirReader())
+			if err != nil {
+				p.logger.Debug("Failed to read continuation areas", "record", dr.FileIdentifier, "error", err)
+				if p.Tolerant() {
+					p.Report(Problem{
+						Structure: "continuation area",
+						LBA:       lba + uint32(index/sectorSize),
+						Offset:    offset + int64(index),
+						Err:       err,
+					})
+				}
+				systemUse = entries
+			}
+			rr, err = extensions.UnmarshalRockRidge(systemUse)
 			if err == nil {
 				dr.RockRidge = rr
 			}
@@ -545,3 +707,22 @@ func (p *Parser) ReadDirectoryRecords(lba uint32, dataLength uint32, joliet bool
 	p.logger.Debug("Finished reading directory records", "sector", lba, "records", len(records))
 	return records, nil
 }
+
+// readDirectorySectors reads the directory extent starting at lba into buf one sector at a time, leaving the sectors
+// that cannot be read zero-filled so the records of the readable ones can still be parsed. An extent running past the
+// end of the image is cut at the last sector read and reported once; the part of buf holding the extent is returned.
+func (p *Parser) readDirectorySectors(buf []byte, lba uint32) []byte {
+	const sectorSize = consts.ISO9660_SECTOR_SIZE
+	for i := 0; i*sectorSize < len(buf); i++ {
+		sector := buf[i*sectorSize : min((i+1)*sectorSize, len(buf))]
+		offset := (int64(lba) + int64(i)) * sectorSize
+		if _, err := p.dirReader().ReadAt(sector, offset); err != nil {
+			clear(sector)
+			p.Report(Problem{Structure: "directory sector", LBA: lba + uint32(i), Offset: offset, Err: err})
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return buf[:i*sectorSize]
+			}
+		}
+	}
+	return buf
+}
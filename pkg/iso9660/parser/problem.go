@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+)
+
+// Problem is damage found in an image opened with tolerant parsing. The damaged structure is skipped and the rest of
+// the image is read as far as possible.
+type Problem struct {
+	// Structure that could not be read, such as "directory record" or "file data"
+	Structure string
+	// Path of the file the structure belongs to, when known
+	Path string
+	// Sector the structure was read from and its offset in bytes from the start of the image
+	LBA    uint32
+	Offset int64
+	// Err is the error reading the structure failed with
+	Err error
+}
+
+// problemKey identifies a damaged structure among the problems reported.
+type problemKey struct {
+	structure string
+	path      string
+	offset    int64
+}
+
+// Error describes the problem and where it was found.
+func (p Problem) Error() string {
+	if p.Path != "" {
+		return fmt.Sprintf("%s of %s at LBA %d (offset %d): %v", p.Structure, p.Path, p.LBA, p.Offset, p.Err)
+	}
+	return fmt.Sprintf("%s at LBA %d (offset %d): %v", p.Structure, p.LBA, p.Offset, p.Err)
+}
+
+// Unwrap returns the error reading the structure failed with.
+func (p Problem) Unwrap() error {
+	return p.Err
+}
+
+// Tolerant returns true if the image is read with tolerant parsing, skipping damaged structures. Images that were not
+// opened, and so have no parser, are never read tolerantly.
+func (p *Parser) Tolerant() bool {
+	return p != nil && p.options.TolerantParsing
+}
+
+// Report records a problem found in the image. Directories can be read more than once, by the directory walks of Open
+// and by lookups, so a structure already reported is not recorded again.
+func (p *Parser) Report(problem Problem) {
+	p.problemsMu.Lock()
+	defer p.problemsMu.Unlock()
+	key := problemKey{structure: problem.Structure, path: problem.Path, offset: problem.Offset}
+	if p.reported[key] {
+		return
+	}
+	if p.reported == nil {
+		p.reported = make(map[problemKey]bool)
+	}
+	p.reported[key] = true
+	p.logger.Info("Skipping damaged structure", "structure", problem.Structure, "path", problem.Path,
+		"lba", problem.LBA, "offset", problem.Offset, "error", problem.Err)
+	p.problems = append(p.problems, problem)
+}
+
+// Tolerate returns err unless the image is read with tolerant parsing, in which case it is recorded as a problem of
+// the structure read from the sector at lba and nil is returned.
+func (p *Parser) Tolerate(err error, lba uint32, structure string) error {
+	if err == nil || !p.Tolerant() {
+		return err
+	}
+	p.Report(Problem{
+		Structure: structure,
+		LBA:       lba,
+		Offset:    int64(lba) * consts.ISO9660_SECTOR_SIZE,
+		Err:       err,
+	})
+	return nil
+}
+
+// Problems returns the problems found so far, in the order they were found.
+func (p *Parser) Problems() []Problem {
+	p.problemsMu.Lock()
+	defer p.problemsMu.Unlock()
+	return append([]Problem(nil), p.problems...)
+}
@@ -0,0 +1,240 @@
+package iso9660
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/directory"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/encoding"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extensions"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"time"
+)
+
+// ErrInsufficientSpace is returned when new content does not fit in the sectors allocated to a file.
+var ErrInsufficientSpace = errors.New("content does not fit in the allocated sectors")
+
+// PatchFile overwrites the content of an existing file in place. The image must have been opened with Open from the
+// image file itself, or from its bytes, and w must write to that same image (for example the *os.File opened
+// read-write). Images read through a sector translation (BIN/CUE, NRG, MDF/MDS and raw CD images), a decompressor
+// (CSO/ZSO) or over HTTP cannot be patched. The content must fit in the sectors already allocated to the file,
+// otherwise ErrInsufficientSpace is returned and nothing is written.
+//
+// The data length of every primary and supplementary (Joliet) directory record pointing at the file's extent is
+// updated, together with the recording date and the Rock Ridge TF modification, access and attribute change stamps.
+// Any bytes left in the last allocated sector are zeroed. Files recorded in interleaved mode or in CD-ROM XA Form 2
+// sectors cannot be patched.
+//
+// Every sector is read and prepared before the first write, so a damaged record leaves the image untouched. A write
+// failing part way through, however, leaves the content and some of the records updated and the others not.
+func (iso *ISO9660) PatchFile(w io.WriterAt, filePath string, data []byte) error {
+	if iso.isoReader == nil {
+		return errors.New("only images opened from a reader can be patched")
+	}
+	if !untranslated(iso.isoReader) {
+		return fmt.Errorf("cannot patch %s: the image is read through a sector translation, a decompressor or over HTTP, not from the image file", filePath)
+	}
+
+	entry, err := iso.findEntry(filePath)
+	if err != nil {
+		return err
+	}
+	if entry.IsDir {
+		return fmt.Errorf("%s is a directory", filePath)
+	}
+	if dr := entry.DirectoryRecord(); dr != nil && dr.FileUnitSize > 0 {
+		return fmt.Errorf("%s is recorded in interleaved mode and cannot be patched", filePath)
+	}
+	if entry.XA != nil && entry.XA.IsForm2() {
+		return fmt.Errorf("%s is recorded in CD-ROM XA Form 2 sectors and cannot be patched", filePath)
+	}
+
+	location, oldSize := entry.Location, entry.Size
+	allocated := int64(sectors(int64(oldSize))) * consts.ISO9660_SECTOR_SIZE
+	if int64(len(data)) > allocated || (location == 0 && len(data) > 0) {
+		return fmt.Errorf("patching %s with %d bytes (%d allocated): %w", filePath, len(data), allocated, ErrInsufficientSpace)
+	}
+
+	// Every record sharing the extent is updated so all hierarchies agree on the new length
+	if err := iso.loadDirectoryRecords(); err != nil {
+		return err
+	}
+	records := iso.recordsForExtent(location, oldSize)
+	if len(records) == 0 {
+		return fmt.Errorf("no directory records found for %s", filePath)
+	}
+
+	// The content is written zero filling the remainder of the allocation so no stale data is left behind
+	var writes []pendingWrite
+	if allocated > 0 {
+		buf := make([]byte, allocated)
+		copy(buf, data)
+		writes = append(writes, pendingWrite{buf, int64(location) * consts.ISO9660_SECTOR_SIZE})
+	}
+	modTime := time.Now()
+	for _, dr := range records {
+		recordWrites, err := iso.patchRecord(dr, uint32(len(data)), modTime)
+		if err != nil {
+			return fmt.Errorf("failed to update directory record of %s: %w", filePath, err)
+		}
+		writes = append(writes, recordWrites...)
+	}
+
+	for _, pw := range writes {
+		if _, err := w.WriteAt(pw.data, pw.offset); err != nil {
+			return fmt.Errorf("failed to write %s at offset %d, the image is partially patched: %w", filePath, pw.offset, err)
+		}
+	}
+
+	// Keep the in memory view in sync with the image
+	for _, dr := range records {
+		updateRecord(dr, uint32(len(data)), modTime)
+	}
+	entries, err := iso.entries()
+	if err != nil {
+		return err
+	}
+	for _, fse := range entries {
+		if !fse.IsDir && fse.Location == location && fse.Size == oldSize {
+			fse.Size = uint32(len(data))
+			fse.ModTime = modTime
+			if !fse.AccessTime.IsZero() {
+				fse.AccessTime = modTime
+			}
+			if !fse.AttributeChangeTime.IsZero() {
+				fse.AttributeChangeTime = modTime
+			}
+		}
+	}
+
+	iso.logger.Debug("Patched file", "path", filePath, "location", location, "size", len(data))
+	return nil
+}
+
+// pendingWrite is data prepared to be written at an offset of the image.
+type pendingWrite struct {
+	data   []byte
+	offset int64
+}
+
+// untranslated reports whether r reads the image as it is stored, so offsets in the image are offsets in the file
+// written to. Readers translating sectors, decompressing or fetching over HTTP present a different layout.
+func untranslated(r io.ReaderAt) bool {
+	switch r.(type) {
+	case *os.File, *bytes.Reader:
+		return true
+	}
+	return false
+}
+
+// findEntry returns the filesystem entry with the given path.
+func (iso *ISO9660) findEntry(filePath string) (*filesystem.FileSystemEntry, error) {
+	cleaned := path.Clean("/" + filePath)
+	entries, err := iso.entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if path.Clean("/"+entry.FullPath) == cleaned {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", filePath, fs.ErrNotExist)
+}
+
+// recordsForExtent returns the file records of every hierarchy that point at the given extent.
+func (iso *ISO9660) recordsForExtent(location, size uint32) []*directory.DirectoryRecord {
+	all := iso.volumeDescriptorSet.Primary.DirectoryRecords
+	for _, svd := range iso.volumeDescriptorSet.Supplementary {
+		all = append(all[:len(all):len(all)], svd.DirectoryRecords...)
+	}
+
+	var records []*directory.DirectoryRecord
+	for _, dr := range all {
+		if !dr.IsDirectory() && dr.LocationOfExtent == location && dr.DataLength == size {
+			records = append(records, dr)
+		}
+	}
+	return records
+}
+
+// patchRecord prepares the writes updating the data length, recording date and Rock Ridge time stamps of a directory
+// record. The record is modified byte for byte so every other field keeps its original encoding.
+func (iso *ISO9660) patchRecord(dr *directory.DirectoryRecord, size uint32, modTime time.Time) ([]pendingWrite, error) {
+	var length [1]byte
+	if _, err := iso.isoReader.ReadAt(length[:], dr.ObjectLocation); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, length[0])
+	if _, err := iso.isoReader.ReadAt(raw, dr.ObjectLocation); err != nil {
+		return nil, err
+	}
+	if len(raw) < 33 {
+		return nil, fmt.Errorf("directory record at offset %d is too short", dr.ObjectLocation)
+	}
+
+	dataLength := encoding.MarshalBothByteOrders32(size)
+	copy(raw[10:18], dataLength[:])
+	recorded, err := encoding.MarshalRecordingDateTime(modTime)
+	if err != nil {
+		return nil, err
+	}
+	copy(raw[18:25], recorded[:])
+
+	// System Use area follows the identifier and its padding byte
+	suOffset := 33 + int(raw[32])
+	if raw[32]%2 == 0 {
+		suOffset++
+	}
+	var continuations []extensions.ContinuationReference
+	if suOffset < len(raw) {
+		// The CD-ROM XA information of Mode 2 discs precedes the System Use entries
+		_, entries := extensions.UnmarshalXA(raw[suOffset:])
+		if continuations, err = extensions.UpdateTimeStamps(entries, modTime); err != nil {
+			return nil, err
+		}
+	}
+	writes := []pendingWrite{{raw, dr.ObjectLocation}}
+
+	// Follow continuation areas, which may hold the TF entry and further CE entries
+	for depth := 0; len(continuations) > 0; depth++ {
+		if depth > consts.ISO9660_SECTOR_SIZE {
+			return nil, errors.New("too many chained continuation areas")
+		}
+		ce := continuations[0]
+		continuations = continuations[1:]
+		area, err := extensions.ReadContinuationArea(iso.isoReader, ce)
+		if err != nil {
+			return nil, err
+		}
+		more, err := extensions.UpdateTimeStamps(area, modTime)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, pendingWrite{area, int64(ce.Block)*consts.ISO9660_SECTOR_SIZE + int64(ce.Offset)})
+		continuations = append(continuations, more...)
+	}
+	return writes, nil
+}
+
+// updateRecord applies a patch to the in memory directory record.
+func updateRecord(dr *directory.DirectoryRecord, size uint32, modTime time.Time) {
+	dr.DataLength = size
+	dr.ObjectSize = size
+	dr.RecordingDateAndTime = modTime
+	if dr.FileExtent != nil {
+		dr.FileExtent.SizeOfFile = size
+	}
+	if dr.RockRidge != nil {
+		for _, stamp := range []**time.Time{&dr.RockRidge.ModificationTime, &dr.RockRidge.AccessTime, &dr.RockRidge.AttributeChangeTime} {
+			if *stamp != nil {
+				*stamp = &modTime
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package iso9660
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extensions"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatchFile(t *testing.T) {
+	old := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	img, err := Create("PATCH_TEST", option.WithJolietEnabled(true), option.WithEnableRockRidge(true))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/docs/readme.txt", []byte("hello")))
+	require.NoError(t, img.AddFile("/docs/other.txt", []byte("other")))
+	require.NoError(t, img.SetFileOptions("/docs/readme.txt", option.WithModTime(old)))
+	out := isotest.File(t, img)
+
+	opened, err := Open(out, option.WithRockRidgeEnabled(true))
+	require.NoError(t, err)
+
+	t.Run("in place", func(t *testing.T) {
+		content := strings.Repeat("patched ", 100)
+		require.NoError(t, opened.PatchFile(out, "/docs/readme.txt", []byte(content)))
+
+		// Every hierarchy sees the new content and the new modification time
+		for _, tc := range []struct {
+			opts          []option.OpenOption
+			patched, kept string
+		}{
+			{[]option.OpenOption{option.WithRockRidgeEnabled(true)}, "docs/readme.txt", "docs/other.txt"},
+			{[]option.OpenOption{option.WithRockRidgeEnabled(false)}, "DOCS/README.TXT;1", "DOCS/OTHER.TXT;1"},
+			{[]option.OpenOption{option.WithPreferJoliet(true)}, "docs/readme.txt", "docs/other.txt"},
+		} {
+			reopened, err := Open(out, tc.opts...)
+			require.NoError(t, err)
+			data, err := fs.ReadFile(reopened, tc.patched)
+			require.NoError(t, err)
+			require.Equal(t, content, string(data))
+			info, err := fs.Stat(reopened, tc.patched)
+			require.NoError(t, err)
+			require.WithinDuration(t, time.Now(), info.ModTime(), time.Minute)
+			data, err = fs.ReadFile(reopened, tc.kept)
+			require.NoError(t, err)
+			require.Equal(t, "other", string(data))
+		}
+	})
+
+	t.Run("insufficient space", func(t *testing.T) {
+		before, err := os.ReadFile(out.Name())
+		require.NoError(t, err)
+		err = opened.PatchFile(out, "/docs/other.txt", make([]byte, 2049))
+		require.ErrorIs(t, err, ErrInsufficientSpace)
+		after, err := os.ReadFile(out.Name())
+		require.NoError(t, err)
+		require.Equal(t, before, after)
+	})
+
+	t.Run("damaged record", func(t *testing.T) {
+		// The second record of the file is damaged after opening, nothing is written before it is found
+		entry, err := opened.findEntry("/docs/other.txt")
+		require.NoError(t, err)
+		records := opened.recordsForExtent(entry.Location, entry.Size)
+		require.Len(t, records, 2)
+		length := make([]byte, 1)
+		_, err = out.ReadAt(length, records[1].ObjectLocation)
+		require.NoError(t, err)
+		_, err = out.WriteAt([]byte{10}, records[1].ObjectLocation)
+		require.NoError(t, err)
+		defer out.WriteAt(length, records[1].ObjectLocation)
+
+		before, err := os.ReadFile(out.Name())
+		require.NoError(t, err)
+		require.ErrorContains(t, opened.PatchFile(out, "/docs/other.txt", []byte("new")), "too short")
+		after, err := os.ReadFile(out.Name())
+		require.NoError(t, err)
+		require.Equal(t, before, after)
+	})
+
+	t.Run("translated reader", func(t *testing.T) {
+		info, err := out.Stat()
+		require.NoError(t, err)
+		translated, err := Open(io.NewSectionReader(out, 0, info.Size()))
+		require.NoError(t, err)
+		require.ErrorContains(t, translated.PatchFile(out, "/DOCS/OTHER.TXT;1", []byte("new")), "sector translation")
+	})
+
+	t.Run("interleaved", func(t *testing.T) {
+		entry, err := opened.findEntry("/docs/other.txt")
+		require.NoError(t, err)
+		entry.DirectoryRecord().FileUnitSize = 1
+		defer func() { entry.DirectoryRecord().FileUnitSize = 0 }()
+		err = opened.PatchFile(out, "/docs/other.txt", []byte("new"))
+		require.ErrorContains(t, err, "interleaved")
+	})
+
+	t.Run("form 2", func(t *testing.T) {
+		entry, err := opened.findEntry("/docs/other.txt")
+		require.NoError(t, err)
+		entry.XA = &extensions.XAAttributes{Attributes: extensions.XA_MODE2_FORM2}
+		defer func() { entry.XA = nil }()
+		err = opened.PatchFile(out, "/docs/other.txt", []byte("new"))
+		require.ErrorContains(t, err, "Form 2")
+	})
+}
@@ -43,6 +43,30 @@ func NewPathTable(reader io.ReaderAt, location uint32, size int, source string,
 	return pt, nil
 }
 
+// NewPathTableFromRecords creates a path table for writing from the given records. The records are copied so the
+// same set can be used to build both the type L and type M tables.
+func NewPathTableFromRecords(records []*PathTableRecord, location uint32, source string, littleEndian bool) *PathTable {
+	pt := &PathTable{
+		source:         source,
+		littleEndian:   littleEndian,
+		ObjectLocation: int64(location),
+	}
+
+	size := 0
+	for _, record := range records {
+		r := *record
+		r.littleEndian = littleEndian
+		r.LengthOfDirectoryIdentifier = uint8(len(r.DirectoryIdentifier))
+		r.ObjectLocation = int64(location)*consts.ISO9660_SECTOR_SIZE + int64(size)
+		r.ObjectSize = uint32(r.RecordLength())
+		size += r.RecordLength()
+		pt.Records = append(pt.Records, &r)
+	}
+	pt.ObjectSize = uint32(size)
+
+	return pt
+}
+
 // PathTable represents a full path table, containing multiple records.
 type PathTable struct {
 	Records      []*PathTableRecord
@@ -176,6 +200,15 @@ func (ptr *PathTableRecord) GetObjects() []info.ImageObject {
 	return []info.ImageObject{ptr}
 }
 
+// RecordLength returns the length in bytes of the record including the padding field.
+func (ptr *PathTableRecord) RecordLength() int {
+	length := 8 + len(ptr.DirectoryIdentifier)
+	if len(ptr.DirectoryIdentifier)%2 != 0 {
+		length++
+	}
+	return length
+}
+
 // Marshal converts a single PathTableRecord into a byte slice.
 func (ptr *PathTableRecord) Marshal() ([]byte, error) {
 	dirIDBytes := []byte(ptr.DirectoryIdentifier)
@@ -0,0 +1,157 @@
+package iso9660
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/descriptor"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/parser"
+	"github.com/bgrewell/iso-kit/pkg/logging"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"io"
+	"time"
+)
+
+const (
+	// MAX_SESSION_GAP_SECTORS is how far past the end of a session the next session is searched for. It covers the
+	// lead-out and lead-in gaps left between sessions on recorded media.
+	MAX_SESSION_GAP_SECTORS = 16384
+)
+
+// sessionScanSectors is how many sectors are read at once while searching for the next session.
+const sessionScanSectors = 32
+
+// Session describes one session of a multisession image.
+type Session struct {
+	// Number of the session, starting at 0
+	Number int `json:"number"`
+	// StartSector is the first logical sector of the session. Its volume descriptor set starts 16 sectors later.
+	StartSector uint32 `json:"start_sector"`
+	// VolumeSpaceSize is the size of the volume up to the end of the session, in logical sectors
+	VolumeSpaceSize uint32 `json:"volume_space_size"`
+	// VolumeID of the session's primary volume descriptor
+	VolumeID string `json:"volume_id"`
+	// CreationTime and ModificationTime of the session's primary volume descriptor
+	CreationTime     time.Time `json:"creation_time"`
+	ModificationTime time.Time `json:"modification_time"`
+}
+
+// ListSessions discovers the sessions of an image. Every session records its own volume descriptor set describing the
+// whole volume up to its end, so each following session is searched for after the end of the previous one.
+//
+// Images grown in place on overwritable media, as done by xorriso and growisofs, also update the descriptors of the
+// first session to describe the newest tree. Only that tree is visible in such images.
+func ListSessions(r io.ReaderAt) ([]Session, error) {
+	var sessions []Session
+	start := uint32(0)
+	for {
+		session, err := readSession(r, len(sessions), start)
+		if err != nil {
+			if len(sessions) == 0 {
+				return nil, err
+			}
+			break
+		}
+		sessions = append(sessions, session)
+
+		next, ok := findNextSession(r, session.VolumeSpaceSize)
+		if !ok {
+			break
+		}
+		start = next
+	}
+	return sessions, nil
+}
+
+// findNextSession returns the start of the first session recorded at or after the given sector. A session is
+// recognized by a primary volume descriptor 16 sectors after its start describing a volume that extends past it.
+// Sectors are read in chunks and the search stops at the end of the image.
+func findNextSession(r io.ReaderAt, from uint32) (uint32, bool) {
+	buf := make([]byte, sessionScanSectors*consts.ISO9660_SECTOR_SIZE)
+	end := from + MAX_SESSION_GAP_SECTORS
+	for chunk := from; chunk < end; chunk += sessionScanSectors {
+		offset := (int64(chunk) + consts.ISO9660_SYSTEM_AREA_SECTORS) * consts.ISO9660_SECTOR_SIZE
+		n, err := r.ReadAt(buf, offset)
+		for i := 0; i*consts.ISO9660_SECTOR_SIZE+6 <= n && chunk+uint32(i) < end; i++ {
+			header := buf[i*consts.ISO9660_SECTOR_SIZE:]
+			if descriptor.VolumeDescriptorType(header[0]) != descriptor.TYPE_PRIMARY_DESCRIPTOR || string(header[1:6]) != "CD001" {
+				continue
+			}
+			sector := chunk + uint32(i)
+			if pvd, err := readSessionDescriptor(r, sector); err == nil && pvd.PrimaryVolumeDescriptorBody.VolumeSpaceSize > sector {
+				return sector, true
+			}
+		}
+		if err != nil {
+			return 0, false
+		}
+	}
+	return 0, false
+}
+
+// readSession describes the session with the given number starting at the given sector.
+func readSession(r io.ReaderAt, number int, start uint32) (Session, error) {
+	pvd, err := readSessionDescriptor(r, start)
+	if err != nil {
+		return Session{}, err
+	}
+	body := pvd.PrimaryVolumeDescriptorBody
+	return Session{
+		Number:           number,
+		StartSector:      start,
+		VolumeSpaceSize:  body.VolumeSpaceSize,
+		VolumeID:         body.VolumeIdentifier,
+		CreationTime:     body.VolumeCreationDateAndTime,
+		ModificationTime: body.VolumeModificationDateAndTime,
+	}, nil
+}
+
+// readSessionDescriptor reads the primary volume descriptor of the session starting at the given sector.
+func readSessionDescriptor(r io.ReaderAt, start uint32) (*descriptor.PrimaryVolumeDescriptor, error) {
+	p := parser.NewParser(r, &option.OpenOptions{Logger: logging.DefaultLogger()})
+	p.SetSessionStart(start)
+	return p.GetPrimaryVolumeDescriptor()
+}
+
+// selectSession returns the session chosen with option.WithSession. The first session is read from sector 16 without
+// searching the image for others, which is only done when a later session is asked for.
+func selectSession(r io.ReaderAt, number int) (Session, error) {
+	if number == 0 {
+		return readSession(r, 0, 0)
+	}
+	sessions, err := ListSessions(r)
+	if err != nil {
+		return Session{}, err
+	}
+	if number == option.SESSION_LATEST {
+		return sessions[len(sessions)-1], nil
+	}
+	if number < 0 || number >= len(sessions) {
+		return Session{}, fmt.Errorf("session %d not found, the image has %d sessions", number, len(sessions))
+	}
+	return sessions[number], nil
+}
+
+// Sessions returns the sessions of the opened image.
+func (iso *ISO9660) Sessions() ([]Session, error) {
+	if iso.isoReader == nil {
+		return nil, errors.New("sessions are only available for opened images")
+	}
+	return ListSessions(iso.isoReader)
+}
+
+// Session returns the session the image was opened with.
+func (iso *ISO9660) Session() Session {
+	return iso.session
+}
+
+// OpenSession opens another session of the image with the same options, so that each session's tree can be browsed
+// on its own.
+func (iso *ISO9660) OpenSession(number int) (*ISO9660, error) {
+	if iso.isoReader == nil {
+		return nil, errors.New("sessions are only available for opened images")
+	}
+	options := *iso.openOptions
+	options.Session = number
+	return Open(iso.isoReader, func(o *option.OpenOptions) { *o = options })
+}
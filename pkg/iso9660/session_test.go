@@ -0,0 +1,125 @@
+package iso9660
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/descriptor"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/fs"
+	"testing"
+)
+
+// countingReader counts the reads made through it and records their offsets.
+type countingReader struct {
+	io.ReaderAt
+	reads   int
+	offsets []int64
+}
+
+func (c *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	c.offsets = append(c.offsets, off)
+	return c.ReaderAt.ReadAt(p, off)
+}
+
+// twoSessionImage returns an image with two sessions. The first session's root is the /V1 directory and the second
+// session, recorded after a gap, has the /V2 directory as its root.
+func twoSessionImage(t *testing.T) ([]byte, uint32) {
+	img, err := Create("SESSION_0")
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/v1/old.txt", []byte("old")))
+	require.NoError(t, img.AddFile("/v2/old.txt", []byte("old")))
+	require.NoError(t, img.AddFile("/v2/new.txt", []byte("new")))
+	image := isotest.Bytes(t, img)
+
+	opened, err := Open(bytes.NewReader(image))
+	require.NoError(t, err)
+	v1, err := opened.findEntry("/V1")
+	require.NoError(t, err)
+	v2, err := opened.findEntry("/V2")
+	require.NoError(t, err)
+
+	setRoot := func(pvd []byte, volumeID string, dir uint32, size uint32, volumeSize uint32) {
+		copy(pvd[40:72], bytes.Repeat([]byte{' '}, 32))
+		copy(pvd[40:], volumeID)
+		binary.LittleEndian.PutUint32(pvd[80:], volumeSize)
+		binary.BigEndian.PutUint32(pvd[84:], volumeSize)
+		binary.LittleEndian.PutUint32(pvd[158:], dir)
+		binary.BigEndian.PutUint32(pvd[162:], dir)
+		binary.LittleEndian.PutUint32(pvd[166:], size)
+		binary.BigEndian.PutUint32(pvd[170:], size)
+	}
+
+	// The second session repeats the descriptor set, up to its terminator, after a gap following the first one
+	first := uint32(len(image) / consts.ISO9660_SECTOR_SIZE)
+	start := first + 100
+	setStart := consts.ISO9660_SYSTEM_AREA_SECTORS * consts.ISO9660_SECTOR_SIZE
+	setEnd := setStart
+	for image[setEnd] != byte(descriptor.TYPE_TERMINATOR_DESCRIPTOR) {
+		setEnd += consts.ISO9660_SECTOR_SIZE
+	}
+	set := image[setStart : setEnd+consts.ISO9660_SECTOR_SIZE]
+	total := start + consts.ISO9660_SYSTEM_AREA_SECTORS + uint32(len(set)/consts.ISO9660_SECTOR_SIZE)
+	image = append(image, make([]byte, int(total-first)*consts.ISO9660_SECTOR_SIZE)...)
+	second := image[(start+consts.ISO9660_SYSTEM_AREA_SECTORS)*consts.ISO9660_SECTOR_SIZE:]
+	copy(second, set)
+
+	setRoot(image[consts.ISO9660_SYSTEM_AREA_SECTORS*consts.ISO9660_SECTOR_SIZE:], "SESSION_0", v1.Location, v1.Size, first)
+	setRoot(second, "SESSION_1", v2.Location, v2.Size, total)
+	return image, start
+}
+
+func TestSessions(t *testing.T) {
+	image, start := twoSessionImage(t)
+
+	sessions, err := ListSessions(bytes.NewReader(image))
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, uint32(0), sessions[0].StartSector)
+	require.Equal(t, "SESSION_0", sessions[0].VolumeID)
+	require.Equal(t, 1, sessions[1].Number)
+	require.Equal(t, start, sessions[1].StartSector)
+	require.Equal(t, "SESSION_1", sessions[1].VolumeID)
+	require.Equal(t, uint32(len(image)/consts.ISO9660_SECTOR_SIZE), sessions[1].VolumeSpaceSize)
+
+	// The first session is opened by default without searching for the others
+	counter := &countingReader{ReaderAt: bytes.NewReader(image)}
+	first, err := Open(counter)
+	require.NoError(t, err)
+	require.Equal(t, "SESSION_0", first.GetVolumeID())
+	require.Equal(t, 0, first.Session().Number)
+	for _, offset := range counter.offsets {
+		require.Less(t, offset, int64(start)*consts.ISO9660_SECTOR_SIZE)
+	}
+
+	latest, err := Open(bytes.NewReader(image), option.WithSession(option.SESSION_LATEST))
+	require.NoError(t, err)
+	require.Equal(t, 1, latest.Session().Number)
+	data, err := fs.ReadFile(latest, "NEW.TXT;1")
+	require.NoError(t, err)
+	require.Equal(t, "new", string(data))
+
+	_, err = fs.Stat(first, "NEW.TXT;1")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	data, err = fs.ReadFile(first, "OLD.TXT;1")
+	require.NoError(t, err)
+	require.Equal(t, "old", string(data))
+
+	reopened, err := first.OpenSession(1)
+	require.NoError(t, err)
+	require.Equal(t, "SESSION_1", reopened.GetVolumeID())
+	_, err = Open(bytes.NewReader(image), option.WithSession(2))
+	require.ErrorContains(t, err, "session 2 not found")
+
+	// Trailing sectors are scanned in chunks rather than one read per sector
+	padded := append(image, make([]byte, 1000*consts.ISO9660_SECTOR_SIZE)...)
+	counter = &countingReader{ReaderAt: bytes.NewReader(padded)}
+	sessions, err = ListSessions(counter)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Less(t, counter.reads, 100)
+}
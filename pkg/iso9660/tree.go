@@ -0,0 +1,344 @@
+package iso9660
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// node is an entry in the staging tree of an image that is being created. The tree is turned into directory records,
+// path tables and extents when the image is packed.
+type node struct {
+	name      string
+	parent    *node
+	children  []*node
+	isDir     bool
+	isCatalog bool
+	symlink   string
+	mode      fs.FileMode
+	uid       uint32
+	gid       uint32
+	modTime   time.Time
+	hidden    bool
+	size      int64
+	source    io.ReaderAt
+
+	// Layout state assigned while packing
+	isoName          string
+	jolietName       string
+	enhancedName     string
+	location         uint32
+	dirSize          uint32
+	jolietLocation   uint32
+	jolietDirSize    uint32
+	enhancedLocation uint32
+	enhancedDirSize  uint32
+}
+
+// fileOverride holds options that are applied at pack time to every node whose path matches pattern.
+type fileOverride struct {
+	pattern string
+	opts    []option.FileOption
+}
+
+// path returns the absolute path of the node inside the image.
+func (n *node) path() string {
+	if n.parent == nil {
+		return "/"
+	}
+	return path.Join(n.parent.path(), n.name)
+}
+
+// child returns the direct child with the given name or nil.
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// remove detaches the node from its parent.
+func (n *node) remove() {
+	if n.parent == nil {
+		return
+	}
+	siblings := n.parent.children
+	for i, c := range siblings {
+		if c == n {
+			n.parent.children = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	n.parent = nil
+}
+
+// walk calls fn for the node and all of its descendants.
+func (n *node) walk(fn func(*node)) {
+	fn(n)
+	for _, c := range n.children {
+		c.walk(fn)
+	}
+}
+
+// apply sets the attributes from the file options on the node.
+func (n *node) apply(opts ...option.FileOption) {
+	fo := &option.FileOptions{}
+	for _, opt := range opts {
+		opt(fo)
+	}
+	if fo.Mode != nil {
+		n.mode = *fo.Mode & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	}
+	if fo.UID != nil {
+		n.uid = *fo.UID
+	}
+	if fo.GID != nil {
+		n.gid = *fo.GID
+	}
+	if fo.ModTime != nil {
+		n.modTime = *fo.ModTime
+	}
+	if fo.Hidden != nil {
+		n.hidden = *fo.Hidden
+	}
+}
+
+// hostFile reads the content of a grafted host file when the image is saved rather than when it is added. Save opens
+// it once to copy the whole file; ReadAt opens it for each read.
+type hostFile struct {
+	path string
+}
+
+func (h hostFile) Open() (io.ReadCloser, error) {
+	return os.Open(h.path)
+}
+
+func (h hostFile) ReadAt(p []byte, off int64) (int, error) {
+	f, err := os.Open(h.path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.ReadAt(p, off)
+}
+
+// splitImagePath cleans an image path and returns its components.
+func splitImagePath(p string) []string {
+	cleaned := path.Clean("/" + filepath.ToSlash(p))
+	if cleaned == "/" {
+		return nil
+	}
+	return strings.Split(strings.TrimPrefix(cleaned, "/"), "/")
+}
+
+// lookup returns the node at the given image path or nil.
+func (iso *ISO9660) lookup(p string) *node {
+	n := iso.root
+	for _, part := range splitImagePath(p) {
+		if n = n.child(part); n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// newNode creates a node with the default attributes of the image.
+func (iso *ISO9660) newNode(name string, isDir bool) *node {
+	mode := fs.FileMode(0o644)
+	if isDir {
+		mode = 0o755
+	}
+	return &node{
+		name:    name,
+		isDir:   isDir,
+		mode:    mode,
+		modTime: iso.createOptions.ModificationTime,
+	}
+}
+
+// mkdirAll returns the directory node for the given components, creating any missing directories.
+func (iso *ISO9660) mkdirAll(parts []string) (*node, error) {
+	dir := iso.root
+	for i, part := range parts {
+		next := dir.child(part)
+		if next == nil {
+			next = iso.newNode(part, true)
+			next.parent = dir
+			dir.children = append(dir.children, next)
+		} else if !next.isDir {
+			return nil, fmt.Errorf("%s is not a directory", "/"+strings.Join(parts[:i+1], "/"))
+		}
+		dir = next
+	}
+	return dir, nil
+}
+
+// insert places the node at the given image path. Existing files and symlinks are replaced, existing directories are
+// kept and updated with the attributes of the new node.
+func (iso *ISO9660) insert(p string, n *node) (*node, error) {
+	if iso.root == nil {
+		return nil, errors.New("entries can only be added to an image created with Create")
+	}
+
+	parts := splitImagePath(p)
+	if len(parts) == 0 {
+		if !n.isDir {
+			return nil, errors.New("the root of the image must be a directory")
+		}
+		return iso.root, nil
+	}
+	for _, part := range parts {
+		if part == ".." {
+			return nil, fmt.Errorf("invalid path %s", p)
+		}
+	}
+
+	parent, err := iso.mkdirAll(parts[:len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	n.name = parts[len(parts)-1]
+	if existing := parent.child(n.name); existing != nil {
+		if existing.isDir != n.isDir {
+			return nil, fmt.Errorf("%s already exists with a different type", p)
+		}
+		if existing.isDir {
+			return existing, nil
+		}
+		existing.remove()
+	}
+
+	n.parent = parent
+	parent.children = append(parent.children, n)
+	iso.isPacked = false
+
+	return n, nil
+}
+
+// AddFile adds a file with the given content to a newly created image.
+func (iso *ISO9660) AddFile(path string, data []byte) error {
+	return iso.AddFileFromReader(path, bytes.NewReader(data), int64(len(data)))
+}
+
+// AddFileFromReader adds a file of the given size to a newly created image. The content is read from r when the image
+// is saved.
+func (iso *ISO9660) AddFileFromReader(path string, r io.ReaderAt, size int64, opts ...option.FileOption) error {
+	if size < 0 || size > math.MaxUint32 {
+		return fmt.Errorf("file %s has unsupported size %d, files must be smaller than 4 GiB", path, size)
+	}
+
+	n := iso.newNode("", false)
+	n.source = r
+	n.size = size
+	n.apply(opts...)
+
+	_, err := iso.insert(path, n)
+	return err
+}
+
+// AddDirectory adds a directory, and any missing parents, to a newly created image.
+func (iso *ISO9660) AddDirectory(path string, opts ...option.FileOption) error {
+	n, err := iso.insert(path, iso.newNode("", true))
+	if err != nil {
+		return err
+	}
+	n.apply(opts...)
+	return nil
+}
+
+// AddSymlink adds a symbolic link to a newly created image. Symbolic links are only recorded when Rock Ridge is
+// enabled.
+func (iso *ISO9660) AddSymlink(path, target string, opts ...option.FileOption) error {
+	if target == "" {
+		return fmt.Errorf("symbolic link %s has an empty target", path)
+	}
+
+	n := iso.newNode("", false)
+	n.symlink = target
+	n.mode = 0o777
+	n.apply(opts...)
+
+	_, err := iso.insert(path, n)
+	return err
+}
+
+// AddLocalPath grafts a file or directory tree from the host filesystem into a newly created image at target. File
+// content is read when the image is saved. Modes and modification times are taken from the host.
+func (iso *ISO9660) AddLocalPath(source, target string) error {
+	return filepath.WalkDir(source, func(hostPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(source, hostPath)
+		if err != nil {
+			return err
+		}
+		imagePath := path.Join("/", filepath.ToSlash(target), filepath.ToSlash(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		attrs := []option.FileOption{
+			option.WithFileMode(info.Mode()),
+			option.WithModTime(info.ModTime()),
+		}
+
+		switch {
+		case info.IsDir():
+			return iso.AddDirectory(imagePath, attrs...)
+		case info.Mode()&fs.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(hostPath)
+			if err != nil {
+				return err
+			}
+			return iso.AddSymlink(imagePath, linkTarget, attrs...)
+		case info.Mode().IsRegular():
+			return iso.AddFileFromReader(imagePath, hostFile{path: hostPath}, info.Size(), attrs...)
+		default:
+			iso.logger.Info("Skipping unsupported file type", "path", hostPath, "mode", info.Mode().String())
+			return nil
+		}
+	})
+}
+
+// SetFileOptions records options that are applied when the image is packed to every entry whose image path matches
+// pattern (using path.Match syntax). Later calls take precedence over earlier ones.
+func (iso *ISO9660) SetFileOptions(pattern string, opts ...option.FileOption) error {
+	if _, err := path.Match(pattern, "/"); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	iso.overrides = append(iso.overrides, fileOverride{pattern: pattern, opts: opts})
+	iso.isPacked = false
+	return nil
+}
+
+// RemoveFile removes a file, symlink or directory tree from a newly created image.
+func (iso *ISO9660) RemoveFile(path string) error {
+	if iso.root == nil {
+		return errors.New("entries can only be removed from an image created with Create")
+	}
+	n := iso.lookup(path)
+	if n == nil {
+		return fmt.Errorf("%s: %w", path, fs.ErrNotExist)
+	}
+	if n == iso.root {
+		return errors.New("the root directory cannot be removed")
+	}
+	n.remove()
+	iso.isPacked = false
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package isotest provides helpers for tests that build images and read them back.
+package isotest
+
+import (
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Saver is an image that can be written out, such as an image returned by iso9660.Create or by the seed builders.
+type Saver interface {
+	Save(writer io.WriterAt) error
+}
+
+// File saves img to a file in a temporary directory of the test. The file is returned open for reading and writing
+// and is closed when the test ends.
+func File(t testing.TB, img Saver) *os.File {
+	t.Helper()
+	f, err := os.Create(filepath.Join(t.TempDir(), "image.iso"))
+	require.NoError(t, err)
+	t.Cleanup(func() { f.Close() })
+	require.NoError(t, img.Save(f))
+	return f
+}
+
+// Bytes saves img and returns the content of the image.
+func Bytes(t testing.TB, img Saver) []byte {
+	t.Helper()
+	data, err := os.ReadFile(File(t, img).Name())
+	require.NoError(t, err)
+	return data
+}
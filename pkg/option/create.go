@@ -1,6 +1,10 @@
 package option
 
-import "github.com/bgrewell/iso-kit/pkg/logging"
+import (
+	"github.com/bgrewell/iso-kit/pkg/iso9660/boot"
+	"github.com/bgrewell/iso-kit/pkg/logging"
+	"time"
+)
 
 // ISOType represents the type of ISO image
 type ISOType int
@@ -10,12 +14,63 @@ const (
 	ISO_TYPE_UDF
 )
 
+// BootEntry describes an El Torito boot image that should be referenced from the boot catalog of a newly created
+// image. The first entry becomes the initial/default entry and any additional entries are written as section entries.
+type BootEntry struct {
+	// ImagePath is the path of the boot image inside the ISO (e.g. "/isolinux/isolinux.bin").
+	ImagePath string
+	// Platform is the platform the boot image targets.
+	Platform boot.Platform
+	// Emulation is the emulation mode used when booting the image.
+	Emulation boot.Emulation
+	// LoadSegment is the segment the BIOS should load the image into. Zero means the default of 0x7C0.
+	LoadSegment uint16
+	// LoadSize is the number of 512-byte virtual sectors to load. Zero means the whole image is loaded.
+	LoadSize uint16
+	// BootInfoTable patches the 56-byte boot information table into the image at offset 8 (like -boot-info-table).
+	BootInfoTable bool
+	// NoBoot marks the entry as not bootable.
+	NoBoot bool
+}
+
+// HybridOptions describes the MBR that is written into the system area so the image can also be booted from a disk.
+type HybridOptions struct {
+	// MBR holds the boot code that is copied to the start of the system area (e.g. isohdpfx.bin). Up to 432 bytes
+	// are used so the LBA of the BIOS boot image, the disk signature and the partition table can be filled in.
+	MBR []byte
+	// PartitionType is the MBR partition type of the partition that covers the whole ISO.
+	PartitionType byte
+	// EFIPartition adds a second partition (type 0xEF) that points at the first EFI El Torito boot image.
+	EFIPartition bool
+	// DiskSignature is the 32-bit MBR disk signature.
+	DiskSignature uint32
+}
+
 type CreateOptions struct {
-	ISOType       ISOType
-	Preparer      string
-	RootDir       string
-	JolietEnabled bool
-	Logger        *logging.Logger
+	ISOType          ISOType
+	VolumeID         string
+	Preparer         string
+	RootDir          string
+	JolietEnabled    bool
+	EnhancedEnabled  bool
+	RockRidgeEnabled bool
+	InterchangeLevel int
+	SystemID         string
+	VolumeSetID      string
+	PublisherID      string
+	ApplicationID    string
+	CopyrightFileID  string
+	AbstractFileID   string
+	BibliographicID  string
+	CreationTime     time.Time
+	ModificationTime time.Time
+	ExpirationTime   time.Time
+	EffectiveTime    time.Time
+	BootEntries      []BootEntry
+	BootCatalog      string
+	HideBootCatalog  bool
+	Hybrid           *HybridOptions
+	Logger           *logging.Logger
 }
 
 type CreateOption func(*CreateOptions)
@@ -26,6 +81,14 @@ func WithISOType(isoType ISOType) CreateOption {
 	}
 }
 
+// WithVolumeID sets the volume identifier of images whose name is not given directly, such as images written with
+// iso.NewWriter. A name passed to Create takes precedence.
+func WithVolumeID(volumeID string) CreateOption {
+	return func(o *CreateOptions) {
+		o.VolumeID = volumeID
+	}
+}
+
 func WithPreparerID(preparer string) CreateOption {
 	return func(o *CreateOptions) {
 		o.Preparer = preparer
@@ -44,6 +107,105 @@ func WithJolietEnabled(jolietEnabled bool) CreateOption {
 	}
 }
 
+// WithEnhancedEnabled records an ISO 9660:1999 enhanced volume descriptor and a matching directory tree in which the
+// original names are kept, up to 207 bytes and without version numbers.
+func WithEnhancedEnabled(enhancedEnabled bool) CreateOption {
+	return func(o *CreateOptions) {
+		o.EnhancedEnabled = enhancedEnabled
+	}
+}
+
+// WithEnableRockRidge controls whether Rock Ridge entries are recorded in the primary directory tree. It is named
+// differently from WithRockRidgeEnabled for the same reason as WithEnableLogging.
+func WithEnableRockRidge(rockRidgeEnabled bool) CreateOption {
+	return func(o *CreateOptions) {
+		o.RockRidgeEnabled = rockRidgeEnabled
+	}
+}
+
+// WithInterchangeLevel sets the ISO9660 interchange level (1-3) used for the names in the primary directory tree.
+func WithInterchangeLevel(level int) CreateOption {
+	return func(o *CreateOptions) {
+		o.InterchangeLevel = level
+	}
+}
+
+func WithSystemID(systemID string) CreateOption {
+	return func(o *CreateOptions) {
+		o.SystemID = systemID
+	}
+}
+
+func WithVolumeSetID(volumeSetID string) CreateOption {
+	return func(o *CreateOptions) {
+		o.VolumeSetID = volumeSetID
+	}
+}
+
+func WithPublisherID(publisher string) CreateOption {
+	return func(o *CreateOptions) {
+		o.PublisherID = publisher
+	}
+}
+
+func WithApplicationID(application string) CreateOption {
+	return func(o *CreateOptions) {
+		o.ApplicationID = application
+	}
+}
+
+func WithCopyrightFileID(copyright string) CreateOption {
+	return func(o *CreateOptions) {
+		o.CopyrightFileID = copyright
+	}
+}
+
+func WithAbstractFileID(abstract string) CreateOption {
+	return func(o *CreateOptions) {
+		o.AbstractFileID = abstract
+	}
+}
+
+func WithBibliographicFileID(bibliographic string) CreateOption {
+	return func(o *CreateOptions) {
+		o.BibliographicID = bibliographic
+	}
+}
+
+// WithVolumeTimes sets the creation, modification, expiration and effective times recorded in the volume
+// descriptors. Zero values are recorded as "not specified", except for creation and modification which default to now.
+func WithVolumeTimes(creation, modification, expiration, effective time.Time) CreateOption {
+	return func(o *CreateOptions) {
+		o.CreationTime = creation
+		o.ModificationTime = modification
+		o.ExpirationTime = expiration
+		o.EffectiveTime = effective
+	}
+}
+
+// WithBootEntry adds an El Torito boot entry. Entries are written to the boot catalog in the order they are added.
+func WithBootEntry(entry BootEntry) CreateOption {
+	return func(o *CreateOptions) {
+		o.BootEntries = append(o.BootEntries, entry)
+	}
+}
+
+// WithBootCatalog sets the path of the El Torito boot catalog inside the ISO and whether it is hidden from the
+// directory tree.
+func WithBootCatalog(path string, hidden bool) CreateOption {
+	return func(o *CreateOptions) {
+		o.BootCatalog = path
+		o.HideBootCatalog = hidden
+	}
+}
+
+// WithHybrid writes an MBR into the system area so the image can also be booted as a disk.
+func WithHybrid(hybrid HybridOptions) CreateOption {
+	return func(o *CreateOptions) {
+		o.Hybrid = &hybrid
+	}
+}
+
 // WithEnableLogging is a temp fix for the fact that we have separate options with helper functions in the same package
 func WithEnableLogging(logger *logging.Logger) CreateOption {
 	return func(o *CreateOptions) {
@@ -0,0 +1,48 @@
+package option
+
+import (
+	"io/fs"
+	"time"
+)
+
+// FileOptions describes the attributes recorded for a file, directory or symlink added to a new image. Fields that are
+// nil are left at their defaults (or at the values taken from the host when grafting a local path).
+type FileOptions struct {
+	Mode    *fs.FileMode
+	UID     *uint32
+	GID     *uint32
+	ModTime *time.Time
+	Hidden  *bool
+}
+
+type FileOption func(*FileOptions)
+
+// WithFileMode sets the permission bits recorded in the Rock Ridge PX entry. Only the permission and special bits are
+// used, the file type is always derived from the entry.
+func WithFileMode(mode fs.FileMode) FileOption {
+	return func(o *FileOptions) {
+		o.Mode = &mode
+	}
+}
+
+// WithOwner sets the owner and group recorded in the Rock Ridge PX entry.
+func WithOwner(uid, gid uint32) FileOption {
+	return func(o *FileOptions) {
+		o.UID = &uid
+		o.GID = &gid
+	}
+}
+
+// WithModTime sets the modification time recorded in the directory record and the Rock Ridge TF entry.
+func WithModTime(modTime time.Time) FileOption {
+	return func(o *FileOptions) {
+		o.ModTime = &modTime
+	}
+}
+
+// WithHidden sets the existence (hidden) flag of the directory records for the entry.
+func WithHidden(hidden bool) FileOption {
+	return func(o *FileOptions) {
+		o.Hidden = &hidden
+	}
+}
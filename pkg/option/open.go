@@ -1,7 +1,24 @@
 package option
 
 import (
+	"github.com/bgrewell/iso-kit/pkg/filesystem"
 	"github.com/bgrewell/iso-kit/pkg/logging"
+	"net/http"
+)
+
+const (
+	// SESSION_LATEST selects the last session of a multisession image
+	SESSION_LATEST = -1
+)
+
+// Filesystem selects the filesystem presented by images recording several, such as UDF bridge images.
+type Filesystem int
+
+const (
+	// FILESYSTEM_AUTO presents the ISO9660 filesystem when the image has one and the UDF filesystem otherwise (default)
+	FILESYSTEM_AUTO Filesystem = iota
+	FILESYSTEM_ISO9660
+	FILESYSTEM_UDF
 )
 
 type ExtractionProgressCallback func(
@@ -17,10 +34,23 @@ type OpenOptions struct {
 	ReadOnly                   bool
 	PreloadDir                 bool
 	PreferJoliet               bool
+	PreferEnhanced             bool
+	MergedView                 bool
 	StripVersionInfo           bool
 	RockRidgeEnabled           bool
 	ElToritoEnabled            bool
 	BootFileExtractLocation    string
+	SymlinkPolicy              filesystem.SymlinkPolicy
+	SpecialFilePolicy          filesystem.SpecialFilePolicy
+	SpecialFileManifest        string
+	HardLinksEnabled           bool
+	Session                    int
+	DirectoryCacheSize         int
+	Filesystem                 Filesystem
+	TolerantParsing            bool
+	HTTPClient                 *http.Client
+	HTTPBlockSize              int
+	HTTPCacheBlocks            int
 	ExtractionProgressCallback ExtractionProgressCallback
 	Logger                     *logging.Logger
 }
@@ -52,6 +82,9 @@ func WithLogger(logger *logging.Logger) OpenOption {
 	}
 }
 
+// WithParseOnOpen controls whether Open reads every directory of the image. When disabled only the volume descriptors
+// and path tables are read up front; Stat, Open and ReadDir read just the directories on the path they are given and
+// listing or extracting the image reads the rest on first use. Enabled by default.
 func WithParseOnOpen(parseOnOpen bool) OpenOption {
 	return func(o *OpenOptions) {
 		o.ParseOnOpen = parseOnOpen
@@ -64,6 +97,9 @@ func WithReadOnly(readOnly bool) OpenOption {
 	}
 }
 
+// WithPreloadDir controls whether Open also walks the directory records of every hierarchy into the volume
+// descriptors, which patching files and describing the image layout need. When disabled they are walked on first use.
+// It has no effect when parsing on open is disabled. Enabled by default.
 func WithPreloadDir(preloadDir bool) OpenOption {
 	return func(o *OpenOptions) {
 		o.PreloadDir = preloadDir
@@ -82,6 +118,24 @@ func WithPreferJoliet(preferJoliet bool) OpenOption {
 	}
 }
 
+// WithPreferEnhanced selects the ISO 9660:1999 enhanced hierarchy, described by a version 2 supplementary volume
+// descriptor, when the image has one. It takes precedence over WithPreferJoliet.
+func WithPreferEnhanced(preferEnhanced bool) OpenOption {
+	return func(o *OpenOptions) {
+		o.PreferEnhanced = preferEnhanced
+	}
+}
+
+// WithMergedView combines the primary hierarchy with the Joliet hierarchy, or the one selected with WithPreferEnhanced
+// or WithPreferJoliet, by matching their records on extent location. Each entry gets the Rock Ridge name, or else the
+// supplementary name, together with the Rock Ridge attributes of the primary record. Entries found in only one
+// hierarchy are listed by the MergeReport method of the image.
+func WithMergedView(mergedView bool) OpenOption {
+	return func(o *OpenOptions) {
+		o.MergedView = mergedView
+	}
+}
+
 func WithRockRidgeEnabled(rockRidgeEnabled bool) OpenOption {
 	return func(o *OpenOptions) {
 		o.RockRidgeEnabled = rockRidgeEnabled
@@ -93,3 +147,86 @@ func WithElToritoEnabled(elToritoEnabled bool) OpenOption {
 		o.ElToritoEnabled = elToritoEnabled
 	}
 }
+
+// WithSymlinkPolicy controls how Extract handles Rock Ridge symbolic links with absolute targets or targets that
+// escape the output directory. The default, filesystem.SYMLINK_POLICY_SKIP, skips them.
+func WithSymlinkPolicy(policy filesystem.SymlinkPolicy) OpenOption {
+	return func(o *OpenOptions) {
+		o.SymlinkPolicy = policy
+	}
+}
+
+// WithSpecialFilePolicy controls how Extract handles device nodes, FIFOs and sockets. The default,
+// filesystem.SPECIAL_FILE_POLICY_SKIP, skips them.
+func WithSpecialFilePolicy(policy filesystem.SpecialFilePolicy) OpenOption {
+	return func(o *OpenOptions) {
+		o.SpecialFilePolicy = policy
+	}
+}
+
+// WithSpecialFileManifest makes Extract write the special files it skipped to the file at path, one per line in the
+// Linux gen_init_cpio format, so they can be recreated later.
+func WithSpecialFileManifest(path string) OpenOption {
+	return func(o *OpenOptions) {
+		o.SpecialFileManifest = path
+	}
+}
+
+// WithHardLinksEnabled controls whether Extract recreates hard links, writing the data of files that share a Rock
+// Ridge serial number or an extent once and linking the others to it. Enabled by default.
+func WithHardLinksEnabled(hardLinksEnabled bool) OpenOption {
+	return func(o *OpenOptions) {
+		o.HardLinksEnabled = hardLinksEnabled
+	}
+}
+
+// WithDirectoryCacheSize sets the number of directory sectors kept in memory when parsing on open is disabled. The
+// least recently used sectors are dropped first.
+func WithDirectoryCacheSize(sectors int) OpenOption {
+	return func(o *OpenOptions) {
+		o.DirectoryCacheSize = sectors
+	}
+}
+
+// WithSession selects the session of a multisession image to read, like the session=N mount option on Linux.
+// Sessions are numbered from 0 and SESSION_LATEST selects the last one. The default, session 0, is read from sector 16
+// without searching for other sessions, which is done past the end of each session when another one is selected.
+func WithSession(session int) OpenOption {
+	return func(o *OpenOptions) {
+		o.Session = session
+	}
+}
+
+// WithTolerantParsing makes Open skip damaged directory records, unreadable directory sectors and damaged descriptors
+// other than the primary volume descriptor instead of failing, so the readable part of a damaged image can still be
+// listed. Extract then writes zeros in place of unreadable file sectors and carries on with the next file. Each
+// skipped structure is recorded with its location and returned by the Problems method of the image.
+func WithTolerantParsing(tolerant bool) OpenOption {
+	return func(o *OpenOptions) {
+		o.TolerantParsing = tolerant
+	}
+}
+
+// WithFilesystem selects the filesystem the root package Open functions present. Opening fails when the image does not
+// record the selected filesystem.
+func WithFilesystem(filesystem Filesystem) OpenOption {
+	return func(o *OpenOptions) {
+		o.Filesystem = filesystem
+	}
+}
+
+// WithHTTPClient sets the client OpenURL sends its Range requests with, http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) OpenOption {
+	return func(o *OpenOptions) {
+		o.HTTPClient = client
+	}
+}
+
+// WithHTTPCache sets the size in bytes of the blocks OpenURL fetches, rounded up to a multiple of the sector size, and
+// the number of blocks kept in memory. Zero values keep the defaults of httprange.NewReader.
+func WithHTTPCache(blockSize, blocks int) OpenOption {
+	return func(o *OpenOptions) {
+		o.HTTPBlockSize = blockSize
+		o.HTTPCacheBlocks = blocks
+	}
+}
@@ -0,0 +1,186 @@
+package probe
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// Size of the blocks MBR and GPT partition tables address
+	diskBlockSize = 512
+	// Offset of the MBR partition table and size of its entries
+	mbrTableOffset = 446
+	mbrEntrySize   = 16
+	// Signature of the GPT header, recorded in the block after the MBR
+	gptSignature = "EFI PART"
+	// Limits on the GPT entries read, the usual table holding 128 entries of 128 bytes. Entry sizes are multiples of
+	// 128 bytes.
+	gptMaxEntries   = 1024
+	gptMinEntrySize = 128
+	gptMaxEntrySize = 4096
+	gptMaxTableSize = 1024 * 1024
+	// Signatures of the Apple driver descriptor map and partition map entries
+	apmDriverSignature    = "ER"
+	apmPartitionSignature = "PM"
+	// Limit on the number of partition map entries read
+	apmMaxEntries = 64
+)
+
+// PartitionScheme is the partition table format a partition was found in.
+type PartitionScheme string
+
+const (
+	PARTITION_SCHEME_MBR PartitionScheme = "MBR"
+	PARTITION_SCHEME_GPT PartitionScheme = "GPT"
+	PARTITION_SCHEME_APM PartitionScheme = "APM"
+)
+
+// Partition is an entry of a partition table found in the system area of an image.
+type Partition struct {
+	Scheme PartitionScheme
+	// Number of the partition in its table, from 1
+	Number int
+	// Partition type: the type byte of MBR partitions as "0xEF", the type GUID of GPT partitions and the type string
+	// of APM partitions
+	Type string
+	// Name of GPT and APM partitions
+	Name string
+	// Bootable is set for active MBR partitions
+	Bootable bool
+	// Offset and size of the partition in bytes
+	Start int64
+	Size  int64
+	// Appended is set when the partition starts after the end of the ISO9660 volume
+	Appended bool
+}
+
+// probePartitions reads the MBR, GPT and APM partition tables found at the start of the image.
+func (r *Result) probePartitions(reader io.ReaderAt) {
+	r.probeMBR(reader)
+	r.probeGPT(reader)
+	r.probeAPM(reader)
+}
+
+// probeMBR reads the partition table of a master boot record in the first block.
+func (r *Result) probeMBR(reader io.ReaderAt) {
+	mbr := make([]byte, diskBlockSize)
+	if _, err := reader.ReadAt(mbr, 0); err != nil || mbr[510] != 0x55 || mbr[511] != 0xAA {
+		return
+	}
+	var partitions []Partition
+	for i := 0; i < 4; i++ {
+		entry := mbr[mbrTableOffset+i*mbrEntrySize : mbrTableOffset+(i+1)*mbrEntrySize]
+		// Boot indicators other than inactive and active mean the block is not a partition table
+		if entry[0] != 0x00 && entry[0] != 0x80 {
+			return
+		}
+		if entry[4] == 0 {
+			continue
+		}
+		partitions = append(partitions, Partition{
+			Scheme:   PARTITION_SCHEME_MBR,
+			Number:   i + 1,
+			Type:     fmt.Sprintf("0x%02X", entry[4]),
+			Bootable: entry[0] == 0x80,
+			Start:    int64(binary.LittleEndian.Uint32(entry[8:12])) * diskBlockSize,
+			Size:     int64(binary.LittleEndian.Uint32(entry[12:16])) * diskBlockSize,
+		})
+	}
+	if len(partitions) > 0 {
+		r.MBR = true
+		r.Partitions = append(r.Partitions, partitions...)
+	}
+}
+
+// probeGPT reads the GUID partition table whose header is in the second block.
+func (r *Result) probeGPT(reader io.ReaderAt) {
+	header := make([]byte, 92)
+	if _, err := reader.ReadAt(header, diskBlockSize); err != nil || string(header[0:8]) != gptSignature {
+		return
+	}
+	tableLBA := int64(binary.LittleEndian.Uint64(header[72:80]))
+	count := int(binary.LittleEndian.Uint32(header[80:84]))
+	entrySize := int(binary.LittleEndian.Uint32(header[84:88]))
+	if count > gptMaxEntries || entrySize < gptMinEntrySize || entrySize > gptMaxEntrySize ||
+		entrySize%gptMinEntrySize != 0 || count*entrySize > gptMaxTableSize {
+		return
+	}
+	table := make([]byte, count*entrySize)
+	if _, err := reader.ReadAt(table, tableLBA*diskBlockSize); err != nil {
+		return
+	}
+	r.GPT = true
+	for i := 0; i < count; i++ {
+		entry := table[i*entrySize : (i+1)*entrySize]
+		if isZero(entry[0:16]) {
+			continue
+		}
+		first := int64(binary.LittleEndian.Uint64(entry[32:40]))
+		last := int64(binary.LittleEndian.Uint64(entry[40:48]))
+		var units []uint16
+		for j := 56; j+1 < 128; j += 2 {
+			unit := binary.LittleEndian.Uint16(entry[j : j+2])
+			if unit == 0 {
+				break
+			}
+			units = append(units, unit)
+		}
+		r.Partitions = append(r.Partitions, Partition{
+			Scheme: PARTITION_SCHEME_GPT,
+			Number: i + 1,
+			Type:   guid(entry[0:16]),
+			Name:   string(utf16.Decode(units)),
+			Start:  first * diskBlockSize,
+			Size:   (last - first + 1) * diskBlockSize,
+		})
+	}
+}
+
+// probeAPM reads the Apple partition map following the driver descriptor map in the first block, in blocks of the size
+// the descriptor records.
+func (r *Result) probeAPM(reader io.ReaderAt) {
+	ddm := make([]byte, 4)
+	if _, err := reader.ReadAt(ddm, 0); err != nil || string(ddm[0:2]) != apmDriverSignature {
+		return
+	}
+	blockSize := int64(binary.BigEndian.Uint16(ddm[2:4]))
+	if blockSize == 0 {
+		blockSize = diskBlockSize
+	}
+	entry := make([]byte, 80)
+	count := 1
+	for i := 1; i <= count && i <= apmMaxEntries; i++ {
+		if _, err := reader.ReadAt(entry, int64(i)*blockSize); err != nil || string(entry[0:2]) != apmPartitionSignature {
+			return
+		}
+		count = int(binary.BigEndian.Uint32(entry[4:8]))
+		r.APM = true
+		r.Partitions = append(r.Partitions, Partition{
+			Scheme: PARTITION_SCHEME_APM,
+			Number: i,
+			Type:   strings.TrimRight(string(entry[48:80]), "\x00"),
+			Name:   strings.TrimRight(string(entry[16:48]), "\x00"),
+			Start:  int64(binary.BigEndian.Uint32(entry[8:12])) * blockSize,
+			Size:   int64(binary.BigEndian.Uint32(entry[12:16])) * blockSize,
+		})
+	}
+}
+
+// guid formats a GUID recorded with its first three fields little-endian.
+func guid(b []byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X", binary.LittleEndian.Uint32(b[0:4]), binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]), b[8:10], b[10:16])
+}
+
+// isZero returns true if every byte of b is zero.
+func isZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
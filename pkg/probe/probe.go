@@ -0,0 +1,259 @@
+// Package probe detects the filesystems, boot structures and partition tables recorded in an image of 2048-byte
+// sectors without opening any of them, so an image holding several of them, like a UDF bridge image or a hybrid image
+// bootable from a disk, can be described completely.
+package probe
+
+import (
+	"encoding/binary"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/boot"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/descriptor"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/extensions"
+	"io"
+	"slices"
+	"strings"
+)
+
+const (
+	// UDF descriptor tag identifier of the anchor volume descriptor pointer
+	udfAnchorTag = 2
+	// Descriptors of the volume recognition sequence that are not ISO9660 or UDF descriptors
+	bootDescriptorIdentifier = "BOOT2"
+	cdwDescriptorIdentifier  = "CDW02"
+)
+
+// Container is the format of the image file the probed sectors were read from.
+type Container string
+
+const (
+	CONTAINER_NONE    Container = ""
+	CONTAINER_CSO     Container = "CSO"
+	CONTAINER_ZSO     Container = "ZSO"
+	CONTAINER_NRG     Container = "NRG"
+	CONTAINER_RAW     Container = "RAW"
+	CONTAINER_BIN_CUE Container = "BIN/CUE"
+	CONTAINER_MDF_MDS Container = "MDF/MDS"
+)
+
+// Identifiers recorded in the ER entry of the root directory by the Rock Ridge versions
+var rockRidgeIdentifiers = []string{extensions.ROCK_RIDGE_IDENTIFIER, "IEEE_P1282", "IEEE_1282"}
+
+// VolumeDescriptor is a descriptor of the volume recognition sequence starting at sector 16.
+type VolumeDescriptor struct {
+	// Sector of the descriptor
+	Sector int64
+	// Standard identifier, such as CD001, BEA01, NSR02, NSR03 or TEA01
+	Identifier string
+	// Descriptor type, only meaningful for ISO9660 descriptors
+	Type descriptor.VolumeDescriptorType
+	// Descriptor version
+	Version uint8
+}
+
+// Result describes the structures found in an image.
+type Result struct {
+	// Container format the sectors were read from, CONTAINER_NONE for images of 2048-byte sectors
+	Container Container
+	// Size of the image in bytes, once read as 2048-byte sectors
+	Size int64
+	// Descriptors of the volume recognition sequence, ISO9660 and UDF alike
+	VolumeDescriptors []VolumeDescriptor
+
+	// ISO9660 is set when the image has a primary volume descriptor
+	ISO9660 bool
+	// Size of the ISO9660 volume in sectors, as recorded in the primary volume descriptor
+	VolumeSize uint32
+	// Joliet is set when a supplementary volume descriptor carries a Joliet escape sequence, of level JolietLevel
+	Joliet      bool
+	JolietLevel int
+	// Enhanced is set when the image has an ISO 9660:1999 enhanced volume descriptor
+	Enhanced bool
+	// RockRidge is set when the root directory of the primary hierarchy carries Rock Ridge entries
+	RockRidge bool
+	// ElTorito is set when the image has an El Torito boot record, pointing at the boot catalog at BootCatalog
+	ElTorito    bool
+	BootCatalog uint32
+	// Entries of the boot catalog
+	BootEntries []*boot.ElToritoEntry
+
+	// UDFStandard is the NSR02 or NSR03 descriptor identifier found in the extended area of the volume recognition
+	// sequence, naming the ECMA-167 edition of a UDF volume
+	UDFStandard string
+	// Sectors holding a valid UDF anchor volume descriptor pointer, out of sector 256, N-256 and N-1
+	Anchors []int64
+
+	// Partition schemes found in the system area
+	MBR bool
+	GPT bool
+	APM bool
+	// Partitions of the partition tables found, in table order
+	Partitions []Partition
+}
+
+// HasUDF returns true if the image has a UDF volume recognition sequence and an anchor volume descriptor pointer.
+func (r *Result) HasUDF() bool {
+	return r.UDFStandard != "" && len(r.Anchors) > 0
+}
+
+// AppendedPartitions returns the partitions starting after the end of the ISO9660 volume, such as the EFI system
+// partitions appended to hybrid images.
+func (r *Result) AppendedPartitions() []Partition {
+	var appended []Partition
+	for _, p := range r.Partitions {
+		if p.Appended {
+			appended = append(appended, p)
+		}
+	}
+	return appended
+}
+
+// Probe detects the structures recorded in the image of the given size read from r. Structures that cannot be read are
+// reported as absent.
+func Probe(r io.ReaderAt, size int64) *Result {
+	res := &Result{Size: size}
+	res.probeVolumeRecognition(r)
+	res.probeAnchors(r)
+	res.probePartitions(r)
+	if res.ISO9660 {
+		end := int64(res.VolumeSize) * consts.ISO9660_SECTOR_SIZE
+		for i := range res.Partitions {
+			res.Partitions[i].Appended = res.Partitions[i].Start >= end
+		}
+	}
+	return res
+}
+
+// probeVolumeRecognition walks the descriptors starting at sector 16: the ISO9660 volume descriptor set, followed by
+// the UDF extended area from BEA01 to TEA01 on UDF volumes.
+func (r *Result) probeVolumeRecognition(reader io.ReaderAt) {
+	sector := make([]byte, consts.ISO9660_SECTOR_SIZE)
+	extended := false
+	for lba := int64(consts.ISO9660_SYSTEM_AREA_SECTORS); ; lba++ {
+		if _, err := reader.ReadAt(sector, lba*consts.ISO9660_SECTOR_SIZE); err != nil {
+			return
+		}
+		vd := VolumeDescriptor{
+			Sector:     lba,
+			Identifier: string(sector[1:6]),
+			Type:       descriptor.VolumeDescriptorType(sector[0]),
+			Version:    sector[6],
+		}
+		switch vd.Identifier {
+		case consts.ISO9660_STD_IDENTIFIER:
+			r.probeISO9660Descriptor(reader, vd, sector)
+		case consts.UDF_STD_IDENTIFIER:
+			extended = true
+		case consts.UDF_NSR02_IDENTIFIER, consts.UDF_NSR03_IDENTIFIER:
+			if extended {
+				r.UDFStandard = vd.Identifier
+			}
+		case consts.UDF_TEA_IDENTIFIER, bootDescriptorIdentifier, cdwDescriptorIdentifier:
+		default:
+			return
+		}
+		r.VolumeDescriptors = append(r.VolumeDescriptors, vd)
+		if vd.Identifier == consts.UDF_TEA_IDENTIFIER {
+			return
+		}
+	}
+}
+
+// probeISO9660Descriptor records what an ISO9660 volume descriptor tells about the image.
+func (r *Result) probeISO9660Descriptor(reader io.ReaderAt, vd VolumeDescriptor, data []byte) {
+	switch vd.Type {
+	case descriptor.TYPE_PRIMARY_DESCRIPTOR:
+		if r.ISO9660 {
+			return
+		}
+		r.ISO9660 = true
+		r.VolumeSize = binary.LittleEndian.Uint32(data[80:84])
+		r.RockRidge = hasRockRidge(reader, binary.LittleEndian.Uint32(data[158:162]))
+	case descriptor.TYPE_SUPPLEMENTARY_DESCRIPTOR:
+		if vd.Version == consts.ISO9660_ENHANCED_VOLUME_DESC_VERSION {
+			r.Enhanced = true
+			return
+		}
+		escapes := string(data[88:120])
+		for level, escape := range []string{consts.JOLIET_LEVEL_1_ESCAPE, consts.JOLIET_LEVEL_2_ESCAPE, consts.JOLIET_LEVEL_3_ESCAPE} {
+			if strings.Contains(escapes, escape) {
+				r.Joliet = true
+				r.JolietLevel = max(r.JolietLevel, level+1)
+			}
+		}
+	case descriptor.TYPE_BOOT_RECORD:
+		if !boot.IsElTorito(string(data[7:39])) {
+			return
+		}
+		r.ElTorito = true
+		r.BootCatalog = binary.LittleEndian.Uint32(data[0x47:0x4B])
+		catalog := make([]byte, consts.ISO9660_SECTOR_SIZE)
+		if _, err := reader.ReadAt(catalog, int64(r.BootCatalog)*consts.ISO9660_SECTOR_SIZE); err != nil {
+			return
+		}
+		var et boot.ElTorito
+		if err := et.UnmarshalBinary(catalog); err == nil {
+			r.BootEntries = et.Entries
+		}
+	}
+}
+
+// hasRockRidge returns true if the "." record of the root directory at the given sector carries Rock Ridge entries or
+// an ER entry referencing Rock Ridge, possibly in a continuation area.
+func hasRockRidge(reader io.ReaderAt, root uint32) bool {
+	sector := make([]byte, consts.ISO9660_SECTOR_SIZE)
+	if _, err := reader.ReadAt(sector, int64(root)*consts.ISO9660_SECTOR_SIZE); err != nil {
+		return false
+	}
+	length := int(sector[0])
+	if length < 34 {
+		return false
+	}
+	// The system use area follows the identifier and its padding byte
+	start := 33 + int(sector[32])
+	if sector[32]%2 == 0 {
+		start++
+	}
+	if start >= length {
+		return false
+	}
+	_, systemUse := extensions.UnmarshalXA(sector[start:length])
+	entries, err := extensions.ReadSystemUse(systemUse, reader)
+	if err != nil {
+		return false
+	}
+	for _, id := range extensions.ExtensionIdentifiers(entries) {
+		if slices.Contains(rockRidgeIdentifiers, id) {
+			return true
+		}
+	}
+	rr, err := extensions.UnmarshalRockRidge(entries)
+	return err == nil && rr.HasRockRidge()
+}
+
+// probeAnchors looks for UDF anchor volume descriptor pointers at sector 256 and at the last sector and 256 sectors
+// before it.
+func (r *Result) probeAnchors(reader io.ReaderAt) {
+	last := r.Size/consts.UDF_SECTOR_SIZE - 1
+	tag := make([]byte, 16)
+	for _, lba := range []int64{consts.UDF_ANCHOR_SECTOR, last - consts.UDF_ANCHOR_SECTOR, last} {
+		if lba < consts.UDF_ANCHOR_SECTOR || slices.Contains(r.Anchors, lba) {
+			continue
+		}
+		if _, err := reader.ReadAt(tag, lba*consts.UDF_SECTOR_SIZE); err != nil {
+			continue
+		}
+		if binary.LittleEndian.Uint16(tag[0:2]) != udfAnchorTag || int64(binary.LittleEndian.Uint32(tag[12:16])) != lba {
+			continue
+		}
+		// The tag checksum is the sum of the tag bytes other than itself
+		var sum byte
+		for i, b := range tag {
+			if i != 4 {
+				sum += b
+			}
+		}
+		if sum == tag[4] {
+			r.Anchors = append(r.Anchors, lba)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/boot"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestProbeISO9660(t *testing.T) {
+	img, err := iso9660.Create("PROBE_TEST",
+		option.WithJolietEnabled(true),
+		option.WithEnableRockRidge(true),
+		option.WithBootEntry(option.BootEntry{ImagePath: "/boot/bios.img"}),
+		option.WithBootEntry(option.BootEntry{ImagePath: "/boot/efi.img", Platform: boot.EFI}),
+		option.WithHybrid(option.HybridOptions{EFIPartition: true}))
+	require.NoError(t, err)
+	require.NoError(t, img.AddFile("/boot/bios.img", make([]byte, 2048)))
+	require.NoError(t, img.AddFile("/boot/efi.img", make([]byte, 4096)))
+	image := isotest.Bytes(t, img)
+
+	res := Probe(bytes.NewReader(image), int64(len(image)))
+	require.True(t, res.ISO9660)
+	require.Equal(t, int64(len(image)), int64(res.VolumeSize)*consts.ISO9660_SECTOR_SIZE)
+	require.True(t, res.Joliet)
+	require.Equal(t, 3, res.JolietLevel)
+	require.True(t, res.RockRidge)
+	require.False(t, res.Enhanced)
+	require.True(t, res.ElTorito)
+	require.Len(t, res.BootEntries, 2)
+	require.Equal(t, boot.EFI, res.BootEntries[1].Platform)
+	require.False(t, res.HasUDF())
+	require.Equal(t, consts.ISO9660_STD_IDENTIFIER, res.VolumeDescriptors[0].Identifier)
+
+	require.True(t, res.MBR)
+	require.False(t, res.GPT)
+	require.Len(t, res.Partitions, 2)
+	require.True(t, res.Partitions[0].Bootable)
+	require.Equal(t, "0xEF", res.Partitions[1].Type)
+	require.Empty(t, res.AppendedPartitions())
+
+	// A partition appended after the end of the volume
+	entry := image[446+2*16:]
+	entry[4] = 0x83
+	binary.LittleEndian.PutUint32(entry[8:], uint32(len(image)/512))
+	binary.LittleEndian.PutUint32(entry[12:], 2048)
+	image = append(image, make([]byte, 2048*512)...)
+	res = Probe(bytes.NewReader(image), int64(len(image)))
+	appended := res.AppendedPartitions()
+	require.Len(t, appended, 1)
+	require.Equal(t, 3, appended[0].Number)
+	require.Equal(t, int64(len(image)-2048*512), appended[0].Start)
+}
+
+func TestProbeUDF(t *testing.T) {
+	const sectors = 300
+	image := make([]byte, sectors*consts.UDF_SECTOR_SIZE)
+	for i, id := range []string{consts.UDF_STD_IDENTIFIER, consts.UDF_NSR03_IDENTIFIER, consts.UDF_TEA_IDENTIFIER} {
+		vd := image[(16+i)*consts.UDF_SECTOR_SIZE:]
+		copy(vd[1:], id)
+		vd[6] = 1
+	}
+
+	// Anchor volume descriptor pointers at sector 256 and at the last sector, with a bad checksum at the latter
+	for _, lba := range []int{256, sectors - 1} {
+		tag := image[lba*consts.UDF_SECTOR_SIZE:]
+		binary.LittleEndian.PutUint16(tag[0:], udfAnchorTag)
+		binary.LittleEndian.PutUint32(tag[12:], uint32(lba))
+		for i := 0; i < 16; i++ {
+			if i != 4 {
+				tag[4] += tag[i]
+			}
+		}
+	}
+	image[(sectors-1)*consts.UDF_SECTOR_SIZE+4]++
+
+	// A GPT with one partition and an Apple partition map of 2048-byte blocks
+	copy(image[512:], gptSignature)
+	binary.LittleEndian.PutUint64(image[512+72:], 2)
+	binary.LittleEndian.PutUint32(image[512+80:], 4)
+	binary.LittleEndian.PutUint32(image[512+84:], 128)
+	gpt := image[1024:]
+	copy(gpt, []byte{0x28, 0x73, 0x2A, 0xC1, 0x1F, 0xF8, 0xD2, 0x11, 0xBA, 0x4B, 0x00, 0xA0, 0xC9, 0x3E, 0xC9, 0x3B})
+	binary.LittleEndian.PutUint64(gpt[32:], 64)
+	binary.LittleEndian.PutUint64(gpt[40:], 127)
+	copy(gpt[56:], []byte{'E', 0, 'F', 0, 'I', 0})
+	copy(image, apmDriverSignature)
+	binary.BigEndian.PutUint16(image[2:], 2048)
+	apm := image[2048:]
+	copy(apm, apmPartitionSignature)
+	binary.BigEndian.PutUint32(apm[4:], 1)
+	binary.BigEndian.PutUint32(apm[8:], 1)
+	binary.BigEndian.PutUint32(apm[12:], 10)
+	copy(apm[16:], "Apple")
+	copy(apm[48:], "Apple_partition_map")
+
+	res := Probe(bytes.NewReader(image), int64(len(image)))
+	require.False(t, res.ISO9660)
+	require.True(t, res.HasUDF())
+	require.Equal(t, consts.UDF_NSR03_IDENTIFIER, res.UDFStandard)
+	require.Equal(t, []int64{256}, res.Anchors)
+	require.Len(t, res.VolumeDescriptors, 3)
+
+	require.False(t, res.MBR)
+	require.True(t, res.GPT)
+	require.True(t, res.APM)
+	require.Equal(t, []Partition{
+		{Scheme: PARTITION_SCHEME_GPT, Number: 1, Type: "C12A7328-F81F-11D2-BA4B-00A0C93EC93B", Name: "EFI", Start: 64 * 512, Size: 64 * 512},
+		{Scheme: PARTITION_SCHEME_APM, Number: 1, Type: "Apple_partition_map", Name: "Apple", Start: 2048, Size: 10 * 2048},
+	}, res.Partitions)
+
+	// GPT headers asking for oversized entries are ignored rather than read
+	binary.LittleEndian.PutUint32(image[512+84:], 0xFFFFFF80)
+	res = Probe(bytes.NewReader(image), int64(len(image)))
+	require.False(t, res.GPT)
+}
@@ -0,0 +1,168 @@
+// Package seed builds the small configuration ISOs that cloud-init and cloudbase-init read on first boot. Both the
+// NoCloud (cidata) and the OpenStack config-drive (config-2) layouts are supported. Seed images always have Joliet
+// and Rock Ridge enabled so both Linux and Windows guests see the original file names.
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bgrewell/iso-kit"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	// Volume label cloud-init looks for when using the NoCloud datasource
+	NOCLOUD_VOLUME_ID = "cidata"
+	// Volume label cloud-init and cloudbase-init look for when using an OpenStack config drive
+	CONFIG_DRIVE_VOLUME_ID = "config-2"
+	// Version directory that always mirrors the newest metadata
+	CONFIG_DRIVE_LATEST = "latest"
+)
+
+// NoCloudConfig holds the files written to a NoCloud seed image.
+type NoCloudConfig struct {
+	// UserData is the content of user-data (usually a #cloud-config document). An empty file is written when nil.
+	UserData []byte
+	// MetaData is the content of meta-data. When nil it is generated from InstanceID and LocalHostname.
+	MetaData []byte
+	// NetworkConfig is the content of network-config. The file is omitted when nil.
+	NetworkConfig []byte
+	// VendorData is the content of vendor-data. The file is omitted when nil.
+	VendorData []byte
+	// InstanceID is used to generate meta-data when MetaData is nil
+	InstanceID string
+	// LocalHostname is used to generate meta-data when MetaData is nil
+	LocalHostname string
+}
+
+// ConfigDriveConfig holds the files written to an OpenStack config drive.
+type ConfigDriveConfig struct {
+	// MetaData is the content of meta_data.json. When nil it is generated from UUID and Hostname.
+	MetaData []byte
+	// UserData is the content of user_data. The file is omitted when nil.
+	UserData []byte
+	// NetworkData is the content of network_data.json. The file is omitted when nil.
+	NetworkData []byte
+	// VendorData is the content of vendor_data.json. The file is omitted when nil.
+	VendorData []byte
+	// UUID is used to generate meta_data.json when MetaData is nil
+	UUID string
+	// Hostname is used to generate meta_data.json when MetaData is nil
+	Hostname string
+	// Versions lists additional dated version directories (e.g. "2012-08-10") that receive a copy of the metadata.
+	Versions []string
+	// Files holds additional files keyed by their path relative to the root of the drive
+	// (e.g. "openstack/content/0000").
+	Files map[string][]byte
+}
+
+// NewNoCloud creates a NoCloud seed image labeled cidata containing user-data, meta-data and, when given,
+// network-config and vendor-data. Additional create options are applied after the seed defaults.
+func NewNoCloud(cfg NoCloudConfig, opts ...option.CreateOption) (iso.ISO, error) {
+	metaData := cfg.MetaData
+	if metaData == nil {
+		if cfg.InstanceID == "" {
+			return nil, fmt.Errorf("either meta-data or an instance id must be provided")
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "instance-id: %s\n", cfg.InstanceID)
+		if cfg.LocalHostname != "" {
+			fmt.Fprintf(&sb, "local-hostname: %s\n", cfg.LocalHostname)
+		}
+		metaData = []byte(sb.String())
+	}
+
+	files := map[string][]byte{
+		"/user-data": orEmpty(cfg.UserData),
+		"/meta-data": metaData,
+	}
+	if cfg.NetworkConfig != nil {
+		files["/network-config"] = cfg.NetworkConfig
+	}
+	if cfg.VendorData != nil {
+		files["/vendor-data"] = cfg.VendorData
+	}
+
+	return create(NOCLOUD_VOLUME_ID, files, opts)
+}
+
+// NewConfigDrive creates an OpenStack config drive labeled config-2 with the metadata under openstack/latest/ and
+// any additional version directories. Additional create options are applied after the seed defaults.
+func NewConfigDrive(cfg ConfigDriveConfig, opts ...option.CreateOption) (iso.ISO, error) {
+	metaData := cfg.MetaData
+	if metaData == nil {
+		if cfg.UUID == "" {
+			return nil, fmt.Errorf("either meta_data.json or a uuid must be provided")
+		}
+		generated := map[string]string{"uuid": cfg.UUID}
+		if cfg.Hostname != "" {
+			generated["hostname"] = cfg.Hostname
+			generated["name"] = cfg.Hostname
+		}
+		data, err := json.Marshal(generated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate meta_data.json: %w", err)
+		}
+		metaData = data
+	}
+
+	files := make(map[string][]byte)
+	for _, version := range append([]string{CONFIG_DRIVE_LATEST}, cfg.Versions...) {
+		if version == "" || strings.Contains(version, "/") {
+			return nil, fmt.Errorf("invalid config drive version %q", version)
+		}
+		dir := path.Join("/openstack", version)
+		files[path.Join(dir, "meta_data.json")] = metaData
+		if cfg.UserData != nil {
+			files[path.Join(dir, "user_data")] = cfg.UserData
+		}
+		if cfg.NetworkData != nil {
+			files[path.Join(dir, "network_data.json")] = cfg.NetworkData
+		}
+		if cfg.VendorData != nil {
+			files[path.Join(dir, "vendor_data.json")] = cfg.VendorData
+		}
+	}
+	for name, data := range cfg.Files {
+		files[path.Join("/", name)] = data
+	}
+
+	return create(CONFIG_DRIVE_VOLUME_ID, files, opts)
+}
+
+// create builds a seed image with the given label and files.
+func create(label string, files map[string][]byte, opts []option.CreateOption) (iso.ISO, error) {
+	createOpts := append([]option.CreateOption{
+		option.WithJolietEnabled(true),
+		option.WithEnableRockRidge(true),
+	}, opts...)
+
+	img, err := iso.Create(label, createOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s image: %w", label, err)
+	}
+
+	// Add files in a stable order so the layout does not depend on map iteration
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err := img.AddFile(name, files[name]); err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", name, err)
+		}
+	}
+
+	return img, nil
+}
+
+func orEmpty(data []byte) []byte {
+	if data == nil {
+		return []byte{}
+	}
+	return data
+}
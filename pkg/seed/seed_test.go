@@ -0,0 +1,58 @@
+package seed
+
+import (
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/isotest"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSeeds(t *testing.T) {
+	noCloud, err := NewNoCloud(NoCloudConfig{
+		UserData:      []byte("#cloud-config\n"),
+		NetworkConfig: []byte("version: 2\n"),
+		InstanceID:    "i-123",
+		LocalHostname: "vm1",
+	})
+	require.NoError(t, err)
+
+	configDrive, err := NewConfigDrive(ConfigDriveConfig{
+		UUID:     "0b7ba6d1-7d21-4d0d-8b1f-0f3c6f0c9d5e",
+		UserData: []byte("#cloud-config\n"),
+		Versions: []string{"2012-08-10"},
+	})
+	require.NoError(t, err)
+
+	for label, tc := range map[string]struct {
+		seed  isotest.Saver
+		files []string
+	}{
+		NOCLOUD_VOLUME_ID: {
+			seed:  noCloud,
+			files: []string{"/meta-data", "/network-config", "/user-data"},
+		},
+		CONFIG_DRIVE_VOLUME_ID: {
+			seed: configDrive,
+			files: []string{
+				"/openstack/2012-08-10/meta_data.json", "/openstack/2012-08-10/user_data",
+				"/openstack/latest/meta_data.json", "/openstack/latest/user_data",
+			},
+		},
+	} {
+		t.Run(label, func(t *testing.T) {
+			img, err := iso9660.Open(isotest.File(t, tc.seed), option.WithRockRidgeEnabled(true), option.WithPreferJoliet(true))
+			require.NoError(t, err)
+			require.Equal(t, label, img.GetVolumeID())
+			require.True(t, img.HasJoliet())
+
+			entries, err := img.ListFiles()
+			require.NoError(t, err)
+			var names []string
+			for _, e := range entries {
+				names = append(names, e.FullPath)
+			}
+			require.ElementsMatch(t, tc.files, names)
+		})
+	}
+}
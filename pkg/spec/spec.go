@@ -0,0 +1,400 @@
+// Package spec implements declarative build specifications for ISO9660 images. A specification describes the volume
+// metadata, the enabled extensions, the host paths grafted into the image, per-file attribute overrides, El Torito
+// boot entries and hybrid MBR settings in a single YAML or JSON document.
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/boot"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Spec is the root of a build specification.
+type Spec struct {
+	Volume     Volume     `yaml:"volume" json:"volume"`
+	Extensions Extensions `yaml:"extensions" json:"extensions"`
+	Grafts     []Graft    `yaml:"grafts" json:"grafts"`
+	Overrides  []Override `yaml:"overrides" json:"overrides"`
+	Boot       *Boot      `yaml:"boot,omitempty" json:"boot,omitempty"`
+	Hybrid     *Hybrid    `yaml:"hybrid,omitempty" json:"hybrid,omitempty"`
+
+	// baseDir is the directory relative host paths are resolved against
+	baseDir string
+}
+
+// Volume holds the identifiers and times recorded in the volume descriptors.
+type Volume struct {
+	ID                string     `yaml:"id" json:"id"`
+	SystemID          string     `yaml:"system_id" json:"system_id"`
+	VolumeSetID       string     `yaml:"volume_set_id" json:"volume_set_id"`
+	PublisherID       string     `yaml:"publisher_id" json:"publisher_id"`
+	PreparerID        string     `yaml:"preparer_id" json:"preparer_id"`
+	ApplicationID     string     `yaml:"application_id" json:"application_id"`
+	CopyrightFile     string     `yaml:"copyright_file" json:"copyright_file"`
+	AbstractFile      string     `yaml:"abstract_file" json:"abstract_file"`
+	BibliographicFile string     `yaml:"bibliographic_file" json:"bibliographic_file"`
+	CreationTime      *time.Time `yaml:"creation_time,omitempty" json:"creation_time,omitempty"`
+	ModificationTime  *time.Time `yaml:"modification_time,omitempty" json:"modification_time,omitempty"`
+	ExpirationTime    *time.Time `yaml:"expiration_time,omitempty" json:"expiration_time,omitempty"`
+	EffectiveTime     *time.Time `yaml:"effective_time,omitempty" json:"effective_time,omitempty"`
+}
+
+// Extensions selects the extensions recorded in the image.
+type Extensions struct {
+	Joliet    bool `yaml:"joliet" json:"joliet"`
+	RockRidge bool `yaml:"rock_ridge" json:"rock_ridge"`
+	// Enhanced records an ISO 9660:1999 enhanced volume descriptor with long names.
+	Enhanced bool `yaml:"enhanced" json:"enhanced"`
+	// Level is the ISO9660 interchange level (1-3). Zero means level 1.
+	Level int `yaml:"level" json:"level"`
+}
+
+// Graft copies a host file or directory tree into the image.
+type Graft struct {
+	// Source is the host path. Relative paths are resolved against the directory of the specification.
+	Source string `yaml:"source" json:"source"`
+	// Target is the path inside the image. An empty target grafts into the root directory.
+	Target string `yaml:"target" json:"target"`
+}
+
+// Override changes the attributes of every image entry whose path matches a glob (path.Match syntax).
+type Override struct {
+	Path string `yaml:"path" json:"path"`
+	// Mode is the octal permission string (e.g. "0755")
+	Mode   string  `yaml:"mode,omitempty" json:"mode,omitempty"`
+	UID    *uint32 `yaml:"uid,omitempty" json:"uid,omitempty"`
+	GID    *uint32 `yaml:"gid,omitempty" json:"gid,omitempty"`
+	Hidden *bool   `yaml:"hidden,omitempty" json:"hidden,omitempty"`
+}
+
+// Boot describes the El Torito boot catalog.
+type Boot struct {
+	Catalog     string      `yaml:"catalog" json:"catalog"`
+	HideCatalog bool        `yaml:"hide_catalog" json:"hide_catalog"`
+	Entries     []BootEntry `yaml:"entries" json:"entries"`
+}
+
+// BootEntry describes a single El Torito boot image.
+type BootEntry struct {
+	// Image is the path of the boot image inside the ISO
+	Image string `yaml:"image" json:"image"`
+	// Platform is one of bios, efi, ppc or mac. Empty means bios.
+	Platform string `yaml:"platform" json:"platform"`
+	// Emulation is one of none, floppy-1.2, floppy-1.44, floppy-2.88 or hdd. Empty means none.
+	Emulation     string `yaml:"emulation" json:"emulation"`
+	LoadSegment   uint16 `yaml:"load_segment" json:"load_segment"`
+	LoadSize      uint16 `yaml:"load_size" json:"load_size"`
+	BootInfoTable bool   `yaml:"boot_info_table" json:"boot_info_table"`
+	NoBoot        bool   `yaml:"no_boot" json:"no_boot"`
+}
+
+// Hybrid describes the MBR written to the system area of a hybrid image.
+type Hybrid struct {
+	// MBR is the host path of the MBR boot code (e.g. isohdpfx.bin). Relative paths are resolved against the
+	// directory of the specification.
+	MBR           string `yaml:"mbr" json:"mbr"`
+	PartitionType uint8  `yaml:"partition_type" json:"partition_type"`
+	EFIPartition  bool   `yaml:"efi_partition" json:"efi_partition"`
+	DiskSignature uint32 `yaml:"disk_signature" json:"disk_signature"`
+}
+
+// Load reads a specification from a file. Files with a .json extension are decoded as JSON, everything else as YAML.
+// Relative host paths in the specification are resolved against the directory of the file.
+func Load(filename string) (*Spec, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec %s: %w", filename, err)
+	}
+
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		format = "json"
+	}
+
+	s, err := Parse(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spec %s: %w", filename, err)
+	}
+	s.baseDir = filepath.Dir(filename)
+
+	return s, nil
+}
+
+// Parse decodes a specification in the given format ("yaml" or "json"). Unknown fields are rejected so typos in a
+// recipe do not silently change the image. Relative host paths are resolved against the working directory.
+func Parse(data []byte, format string) (*Spec, error) {
+	s := &Spec{}
+	switch format {
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(s); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(s); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported spec format %q", format)
+	}
+
+	return s, s.Validate()
+}
+
+// Validate checks the specification for values that cannot be turned into options.
+func (s *Spec) Validate() error {
+	if s.Volume.ID == "" {
+		return errors.New("volume.id is required")
+	}
+	if s.Extensions.Level < 0 || s.Extensions.Level > 3 {
+		return fmt.Errorf("extensions.level must be between 1 and 3, or 0 for the default, got %d", s.Extensions.Level)
+	}
+	for i, g := range s.Grafts {
+		if g.Source == "" {
+			return fmt.Errorf("grafts[%d].source is required", i)
+		}
+	}
+	for i, o := range s.Overrides {
+		if o.Path == "" {
+			return fmt.Errorf("overrides[%d].path is required", i)
+		}
+		if _, err := path.Match(o.Path, "/"); err != nil {
+			return fmt.Errorf("overrides[%d].path: %w", i, err)
+		}
+		if o.Mode != "" {
+			if _, err := parseMode(o.Mode); err != nil {
+				return fmt.Errorf("overrides[%d].mode: %w", i, err)
+			}
+		}
+	}
+	if s.Boot != nil {
+		if len(s.Boot.Entries) == 0 {
+			return errors.New("boot.entries must contain at least one entry")
+		}
+		for i, e := range s.Boot.Entries {
+			if e.Image == "" {
+				return fmt.Errorf("boot.entries[%d].image is required", i)
+			}
+			if _, err := parsePlatform(e.Platform); err != nil {
+				return fmt.Errorf("boot.entries[%d].platform: %w", i, err)
+			}
+			if _, err := parseEmulation(e.Emulation); err != nil {
+				return fmt.Errorf("boot.entries[%d].emulation: %w", i, err)
+			}
+		}
+	}
+	if s.Hybrid != nil && s.Boot == nil {
+		return errors.New("hybrid requires at least one boot entry")
+	}
+	return nil
+}
+
+// Options converts the specification into create options.
+func (s *Spec) Options() ([]option.CreateOption, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	level := s.Extensions.Level
+	if level == 0 {
+		level = 1
+	}
+	opts := []option.CreateOption{
+		option.WithJolietEnabled(s.Extensions.Joliet),
+		option.WithEnhancedEnabled(s.Extensions.Enhanced),
+		option.WithEnableRockRidge(s.Extensions.RockRidge),
+		option.WithInterchangeLevel(level),
+		option.WithSystemID(s.Volume.SystemID),
+		option.WithVolumeSetID(s.Volume.VolumeSetID),
+		option.WithPublisherID(s.Volume.PublisherID),
+		option.WithApplicationID(s.Volume.ApplicationID),
+		option.WithCopyrightFileID(s.Volume.CopyrightFile),
+		option.WithAbstractFileID(s.Volume.AbstractFile),
+		option.WithBibliographicFileID(s.Volume.BibliographicFile),
+		option.WithVolumeTimes(timeOrZero(s.Volume.CreationTime), timeOrZero(s.Volume.ModificationTime),
+			timeOrZero(s.Volume.ExpirationTime), timeOrZero(s.Volume.EffectiveTime)),
+	}
+	if s.Volume.PreparerID != "" {
+		opts = append(opts, option.WithPreparerID(s.Volume.PreparerID))
+	}
+
+	if s.Boot != nil {
+		opts = append(opts, option.WithBootCatalog(s.Boot.Catalog, s.Boot.HideCatalog))
+		for _, e := range s.Boot.Entries {
+			platform, _ := parsePlatform(e.Platform)
+			emulation, _ := parseEmulation(e.Emulation)
+			opts = append(opts, option.WithBootEntry(option.BootEntry{
+				ImagePath:     e.Image,
+				Platform:      platform,
+				Emulation:     emulation,
+				LoadSegment:   e.LoadSegment,
+				LoadSize:      e.LoadSize,
+				BootInfoTable: e.BootInfoTable,
+				NoBoot:        e.NoBoot,
+			}))
+		}
+	}
+
+	if s.Hybrid != nil {
+		hybrid := option.HybridOptions{
+			PartitionType: s.Hybrid.PartitionType,
+			EFIPartition:  s.Hybrid.EFIPartition,
+			DiskSignature: s.Hybrid.DiskSignature,
+		}
+		if s.Hybrid.MBR != "" {
+			mbr, err := os.ReadFile(s.hostPath(s.Hybrid.MBR))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read hybrid MBR: %w", err)
+			}
+			hybrid.MBR = mbr
+		}
+		opts = append(opts, option.WithHybrid(hybrid))
+	}
+
+	return opts, nil
+}
+
+// Create builds the image described by the specification without writing it. Additional options are applied after
+// the options of the specification.
+func (s *Spec) Create(opts ...option.CreateOption) (*iso9660.ISO9660, error) {
+	specOpts, err := s.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := iso9660.Create(s.Volume.ID, append(specOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, g := range s.Grafts {
+		target := g.Target
+		if target == "" {
+			target = "/"
+		}
+		if err := img.AddLocalPath(s.hostPath(g.Source), target); err != nil {
+			return nil, fmt.Errorf("failed to graft %s to %s: %w", g.Source, target, err)
+		}
+	}
+
+	for _, o := range s.Overrides {
+		var fileOpts []option.FileOption
+		if o.Mode != "" {
+			mode, _ := parseMode(o.Mode)
+			fileOpts = append(fileOpts, option.WithFileMode(mode))
+		}
+		if o.UID != nil || o.GID != nil {
+			fileOpts = append(fileOpts, withOwner(o.UID, o.GID))
+		}
+		if o.Hidden != nil {
+			fileOpts = append(fileOpts, option.WithHidden(*o.Hidden))
+		}
+		if err := img.SetFileOptions(o.Path, fileOpts...); err != nil {
+			return nil, err
+		}
+	}
+
+	return img, nil
+}
+
+// Build creates the image described by the specification and writes it to w.
+func (s *Spec) Build(w io.WriterAt, opts ...option.CreateOption) error {
+	img, err := s.Create(opts...)
+	if err != nil {
+		return err
+	}
+	return img.Save(w)
+}
+
+// hostPath resolves a host path from the specification.
+func (s *Spec) hostPath(p string) string {
+	if filepath.IsAbs(p) || s.baseDir == "" {
+		return p
+	}
+	return filepath.Join(s.baseDir, p)
+}
+
+// withOwner sets only the owner fields that are given.
+func withOwner(uid, gid *uint32) option.FileOption {
+	return func(o *option.FileOptions) {
+		if uid != nil {
+			o.UID = uid
+		}
+		if gid != nil {
+			o.GID = gid
+		}
+	}
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func parseMode(mode string) (fs.FileMode, error) {
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value > 0o7777 {
+		return 0, fmt.Errorf("invalid mode %q", mode)
+	}
+	m := fs.FileMode(value & 0o777)
+	if value&0o4000 != 0 {
+		m |= fs.ModeSetuid
+	}
+	if value&0o2000 != 0 {
+		m |= fs.ModeSetgid
+	}
+	if value&0o1000 != 0 {
+		m |= fs.ModeSticky
+	}
+	return m, nil
+}
+
+func parsePlatform(platform string) (boot.Platform, error) {
+	switch strings.ToLower(platform) {
+	case "", "bios", "x86":
+		return boot.BIOS, nil
+	case "efi", "uefi":
+		return boot.EFI, nil
+	case "ppc", "powerpc":
+		return boot.PPC, nil
+	case "mac":
+		return boot.Mac, nil
+	default:
+		return 0, fmt.Errorf("unknown platform %q", platform)
+	}
+}
+
+func parseEmulation(emulation string) (boot.Emulation, error) {
+	switch strings.ToLower(emulation) {
+	case "", "none", "no-emulation":
+		return boot.NoEmulation, nil
+	case "floppy-1.2":
+		return boot.Floppy12Emulation, nil
+	case "floppy-1.44":
+		return boot.Floppy144Emulation, nil
+	case "floppy-2.88":
+		return boot.Floppy288Emulation, nil
+	case "hdd", "hard-disk":
+		return boot.HardDiskEmulation, nil
+	default:
+		return 0, fmt.Errorf("unknown emulation %q", emulation)
+	}
+}
@@ -0,0 +1,138 @@
+package spec
+
+import (
+	"encoding/binary"
+	"github.com/bgrewell/iso-kit/pkg/consts"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/iso9660/pathtable"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("yaml and json are equivalent", func(t *testing.T) {
+		y, err := Parse([]byte(`
+volume:
+  id: TEST
+extensions:
+  joliet: true
+  rock_ridge: true
+  level: 2
+overrides:
+  - path: /bin/*
+    mode: "0755"
+`), "yaml")
+		require.NoError(t, err)
+
+		j, err := Parse([]byte(`{
+  "volume": {"id": "TEST"},
+  "extensions": {"joliet": true, "rock_ridge": true, "level": 2},
+  "overrides": [{"path": "/bin/*", "mode": "0755"}]
+}`), "json")
+		require.NoError(t, err)
+		require.Equal(t, y, j)
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := Parse([]byte("volume:\n  id: TEST\n  label: oops\n"), "yaml")
+		require.Error(t, err)
+
+		_, err = Parse([]byte(`{"volume": {"id": "TEST"}, "extra": 1}`), "json")
+		require.Error(t, err)
+	})
+
+	t.Run("rejects invalid values", func(t *testing.T) {
+		for _, doc := range []string{
+			"extensions: {level: 1}\n",
+			"volume: {id: TEST}\nextensions: {level: 4}\n",
+			"volume: {id: TEST}\noverrides: [{path: /a, mode: rwx}]\n",
+			"volume: {id: TEST}\nboot: {entries: [{image: /b, platform: arm}]}\n",
+			"volume: {id: TEST}\nhybrid: {disk_signature: 1}\n",
+		} {
+			_, err := Parse([]byte(doc), "yaml")
+			require.Error(t, err, doc)
+		}
+	})
+}
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "root", "docs"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "root", "docs", "readme.txt"), []byte("hello"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "boot.img"), make([]byte, 2048), 0o644))
+
+	specPath := filepath.Join(dir, "build.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(`
+volume:
+  id: SPEC_TEST
+extensions:
+  joliet: true
+  rock_ridge: true
+grafts:
+  - source: root
+  - source: boot.img
+    target: /boot/boot.img
+overrides:
+  - path: /docs/*
+    mode: "0600"
+    uid: 1000
+    gid: 1000
+boot:
+  entries:
+    - image: /boot/boot.img
+`), 0o644))
+
+	s, err := Load(specPath)
+	require.NoError(t, err)
+
+	out, err := os.Create(filepath.Join(dir, "out.iso"))
+	require.NoError(t, err)
+	defer out.Close()
+	require.NoError(t, s.Build(out))
+
+	img, err := iso9660.Open(out, option.WithRockRidgeEnabled(true))
+	require.NoError(t, err)
+	require.Equal(t, "SPEC_TEST", img.GetVolumeID())
+	require.True(t, img.HasJoliet())
+	require.True(t, img.HasElTorito())
+
+	files, err := img.ListFiles()
+	require.NoError(t, err)
+	modes := make(map[string]os.FileMode)
+	for _, f := range files {
+		modes[f.FullPath] = f.Mode
+	}
+	require.Contains(t, modes, "/boot/boot.img")
+	require.Contains(t, modes, "/boot.catalog")
+	require.Equal(t, os.FileMode(0o600), modes["/docs/readme.txt"].Perm())
+
+	// Both path tables of the primary volume descriptor locate every directory
+	pvd := make([]byte, consts.ISO9660_SECTOR_SIZE)
+	_, err = out.ReadAt(pvd, consts.ISO9660_SYSTEM_AREA_SECTORS*consts.ISO9660_SECTOR_SIZE)
+	require.NoError(t, err)
+	dirs, err := img.ListDirectories()
+	require.NoError(t, err)
+	locations := []uint32{img.RootDirectoryLocation()}
+	for _, d := range dirs {
+		locations = append(locations, d.Location)
+	}
+	size := int(binary.LittleEndian.Uint32(pvd[132:136]))
+	for _, table := range []struct {
+		location     uint32
+		littleEndian bool
+	}{
+		{binary.LittleEndian.Uint32(pvd[140:144]), true},
+		{binary.BigEndian.Uint32(pvd[148:152]), false},
+	} {
+		pt, err := pathtable.NewPathTable(out, table.location, size, "Primary", table.littleEndian)
+		require.NoError(t, err)
+		var extents []uint32
+		for _, record := range pt.Records {
+			extents = append(extents, record.LocationOfExtent)
+		}
+		require.ElementsMatch(t, locations, extents)
+	}
+}
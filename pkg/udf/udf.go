@@ -6,6 +6,7 @@ import (
 	"github.com/bgrewell/iso-kit/pkg/logging"
 	"github.com/bgrewell/iso-kit/pkg/option"
 	"io"
+	"io/fs"
 	"time"
 )
 
@@ -87,6 +88,11 @@ func (U UDF) HasJoliet() bool {
 	panic("implement me")
 }
 
+func (U UDF) HasEnhanced() bool {
+	//TODO implement me
+	panic("implement me")
+}
+
 func (U UDF) HasRockRidge() bool {
 	//TODO implement me
 	panic("implement me")
@@ -132,6 +138,21 @@ func (U UDF) ReadFile(path string) ([]byte, error) {
 	panic("implement me")
 }
 
+func (U UDF) Open(name string) (fs.File, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (U UDF) ReadDir(name string) ([]fs.DirEntry, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (U UDF) Stat(name string) (fs.FileInfo, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
 func (U UDF) AddFile(path string, data []byte) error {
 	//TODO implement me
 	panic("implement me")
@@ -142,6 +163,11 @@ func (U UDF) RemoveFile(path string) error {
 	panic("implement me")
 }
 
+func (U UDF) PatchFile(w io.WriterAt, path string, data []byte) error {
+	//TODO implement me
+	panic("implement me")
+}
+
 func (U UDF) CreateDirectories(path string) error {
 	panic("implement me")
 }
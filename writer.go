@@ -0,0 +1,226 @@
+package iso
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"io"
+	"os"
+	"path"
+)
+
+const (
+	// Volume identifier used by NewWriter when none is given with option.WithVolumeID
+	WRITER_DEFAULT_VOLUME_ID = "CDROM"
+)
+
+var (
+	// ErrWriteTooLong is returned when more data is written than the size given in the header.
+	ErrWriteTooLong = errors.New("iso: write too long")
+	// ErrWriteAfterClose is returned when the Writer is used after Close.
+	ErrWriteAfterClose = errors.New("iso: write after close")
+)
+
+// Writer provides sequential writing of an ISO9660 image in the style of archive/tar.Writer. WriteHeader begins a new
+// entry, Write supplies the data of regular files and Close lays out the image and writes it to the underlying
+// io.Writer.
+//
+// File data is spooled to a temporary file until Close because the directories, path tables and volume descriptors
+// precede the file data in the image and can only be written once every entry is known.
+type Writer struct {
+	w         io.Writer
+	img       *iso9660.ISO9660
+	err       error
+	spool     *os.File
+	spoolSize int64
+	current   *tar.Header
+	remaining int64
+	closed    bool
+}
+
+// NewWriter creates a Writer writing an ISO9660 image to w. The create options are the same as those accepted by
+// Create; the volume identifier is set with option.WithVolumeID.
+func NewWriter(w io.Writer, opts ...option.CreateOption) *Writer {
+	options := option.CreateOptions{
+		ISOType:  option.ISO_TYPE_ISO9660,
+		VolumeID: WRITER_DEFAULT_VOLUME_ID,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	writer := &Writer{w: w}
+	if options.ISOType != option.ISO_TYPE_ISO9660 {
+		writer.err = errors.New("iso: only ISO9660 images can be written sequentially")
+		return writer
+	}
+	writer.img, writer.err = iso9660.Create("", append([]option.CreateOption{option.WithVolumeID(options.VolumeID)}, opts...)...)
+	return writer
+}
+
+// WriteHeader begins a new entry described by hdr. Regular files, directories and symbolic links are supported; the
+// data of a regular file must be supplied with Write before the next call to WriteHeader or Close. Parent directories
+// that have not been written explicitly are created with default attributes.
+func (tw *Writer) WriteHeader(hdr *tar.Header) error {
+	if err := tw.finishEntry(); err != nil {
+		return err
+	}
+	if hdr.Size < 0 {
+		return fmt.Errorf("iso: negative size for %s", hdr.Name)
+	}
+
+	name := path.Clean("/" + hdr.Name)
+	fileOpts := []option.FileOption{
+		option.WithFileMode(hdr.FileInfo().Mode()),
+		option.WithOwner(uint32(hdr.Uid), uint32(hdr.Gid)),
+	}
+	if !hdr.ModTime.IsZero() {
+		fileOpts = append(fileOpts, option.WithModTime(hdr.ModTime))
+	}
+
+	var err error
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		if err = tw.ensureSpool(); err != nil {
+			return err
+		}
+		section := io.NewSectionReader(tw.spool, tw.spoolSize, hdr.Size)
+		if err = tw.img.AddFileFromReader(name, section, hdr.Size, fileOpts...); err == nil {
+			tw.current = hdr
+			tw.remaining = hdr.Size
+		}
+	case tar.TypeDir:
+		err = tw.img.AddDirectory(name, fileOpts...)
+	case tar.TypeSymlink:
+		err = tw.img.AddSymlink(name, hdr.Linkname, fileOpts...)
+	default:
+		return fmt.Errorf("iso: unsupported entry type %q for %s", hdr.Typeflag, hdr.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("iso: failed to add %s: %w", hdr.Name, err)
+	}
+
+	return nil
+}
+
+// Write writes to the current regular file. Write returns ErrWriteTooLong if more than the size given in the header
+// is written.
+func (tw *Writer) Write(b []byte) (int, error) {
+	if tw.closed {
+		return 0, ErrWriteAfterClose
+	}
+	if tw.err != nil {
+		return 0, tw.err
+	}
+
+	overflow := false
+	if int64(len(b)) > tw.remaining {
+		b = b[:tw.remaining]
+		overflow = true
+	}
+	var n int
+	if len(b) > 0 {
+		var err error
+		n, err = tw.spool.WriteAt(b, tw.spoolSize)
+		tw.spoolSize += int64(n)
+		tw.remaining -= int64(n)
+		if err != nil {
+			tw.err = fmt.Errorf("iso: failed to spool file data: %w", err)
+			return n, tw.err
+		}
+	}
+	if overflow {
+		return n, ErrWriteTooLong
+	}
+	return n, nil
+}
+
+// Close lays out the image and writes it to the underlying writer. It does not close the underlying writer.
+func (tw *Writer) Close() error {
+	if tw.closed {
+		return nil
+	}
+	defer tw.removeSpool()
+
+	err := tw.finishEntry()
+	tw.closed = true
+	if err != nil {
+		return err
+	}
+
+	if err = tw.img.Save(&sequentialWriter{w: tw.w}); err != nil {
+		return fmt.Errorf("iso: failed to write image: %w", err)
+	}
+	return nil
+}
+
+// finishEntry checks that the data of the current file was written completely.
+func (tw *Writer) finishEntry() error {
+	if tw.closed {
+		return ErrWriteAfterClose
+	}
+	if tw.err != nil {
+		return tw.err
+	}
+	if tw.current != nil && tw.remaining > 0 {
+		tw.err = fmt.Errorf("iso: missed writing %d bytes of %s", tw.remaining, tw.current.Name)
+		return tw.err
+	}
+	tw.current = nil
+	return nil
+}
+
+// ensureSpool creates the temporary file holding file data until Close.
+func (tw *Writer) ensureSpool() error {
+	if tw.spool != nil {
+		return nil
+	}
+	f, err := os.CreateTemp("", "iso-kit-writer-*")
+	if err != nil {
+		tw.err = fmt.Errorf("iso: failed to create spool file: %w", err)
+		return tw.err
+	}
+	tw.spool = f
+	return nil
+}
+
+func (tw *Writer) removeSpool() {
+	if tw.spool != nil {
+		tw.spool.Close()
+		os.Remove(tw.spool.Name())
+		tw.spool = nil
+	}
+}
+
+// sequentialWriter adapts an io.Writer to the io.WriterAt used by Save. Save writes objects in ascending offset order
+// so gaps are filled with zeros and writes before the current position are rejected.
+type sequentialWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (s *sequentialWriter) WriteAt(p []byte, off int64) (int, error) {
+	if off < s.pos {
+		return 0, fmt.Errorf("out of order write at offset %d, already at %d", off, s.pos)
+	}
+	if gap := off - s.pos; gap > 0 {
+		written, err := io.CopyN(s.w, zeroReader{}, gap)
+		s.pos += written
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.w.Write(p)
+	s.pos += int64(n)
+	return n, err
+}
+
+// zeroReader is an endless source of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
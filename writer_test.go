@@ -0,0 +1,47 @@
+package iso
+
+import (
+	"archive/tar"
+	"bytes"
+	"github.com/bgrewell/iso-kit/pkg/iso9660"
+	"github.com/bgrewell/iso-kit/pkg/option"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	tw := NewWriter(&buf, option.WithVolumeID("WRITER"), option.WithEnableRockRidge(true))
+
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "etc/", Mode: 0o750}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "etc/hosts", Mode: 0o644, Size: 5}))
+	_, err := io.WriteString(tw, "hello")
+	require.NoError(t, err)
+	_, err = tw.Write([]byte("!"))
+	require.ErrorIs(t, err, ErrWriteTooLong)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "hosts", Linkname: "etc/hosts"}))
+	require.NoError(t, tw.Close())
+
+	_, err = tw.Write([]byte("x"))
+	require.ErrorIs(t, err, ErrWriteAfterClose)
+
+	img, err := iso9660.Open(bytes.NewReader(buf.Bytes()), option.WithRockRidgeEnabled(true))
+	require.NoError(t, err)
+	require.Equal(t, "WRITER", img.GetVolumeID())
+	files, err := img.ListFiles()
+	require.NoError(t, err)
+	contents := make(map[string]string)
+	for _, f := range files {
+		data, err := f.GetBytes()
+		require.NoError(t, err)
+		contents[f.FullPath] = string(data)
+	}
+	require.Equal(t, "hello", contents["/etc/hosts"])
+
+	t.Run("short file", func(t *testing.T) {
+		tw := NewWriter(io.Discard)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "short", Size: 3}))
+		require.Error(t, tw.Close())
+	})
+}